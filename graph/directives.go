@@ -0,0 +1,112 @@
+package graph
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/pascaloseko/ems/graph/model"
+	"github.com/pascaloseko/ems/internal/apikeys"
+	"github.com/pascaloseko/ems/internal/auth"
+	"github.com/pascaloseko/ems/internal/employees"
+)
+
+// RedactedValue replaces the value of @sensitive fields the caller may not see
+const RedactedValue = "[REDACTED]"
+
+// NewConfig wires the resolvers and schema directives together
+func NewConfig(r *Resolver) Config {
+	return Config{
+		Resolvers: r,
+		Directives: DirectiveRoot{
+			Sensitive: Sensitive,
+			HasRole:   HasRole,
+		},
+	}
+}
+
+// HasRole implements the @hasRole directive
+func HasRole(ctx context.Context, obj interface{}, next graphql.Resolver, role model.Role, scope *model.Scope) (interface{}, error) {
+	if !hasRole(ctx, role, scope) {
+		return nil, ErrAccessDenied
+	}
+	return next(ctx)
+}
+
+// hasRole reports whether the caller holds at least role, or is a service
+// account granted scope
+func hasRole(ctx context.Context, role model.Role, scope *model.Scope) bool {
+	if service := auth.ServiceForContext(ctx); service != nil {
+		return scope != nil && service.HasScope(toAPIScope(*scope))
+	}
+	user := auth.ForContext(ctx)
+	return user != nil && user.Role.Satisfies(toEmployeeRole(role))
+}
+
+// outranks reports whether the caller holds at least the role of target, so
+// that HR cannot edit or delete admins. Service accounts act as HR.
+func outranks(ctx context.Context, target employees.Employee) bool {
+	if auth.ServiceForContext(ctx) != nil {
+		return employees.RoleHR.Satisfies(target.Role)
+	}
+	user := auth.ForContext(ctx)
+	return user != nil && user.Role.Satisfies(target.Role)
+}
+
+// Sensitive implements the @sensitive directive
+func Sensitive(ctx context.Context, obj interface{}, next graphql.Resolver) (interface{}, error) {
+	value, err := next(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if canSeeSensitive(ctx, obj) {
+		return value, nil
+	}
+	return RedactedValue, nil
+}
+
+// RedactEmployee masks the @sensitive fields of employee for callers that resolve
+// employees outside of GraphQL, such as the REST handlers
+func RedactEmployee(ctx context.Context, employee *model.Employee) {
+	if canSeeSensitive(ctx, employee) {
+		return
+	}
+	employee.Email = RedactedValue
+	employee.Dob = RedactedValue
+}
+
+// canSeeSensitive reports whether the caller may see personal data of obj
+func canSeeSensitive(ctx context.Context, obj interface{}) bool {
+	if service := auth.ServiceForContext(ctx); service != nil {
+		return service.HasScope(apikeys.ScopeEmployeesReadSensitive)
+	}
+	user := auth.ForContext(ctx)
+	if user == nil {
+		return false
+	}
+	employee, ok := obj.(*model.Employee)
+	if !ok {
+		return false
+	}
+	// HR can see everyone, employees can always see their own data
+	return user.Role.Satisfies(employees.RoleHR) || employee.ID == strconv.FormatInt(user.ID, 10)
+}
+
+func toModelRole(role employees.Role) model.Role {
+	return model.Role(strings.ToUpper(string(role)))
+}
+
+func toEmployeeRole(role model.Role) employees.Role {
+	return employees.Role(strings.ToLower(string(role)))
+}
+
+// toAPIScope maps EMPLOYEES_READ to employees:read
+func toAPIScope(scope model.Scope) apikeys.Scope {
+	resource, access, _ := strings.Cut(strings.ToLower(string(scope)), "_")
+	return apikeys.Scope(resource + ":" + access)
+}
+
+func toModelScope(scope apikeys.Scope) model.Scope {
+	return model.Scope(strings.ToUpper(strings.Replace(string(scope), ":", "_", 1)))
+}
@@ -0,0 +1,68 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/pascaloseko/ems/internal/employees"
+	"github.com/pascaloseko/ems/internal/lockout"
+	"github.com/pascaloseko/ems/internal/passwords"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// ErrorPresenter adds machine readable details to the errors clients are expected
+// to act on, such as the rules a rejected password breaks or when a locked
+// account can log in again
+func ErrorPresenter(ctx context.Context, err error) *gqlerror.Error {
+	gqlErr := graphql.DefaultErrorPresenter(ctx, err)
+	var violation *passwords.PolicyViolationError
+	if errors.As(err, &violation) {
+		gqlErr.Extensions = map[string]interface{}{
+			"code":       "PASSWORD_POLICY",
+			"violations": violation.Violations,
+		}
+	}
+	var locked *lockout.AccountLockedError
+	if errors.As(err, &locked) {
+		gqlErr.Extensions = map[string]interface{}{
+			"code":       "ACCOUNT_LOCKED",
+			"retryAfter": seconds(locked.RetryAfter),
+		}
+	}
+	var throttled *lockout.TooManyAttemptsError
+	if errors.As(err, &throttled) {
+		gqlErr.Extensions = map[string]interface{}{
+			"code":       "TOO_MANY_ATTEMPTS",
+			"retryAfter": seconds(throttled.RetryAfter),
+		}
+	}
+	// the message of the driver is left out, it only names the index
+	for _, c := range constraintCodes {
+		if errors.Is(err, c.err) {
+			gqlErr.Message = c.err.Error()
+			gqlErr.Extensions = map[string]interface{}{
+				"code": c.code,
+			}
+		}
+	}
+	return gqlErr
+}
+
+// constraintCodes are the codes of saving an employee that breaks a constraint
+// of the database
+var constraintCodes = []struct {
+	err  error
+	code string
+}{
+	{employees.ErrDuplicateUsername, "DUPLICATE_USERNAME"},
+	{employees.ErrDuplicateEmail, "DUPLICATE_EMAIL"},
+	{employees.ErrUnknownDepartment, "UNKNOWN_DEPARTMENT"},
+}
+
+// seconds rounds d up to whole seconds, as clients are told when to retry
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
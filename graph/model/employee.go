@@ -0,0 +1,15 @@
+package model
+
+// Employee is bound manually so that the department can be resolved from
+// DepartmentID instead of being loaded with every employee.
+type Employee struct {
+	ID           string `json:"id"`
+	FirstName    string `json:"firstName"`
+	LastName     string `json:"lastName"`
+	Username     string `json:"username"`
+	Email        string `json:"email"`
+	Dob          string `json:"dob"`
+	DepartmentID int64  `json:"departmentID"`
+	Position     string `json:"position"`
+	Role         Role   `json:"role"`
+}
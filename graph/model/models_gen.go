@@ -2,21 +2,67 @@
 
 package model
 
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+type APIKey struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// the start of the key, to tell keys apart
+	Prefix    string    `json:"prefix"`
+	Scopes    []Scope   `json:"scopes"`
+	CreatedAt time.Time `json:"createdAt"`
+	// the key is rejected from then on, it never expires when null
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+}
+
+// The tokens of a login. Employees with two-factor authentication first only get
+// an mfaToken, which verifyMFA exchanges for the access and refresh tokens.
+type AuthToken struct {
+	// short lived JWT sent as the Authorization header
+	AccessToken *string `json:"accessToken,omitempty"`
+	// opaque single use token, exchange it through refreshToken for a new pair
+	RefreshToken *string `json:"refreshToken,omitempty"`
+	// short lived token proving the password, pass it to verifyMFA with a code
+	MfaToken *string `json:"mfaToken,omitempty"`
+}
+
 type Department struct {
 	ID   string `json:"id"`
 	Name string `json:"name"`
 }
 
-type Employee struct {
-	ID           string `json:"id"`
-	FirstName    string `json:"firstName"`
-	LastName     string `json:"lastName"`
-	Username     string `json:"username"`
-	Password     string `json:"password"`
-	Email        string `json:"email"`
-	Dob          string `json:"dob"`
-	DepartmentID int    `json:"departmentID"`
-	Position     string `json:"position"`
+type EmployeeConnection struct {
+	Edges      []*EmployeeEdge `json:"edges"`
+	PageInfo   *PageInfo       `json:"pageInfo"`
+	TotalCount int             `json:"totalCount"`
+}
+
+type EmployeeEdge struct {
+	Cursor string    `json:"cursor"`
+	Node   *Employee `json:"node"`
+}
+
+// Filters are combined with AND. dobFrom and dobTo are inclusive YYYY-MM-DD dates.
+type EmployeeFilter struct {
+	// matches first name, last name or full name containing the value
+	Name       *string `json:"name,omitempty"`
+	Department *string `json:"department,omitempty"`
+	Position   *string `json:"position,omitempty"`
+	// matches employees whose email address is on this domain, e.g. example.com
+	EmailDomain *string `json:"emailDomain,omitempty"`
+	DobFrom     *string `json:"dobFrom,omitempty"`
+	DobTo       *string `json:"dobTo,omitempty"`
+}
+
+type EmployeeOrder struct {
+	Field     EmployeeOrderField `json:"field"`
+	Direction *OrderDirection    `json:"direction,omitempty"`
 }
 
 type Login struct {
@@ -27,15 +73,29 @@ type Login struct {
 type Mutation struct {
 }
 
+type NewAPIKey struct {
+	// send it as the X-API-Key header, it is not shown again
+	Key    string  `json:"key"`
+	APIKey *APIKey `json:"apiKey"`
+}
+
 type NewEmployee struct {
-	FirstName    string `json:"firstName"`
-	LastName     string `json:"lastName"`
-	Username     string `json:"username"`
-	Password     string `json:"password"`
-	Email        string `json:"email"`
-	Dob          string `json:"dob"`
-	DepartmentID int    `json:"departmentID"`
-	Position     string `json:"position"`
+	FirstName string `json:"firstName"`
+	LastName  string `json:"lastName"`
+	Username  string `json:"username"`
+	// write-only, passwords are never returned by any query
+	Password   string `json:"password"`
+	Email      string `json:"email"`
+	Dob        string `json:"dob"`
+	Department string `json:"department"`
+	Position   string `json:"position"`
+}
+
+type PageInfo struct {
+	HasNextPage     bool    `json:"hasNextPage"`
+	HasPreviousPage bool    `json:"hasPreviousPage"`
+	StartCursor     *string `json:"startCursor,omitempty"`
+	EndCursor       *string `json:"endCursor,omitempty"`
 }
 
 type Query struct {
@@ -44,3 +104,233 @@ type Query struct {
 type RefreshTokenInput struct {
 	Token string `json:"token"`
 }
+
+// a non-human client of the API, such as an integration, calling it with API keys
+type ServiceAccount struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"createdAt"`
+	APIKeys   []*APIKey `json:"apiKeys"`
+}
+
+// a login of an employee, lasting as long as its refresh tokens are used
+type Session struct {
+	ID         string    `json:"id"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+	UserAgent  string    `json:"userAgent"`
+	IPAddress  string    `json:"ipAddress"`
+	// true for the session the request was made with
+	Current bool `json:"current"`
+}
+
+// what an authenticator app needs, shown once when enrolling
+type TOTPEnrollment struct {
+	// otpauth:// URI, usually shown as a QR code
+	URI string `json:"uri"`
+	// the secret of the URI, for apps that cannot read it
+	Secret string `json:"secret"`
+	// single use codes to log in without the authenticator app, they are not shown again
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+type UpdateEmployee struct {
+	FirstName *string `json:"firstName,omitempty"`
+	LastName  *string `json:"lastName,omitempty"`
+	Username  *string `json:"username,omitempty"`
+	// write-only, passwords are never returned by any query
+	Password   *string `json:"password,omitempty"`
+	Email      *string `json:"email,omitempty"`
+	Dob        *string `json:"dob,omitempty"`
+	Department *string `json:"department,omitempty"`
+	Position   *string `json:"position,omitempty"`
+	// only admins can change roles
+	Role *Role `json:"role,omitempty"`
+}
+
+type EmployeeOrderField string
+
+const (
+	EmployeeOrderFieldID        EmployeeOrderField = "ID"
+	EmployeeOrderFieldFirstName EmployeeOrderField = "FIRST_NAME"
+	EmployeeOrderFieldLastName  EmployeeOrderField = "LAST_NAME"
+	EmployeeOrderFieldUsername  EmployeeOrderField = "USERNAME"
+	EmployeeOrderFieldEmail     EmployeeOrderField = "EMAIL"
+	EmployeeOrderFieldDob       EmployeeOrderField = "DOB"
+	EmployeeOrderFieldPosition  EmployeeOrderField = "POSITION"
+)
+
+var AllEmployeeOrderField = []EmployeeOrderField{
+	EmployeeOrderFieldID,
+	EmployeeOrderFieldFirstName,
+	EmployeeOrderFieldLastName,
+	EmployeeOrderFieldUsername,
+	EmployeeOrderFieldEmail,
+	EmployeeOrderFieldDob,
+	EmployeeOrderFieldPosition,
+}
+
+func (e EmployeeOrderField) IsValid() bool {
+	switch e {
+	case EmployeeOrderFieldID, EmployeeOrderFieldFirstName, EmployeeOrderFieldLastName, EmployeeOrderFieldUsername, EmployeeOrderFieldEmail, EmployeeOrderFieldDob, EmployeeOrderFieldPosition:
+		return true
+	}
+	return false
+}
+
+func (e EmployeeOrderField) String() string {
+	return string(e)
+}
+
+func (e *EmployeeOrderField) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = EmployeeOrderField(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid EmployeeOrderField", str)
+	}
+	return nil
+}
+
+func (e EmployeeOrderField) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type OrderDirection string
+
+const (
+	OrderDirectionAsc  OrderDirection = "ASC"
+	OrderDirectionDesc OrderDirection = "DESC"
+)
+
+var AllOrderDirection = []OrderDirection{
+	OrderDirectionAsc,
+	OrderDirectionDesc,
+}
+
+func (e OrderDirection) IsValid() bool {
+	switch e {
+	case OrderDirectionAsc, OrderDirectionDesc:
+		return true
+	}
+	return false
+}
+
+func (e OrderDirection) String() string {
+	return string(e)
+}
+
+func (e *OrderDirection) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = OrderDirection(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid OrderDirection", str)
+	}
+	return nil
+}
+
+func (e OrderDirection) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type Role string
+
+const (
+	RoleAdmin    Role = "ADMIN"
+	RoleHr       Role = "HR"
+	RoleManager  Role = "MANAGER"
+	RoleEmployee Role = "EMPLOYEE"
+)
+
+var AllRole = []Role{
+	RoleAdmin,
+	RoleHr,
+	RoleManager,
+	RoleEmployee,
+}
+
+func (e Role) IsValid() bool {
+	switch e {
+	case RoleAdmin, RoleHr, RoleManager, RoleEmployee:
+		return true
+	}
+	return false
+}
+
+func (e Role) String() string {
+	return string(e)
+}
+
+func (e *Role) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = Role(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid Role", str)
+	}
+	return nil
+}
+
+func (e Role) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+// what an API key lets a service account do
+type Scope string
+
+const (
+	ScopeEmployeesRead Scope = "EMPLOYEES_READ"
+	// see the personal data of employees, such as their email and date of birth
+	ScopeEmployeesReadSensitive Scope = "EMPLOYEES_READ_SENSITIVE"
+	ScopeEmployeesWrite         Scope = "EMPLOYEES_WRITE"
+	ScopeDepartmentsRead        Scope = "DEPARTMENTS_READ"
+	ScopeDepartmentsWrite       Scope = "DEPARTMENTS_WRITE"
+)
+
+var AllScope = []Scope{
+	ScopeEmployeesRead,
+	ScopeEmployeesReadSensitive,
+	ScopeEmployeesWrite,
+	ScopeDepartmentsRead,
+	ScopeDepartmentsWrite,
+}
+
+func (e Scope) IsValid() bool {
+	switch e {
+	case ScopeEmployeesRead, ScopeEmployeesReadSensitive, ScopeEmployeesWrite, ScopeDepartmentsRead, ScopeDepartmentsWrite:
+		return true
+	}
+	return false
+}
+
+func (e Scope) String() string {
+	return string(e)
+}
+
+func (e *Scope) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = Scope(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid Scope", str)
+	}
+	return nil
+}
+
+func (e Scope) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
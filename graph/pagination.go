@@ -0,0 +1,103 @@
+package graph
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pascaloseko/ems/graph/model"
+	"github.com/pascaloseko/ems/internal/employees"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+	cursorPrefix    = "cursor:"
+)
+
+// encodeCursor turns a row offset into an opaque cursor
+func encodeCursor(offset int) string {
+	return base64.StdEncoding.EncodeToString([]byte(cursorPrefix + strconv.Itoa(offset)))
+}
+
+// decodeCursor returns the row offset a cursor points at
+func decodeCursor(cursor string) (int, error) {
+	raw, err := base64.StdEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(raw), cursorPrefix) {
+		return 0, fmt.Errorf("invalid cursor %q", cursor)
+	}
+	offset, err := strconv.Atoi(strings.TrimPrefix(string(raw), cursorPrefix))
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("invalid cursor %q", cursor)
+	}
+	return offset, nil
+}
+
+// toEmployeeQuery maps the arguments of the employees query onto a store query
+func toEmployeeQuery(filter *model.EmployeeFilter, orderBy []*model.EmployeeOrder, first *int, after *string) (employees.EmployeeQuery, error) {
+	query := employees.EmployeeQuery{Limit: defaultPageSize}
+
+	if first != nil {
+		if *first < 0 || *first > maxPageSize {
+			return query, fmt.Errorf("first must be between 0 and %d", maxPageSize)
+		}
+		query.Limit = *first
+	}
+	if after != nil {
+		offset, err := decodeCursor(*after)
+		if err != nil {
+			return query, err
+		}
+		query.Offset = offset + 1
+	}
+
+	if filter != nil {
+		query.Filter = employees.EmployeeFilter{
+			Name:        deref(filter.Name),
+			Department:  deref(filter.Department),
+			Position:    deref(filter.Position),
+			EmailDomain: deref(filter.EmailDomain),
+			DOBFrom:     deref(filter.DobFrom),
+			DOBTo:       deref(filter.DobTo),
+		}
+	}
+
+	for _, o := range orderBy {
+		query.OrderBy = append(query.OrderBy, employees.EmployeeOrder{
+			Field: employees.EmployeeOrderField(o.Field),
+			Desc:  o.Direction != nil && *o.Direction == model.OrderDirectionDesc,
+		})
+	}
+	return query, nil
+}
+
+// newEmployeeConnection wraps a page of employees fetched with query into a connection
+func newEmployeeConnection(page []employees.Employee, total int, query employees.EmployeeQuery) *model.EmployeeConnection {
+	connection := &model.EmployeeConnection{
+		Edges:      make([]*model.EmployeeEdge, 0, len(page)),
+		TotalCount: total,
+		PageInfo: &model.PageInfo{
+			HasPreviousPage: query.Offset > 0,
+			HasNextPage:     query.Offset+len(page) < total,
+		},
+	}
+	for i, employee := range page {
+		connection.Edges = append(connection.Edges, &model.EmployeeEdge{
+			Cursor: encodeCursor(query.Offset + i),
+			Node:   toModelEmployee(employee),
+		})
+	}
+	if len(connection.Edges) > 0 {
+		connection.PageInfo.StartCursor = &connection.Edges[0].Cursor
+		connection.PageInfo.EndCursor = &connection.Edges[len(connection.Edges)-1].Cursor
+	}
+	return connection
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -1,18 +1,337 @@
 package graph
 
-import "github.com/pascaloseko/ems/internal/employees"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/pascaloseko/ems/graph/model"
+	"github.com/pascaloseko/ems/internal/apikeys"
+	"github.com/pascaloseko/ems/internal/auth"
+	"github.com/pascaloseko/ems/internal/employees"
+	"github.com/pascaloseko/ems/internal/loaders"
+	"github.com/pascaloseko/ems/internal/lockout"
+	"github.com/pascaloseko/ems/internal/passwordreset"
+	"github.com/pascaloseko/ems/internal/passwords"
+	"github.com/pascaloseko/ems/internal/pkg/jwt"
+	"github.com/pascaloseko/ems/internal/revocation"
+	"github.com/pascaloseko/ems/internal/sessions"
+	"github.com/pascaloseko/ems/internal/twofactor"
+)
 
 // This file will not be regenerated automatically.
 //
 // It serves as dependency injection for your app, add any dependencies you require here.
 
-type Resolver struct{
-	emp employees.Store
+var ErrAccessDenied = errors.New("access denied")
+
+// ErrChangeOwnPassword is returned when updateEmployee is used to set the
+// password of the caller, which changePassword does after checking the old one
+var ErrChangeOwnPassword = errors.New("change your own password with changePassword")
+
+// Services are the dependencies of the resolvers
+type Services struct {
+	Employees      employees.Store
+	Tokens         *jwt.Issuer
+	Sessions       *sessions.Manager
+	Denylist       *revocation.Denylist
+	PasswordResets *passwordreset.Manager
+	PasswordPolicy *passwords.Policy
+	Lockout        *lockout.Guard
+	TwoFactor      *twofactor.Manager
+	APIKeys        *apikeys.Manager
+	// OpenRegistration lets anyone sign up through registerEmployee, otherwise
+	// only HR and service accounts allowed to write employees can
+	OpenRegistration bool
 }
 
+type Resolver struct {
+	emp      employees.Store
+	tokens   *jwt.Issuer
+	sessions *sessions.Manager
+	denylist *revocation.Denylist
+	resets   *passwordreset.Manager
+	policy   *passwords.Policy
+	lockout  *lockout.Guard
+	totp     *twofactor.Manager
+	keys     *apikeys.Manager
+
+	openRegistration bool
+}
 
-func NewResolver(emp employees.Store) *Resolver {
+func NewResolver(services Services) *Resolver {
 	return &Resolver{
-		emp: emp,
+		emp:      services.Employees,
+		tokens:   services.Tokens,
+		sessions: services.Sessions,
+		denylist: services.Denylist,
+		resets:   services.PasswordResets,
+		policy:   services.PasswordPolicy,
+		lockout:  services.Lockout,
+		totp:     services.TwoFactor,
+		keys:     services.APIKeys,
+
+		openRegistration: services.OpenRegistration,
+	}
+}
+
+// loaders returns the request scoped loaders, falling back to unshared ones when
+// the request did not pass through loaders.Middleware
+func (r *Resolver) loaders(ctx context.Context) *loaders.Loaders {
+	if l := loaders.For(ctx); l != nil {
+		return l
+	}
+	return loaders.NewLoaders(r.emp)
+}
+
+// saveEmployee checks the password of a new employee against the password
+// policy, hashes it and persists the employee
+func (r *Resolver) saveEmployee(ctx context.Context, input model.NewEmployee) (employees.Employee, error) {
+	if err := r.checkPassword(input.Password, input.Username, input.Email); err != nil {
+		return employees.Employee{}, err
+	}
+	var employee employees.Employee
+	employee.FirstName = input.FirstName
+	employee.LastName = input.LastName
+	employee.Username = input.Username
+	employee.Email = input.Email
+	employee.DOB = input.Dob
+	employee.DepartmentName = input.Department
+	hashed, err := r.emp.HashPassword(ctx, input.Password)
+	if err != nil {
+		return employee, fmt.Errorf("failed to hash password: %w", err)
+	}
+	employee.Password = hashed
+	employee.Position = input.Position
+	employee.Role = employees.RoleEmployee
+
+	id, err := r.emp.Save(ctx, employee)
+	if err != nil {
+		return employee, fmt.Errorf("failed to save employee: %w", err)
+	}
+	employee.ID = id
+	return employee, nil
+}
+
+// checkPassword applies the password policy to password, which may not contain
+// any of the usernames or emails of the employee
+func (r *Resolver) checkPassword(password string, personal ...string) error {
+	return r.policy.Check(password, personal...)
+}
+
+// setPassword stores the hash of password as the password of employeeID
+func (r *Resolver) setPassword(ctx context.Context, employeeID int64, password string) error {
+	hashed, err := r.emp.HashPassword(ctx, password)
+	if err != nil {
+		return err
+	}
+	if _, err := r.emp.Update(ctx, employeeID, employees.EmployeeUpdate{Password: &hashed}); err != nil {
+		return err
+	}
+	return nil
+}
+
+// newAuthToken starts a new session for employee and issues its first token pair
+func (r *Resolver) newAuthToken(ctx context.Context, employee employees.Employee) (*model.AuthToken, error) {
+	grant, err := r.sessions.Issue(ctx, employee.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start session: %w", err)
+	}
+	accessToken, err := r.tokens.GenerateSessionToken(employee.ID, employee.Username, string(employee.Role), grant.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	return &model.AuthToken{
+		AccessToken:  &accessToken,
+		RefreshToken: &grant.RefreshToken,
+	}, nil
+}
+
+// SingleSignOn logs in the employee an identity provider vouched for, email
+// being the verified address of its ID token. Passwords, lockout and second
+// factors are left to the provider.
+func (r *Resolver) SingleSignOn(ctx context.Context, email string) (*model.AuthToken, error) {
+	employee, err := r.emp.GetEmployeeByEmail(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	return r.newAuthToken(ctx, employee)
+}
+
+// verifySecondFactor checks a TOTP or recovery code of employee. Wrong codes
+// count as failed logins so that codes cannot be guessed.
+func (r *Resolver) verifySecondFactor(ctx context.Context, employee employees.Employee, code string) error {
+	ip := sessions.ClientForContext(ctx).IPAddress
+	if err := r.lockout.Attempt(ctx, employee.Username, ip); err != nil {
+		return err
+	}
+	if err := r.totp.Verify(ctx, employee.ID, code); err != nil {
+		r.attemptFailed(ctx, employee.Username, ip, err)
+		return err
+	}
+	if err := r.lockout.Succeeded(ctx, employee.Username); err != nil {
+		log.Printf("failed to reset failed logins of %q: %v", employee.Username, err)
+	}
+	return nil
+}
+
+// attemptFailed ends a login attempt started with lockout.Attempt that failed
+// with err. Only wrong credentials and codes count as failed logins, errors such
+// as an unreachable database say nothing about the password.
+func (r *Resolver) attemptFailed(ctx context.Context, username, ip string, err error) {
+	var wrongCredentials *employees.WrongUsernameOrPasswordError
+	var invalidCode *twofactor.InvalidCodeError
+	if errors.As(err, &wrongCredentials) || errors.As(err, &invalidCode) {
+		if err := r.lockout.Failed(ctx, username, ip); err != nil {
+			log.Printf("failed to record failed login of %q: %v", username, err)
+		}
+		return
+	}
+	r.releaseAttempt(ctx, username)
+}
+
+// releaseAttempt stops counting a login attempt of username as a failed login
+func (r *Resolver) releaseAttempt(ctx context.Context, username string) {
+	if err := r.lockout.Release(ctx, username); err != nil {
+		log.Printf("failed to release login attempt of %q: %v", username, err)
 	}
-}
\ No newline at end of file
+}
+
+// endSession terminates the session with id and revokes the access tokens issued to it
+func (r *Resolver) endSession(ctx context.Context, id string) error {
+	if err := r.sessions.Terminate(ctx, id); err != nil {
+		return fmt.Errorf("failed to end session: %w", err)
+	}
+	if err := r.denylist.RevokeSession(ctx, id); err != nil {
+		return fmt.Errorf("failed to revoke session tokens: %w", err)
+	}
+	return nil
+}
+
+// signOutEverywhere ends every session of employeeID and revokes all of its tokens
+func (r *Resolver) signOutEverywhere(ctx context.Context, employeeID int64) error {
+	if err := r.sessions.RevokeAll(ctx, employeeID); err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+	if err := r.denylist.RevokeEmployee(ctx, employeeID); err != nil {
+		return fmt.Errorf("failed to revoke tokens: %w", err)
+	}
+	return nil
+}
+
+// signOutOtherSessions ends every session of employeeID but the one of the
+// request. Without a session to keep, all tokens of employeeID are revoked.
+func (r *Resolver) signOutOtherSessions(ctx context.Context, employeeID int64) error {
+	claims := auth.TokenForContext(ctx)
+	if claims == nil || claims.SessionID == "" {
+		return r.signOutEverywhere(ctx, employeeID)
+	}
+	list, err := r.sessions.Sessions(ctx, employeeID)
+	if err != nil {
+		return fmt.Errorf("failed to get sessions: %w", err)
+	}
+	for _, session := range list {
+		if session.ID == claims.SessionID {
+			continue
+		}
+		if err := r.endSession(ctx, session.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sessionsOf lists the sessions of employeeID, flagging the one of the request
+func (r *Resolver) sessionsOf(ctx context.Context, employeeID int64) ([]*model.Session, error) {
+	list, err := r.sessions.Sessions(ctx, employeeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sessions: %w", err)
+	}
+	var current string
+	if claims := auth.TokenForContext(ctx); claims != nil {
+		current = claims.SessionID
+	}
+	result := make([]*model.Session, 0, len(list))
+	for _, session := range list {
+		result = append(result, toModelSession(session, current))
+	}
+	return result, nil
+}
+
+// parseID converts a GraphQL ID into a database identifier
+func parseID(id string) (int64, error) {
+	parsed, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid id %q", id)
+	}
+	return parsed, nil
+}
+
+// toModelEmployee maps a stored employee onto its GraphQL representation
+func toModelEmployee(employee employees.Employee) *model.Employee {
+	return &model.Employee{
+		ID:           strconv.FormatInt(employee.ID, 10),
+		FirstName:    employee.FirstName,
+		LastName:     employee.LastName,
+		Username:     employee.Username,
+		Email:        employee.Email,
+		Dob:          employee.DOB,
+		DepartmentID: employee.DepartmentID,
+		Position:     employee.Position,
+		Role:         toModelRole(employee.Role),
+	}
+}
+
+// toModelSession maps a session onto its GraphQL representation
+func toModelSession(session sessions.Session, current string) *model.Session {
+	return &model.Session{
+		ID:         session.ID,
+		CreatedAt:  session.CreatedAt,
+		LastSeenAt: session.LastSeenAt,
+		ExpiresAt:  session.ExpiresAt,
+		UserAgent:  session.UserAgent,
+		IPAddress:  session.IPAddress,
+		Current:    session.ID == current,
+	}
+}
+
+// toModelServiceAccount maps a service account and its keys onto their GraphQL
+// representation
+func toModelServiceAccount(account apikeys.ServiceAccount, keys []apikeys.Key) *model.ServiceAccount {
+	result := &model.ServiceAccount{
+		ID:        strconv.FormatInt(account.ID, 10),
+		Name:      account.Name,
+		CreatedAt: account.CreatedAt,
+		APIKeys:   make([]*model.APIKey, 0, len(keys)),
+	}
+	for _, key := range keys {
+		result.APIKeys = append(result.APIKeys, toModelAPIKey(key))
+	}
+	return result
+}
+
+func toModelAPIKey(key apikeys.Key) *model.APIKey {
+	scopes := make([]model.Scope, 0, len(key.Scopes))
+	for _, scope := range key.Scopes {
+		scopes = append(scopes, toModelScope(scope))
+	}
+	return &model.APIKey{
+		ID:         key.ID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     scopes,
+		CreatedAt:  key.CreatedAt,
+		ExpiresAt:  key.ExpiresAt,
+		LastUsedAt: key.LastUsedAt,
+	}
+}
+
+// toModelDepartment maps a stored department onto its GraphQL representation
+func toModelDepartment(department employees.Department) *model.Department {
+	return &model.Department{
+		ID:   strconv.FormatInt(department.ID, 10),
+		Name: department.Name,
+	}
+}
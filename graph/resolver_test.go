@@ -0,0 +1,1239 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/golang/mock/gomock"
+	"github.com/pascaloseko/ems/graph/model"
+	"github.com/pascaloseko/ems/internal/apikeys"
+	"github.com/pascaloseko/ems/internal/auth"
+	"github.com/pascaloseko/ems/internal/employees"
+	"github.com/pascaloseko/ems/internal/loaders"
+	"github.com/pascaloseko/ems/internal/lockout"
+	"github.com/pascaloseko/ems/internal/mail"
+	"github.com/pascaloseko/ems/internal/mockdb"
+	"github.com/pascaloseko/ems/internal/passwordreset"
+	"github.com/pascaloseko/ems/internal/passwords"
+	"github.com/pascaloseko/ems/internal/pkg/jwt"
+	"github.com/pascaloseko/ems/internal/pkg/totp"
+	"github.com/pascaloseko/ems/internal/revocation"
+	"github.com/pascaloseko/ems/internal/sessions"
+	"github.com/pascaloseko/ems/internal/twofactor"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var testIssuer, _ = jwt.NewIssuer(jwt.Config{Algorithm: jwt.HS256, Secret: []byte("test-secret")})
+
+// newTestClient wires the resolvers behind the same middleware as server.go
+func newTestClient(t *testing.T, store *mockdb.MockStore) *client.Client {
+	return newTestClientWithStores(t, testStores{emp: store})
+}
+
+// testStores back a test client, the ones left nil must not be used by the test
+type testStores struct {
+	emp         employees.Store
+	sessions    sessions.Store
+	revocations revocation.Store
+	resets      passwordreset.Store
+	lockouts    lockout.Store
+	twoFactor   twofactor.Store
+	apiKeys     apikeys.Store
+	mailer      mail.Mailer
+}
+
+func newTestClientWithStores(t *testing.T, stores testStores) *client.Client {
+	store := stores.emp
+	denylist := revocation.NewDenylist(stores.revocations, testIssuer.MaxTokenAge())
+	sessionManager := sessions.NewManager(stores.sessions, 0)
+	keys := apikeys.NewManager(stores.apiKeys)
+	resolver := NewResolver(Services{
+		Employees:      store,
+		Tokens:         testIssuer,
+		Sessions:       sessionManager,
+		Denylist:       denylist,
+		PasswordResets: passwordreset.NewManager(stores.resets, stores.mailer, 0, ""),
+		PasswordPolicy: passwords.NewPolicy(10, 2),
+		Lockout:        lockout.NewGuard(stores.lockouts, lockout.Config{Threshold: 3}),
+		TwoFactor:      twofactor.NewManager(stores.twoFactor, ""),
+		APIKeys:        keys,
+	})
+	srv := handler.NewDefaultServer(NewExecutableSchema(NewConfig(resolver)))
+	srv.SetErrorPresenter(ErrorPresenter)
+	var h http.Handler = srv
+	h = loaders.Middleware(store)(h)
+	h = auth.OptionalMiddleware(store, testIssuer, denylist, sessionManager, keys)(h)
+	h = sessions.Middleware(h)
+	return client.New(h)
+}
+
+// asUser authenticates a test request as username
+func asUser(t *testing.T, store *mockdb.MockStore, username string, id int64) client.Option {
+	return asRole(t, store, username, id, employees.RoleEmployee)
+}
+
+// asRole authenticates a test request as username holding role
+func asRole(t *testing.T, store *mockdb.MockStore, username string, id int64, role employees.Role) client.Option {
+	return inSession(t, store, username, id, role, "")
+}
+
+// inSession authenticates a test request with an access token of the session sessionID
+func inSession(t *testing.T, store *mockdb.MockStore, username string, id int64, role employees.Role, sessionID string) client.Option {
+	store.EXPECT().GetEmployeeIdByUsername(gomock.Any(), username).AnyTimes().Return(id, nil)
+	token, err := testIssuer.GenerateSessionToken(id, username, string(role), sessionID)
+	require.NoError(t, err)
+	return client.AddHeader("Authorization", "Bearer "+token)
+}
+
+// lockoutAccount keeps the failed logins of username for lockoutStore the way
+// the SQL store does, and returns them for the test to look at
+func lockoutAccount(lockoutStore *mockdb.MockLockoutStore, username string) *lockout.Account {
+	account := &lockout.Account{Username: username}
+	lockoutStore.EXPECT().GetAccount(gomock.Any(), username).AnyTimes().DoAndReturn(
+		func(context.Context, string) (lockout.Account, error) {
+			return *account, nil
+		})
+	lockoutStore.EXPECT().RecordAttempt(gomock.Any(), username, gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
+		func(_ context.Context, _ string, at, since time.Time, threshold int, holdUntil time.Time) (*time.Time, error) {
+			if account.LockedUntil != nil && at.Before(*account.LockedUntil) {
+				return account.LockedUntil, nil
+			}
+			if account.LastFailedAt.Before(since) {
+				account.Failures = 0
+			}
+			account.Failures++
+			account.LastFailedAt = at
+			if account.Failures >= threshold {
+				account.LockedUntil = &holdUntil
+			}
+			return nil, nil
+		})
+	lockoutStore.EXPECT().ReleaseAttempt(gomock.Any(), username).AnyTimes().DoAndReturn(
+		func(context.Context, string) error {
+			if account.Failures > 0 {
+				account.Failures--
+				account.LockedUntil = nil
+			}
+			return nil
+		})
+	lockoutStore.EXPECT().LockAccount(gomock.Any(), username, gomock.Any()).AnyTimes().DoAndReturn(
+		func(_ context.Context, _ string, until time.Time) error {
+			account.LockedUntil = &until
+			return nil
+		})
+	lockoutStore.EXPECT().ResetAccount(gomock.Any(), username).AnyTimes().DoAndReturn(
+		func(context.Context, string) error {
+			*account = lockout.Account{Username: username}
+			return nil
+		})
+	return account
+}
+
+func TestEmployeesDepartmentsAreBatched(t *testing.T) {
+	for _, size := range []int{1, 10, 100} {
+		t.Run(fmt.Sprintf("%d employees", size), func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+
+			page := make([]employees.Employee, size)
+			for i := range page {
+				page[i] = employees.Employee{ID: int64(i + 1), Username: fmt.Sprintf("user%d", i), DepartmentID: int64(i%5 + 1)}
+			}
+			store.EXPECT().FindEmployees(gomock.Any(), gomock.Any()).Times(1).Return(page, size, nil)
+			// however many employees there are, departments are fetched in one query
+			store.EXPECT().GetDepartmentsByIDs(gomock.Any(), gomock.Any()).Times(1).DoAndReturn(
+				func(_ interface{}, ids []int64) ([]employees.Department, error) {
+					require.LessOrEqual(t, len(ids), 5)
+					departments := make([]employees.Department, 0, len(ids))
+					for _, id := range ids {
+						departments = append(departments, employees.Department{ID: id, Name: fmt.Sprintf("dept%d", id)})
+					}
+					return departments, nil
+				})
+
+			c := newTestClient(t, store)
+			var resp struct {
+				Employees struct {
+					Edges []struct {
+						Node struct {
+							Username   string
+							Department struct{ Name string }
+						}
+					}
+				}
+			}
+			c.MustPost(`{ employees(first: 100) { edges { node { username department { name } } } } }`, &resp, asUser(t, store, "pascal", 1))
+
+			require.Len(t, resp.Employees.Edges, size)
+			for i, edge := range resp.Employees.Edges {
+				require.Equal(t, fmt.Sprintf("dept%d", i%5+1), edge.Node.Department.Name)
+			}
+		})
+	}
+}
+
+func TestSensitiveFieldsAreMasked(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+
+	store.EXPECT().GetEmployeesByIDs(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
+		func(_ interface{}, ids []int64) ([]employees.Employee, error) {
+			var found []employees.Employee
+			for _, id := range ids {
+				found = append(found, employees.Employee{ID: id, Username: fmt.Sprintf("user%d", id), Email: "someone@example.com", DOB: "1990-01-01", Password: "hash"})
+			}
+			return found, nil
+		})
+
+	c := newTestClient(t, store)
+	auth := asUser(t, store, "pascal", 1)
+
+	var resp struct {
+		Employee struct {
+			Email string
+			Dob   string
+		}
+	}
+	c.MustPost(`{ employee(id: "1") { email dob } }`, &resp, auth)
+	require.Equal(t, "someone@example.com", resp.Employee.Email)
+	require.Equal(t, "1990-01-01", resp.Employee.Dob)
+
+	c.MustPost(`{ employee(id: "2") { email dob } }`, &resp, auth)
+	require.Equal(t, RedactedValue, resp.Employee.Email)
+	require.Equal(t, RedactedValue, resp.Employee.Dob)
+
+	// HR sees everyone's details
+	c.MustPost(`{ employee(id: "2") { email dob } }`, &resp, asRole(t, store, "hr", 3, employees.RoleHR))
+	require.Equal(t, "someone@example.com", resp.Employee.Email)
+
+	// the password hash is not part of the schema at all
+	err := c.Post(`{ employee(id: "1") { password } }`, &resp, auth)
+	require.Error(t, err)
+}
+
+func TestHasRole(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+	sessionStore := mockdb.NewMockSessionStore(ctrl)
+	revocationStore := mockdb.NewMockRevocationStore(ctrl)
+	c := newTestClientWithStores(t, testStores{emp: store, sessions: sessionStore, revocations: revocationStore})
+
+	var resp struct{ DeleteEmployee bool }
+
+	// anonymous callers and plain employees cannot delete
+	err := c.Post(`mutation { deleteEmployee(id: "2") }`, &resp)
+	require.ErrorContains(t, err, ErrAccessDenied.Error())
+	err = c.Post(`mutation { deleteEmployee(id: "2") }`, &resp, asUser(t, store, "pascal", 1))
+	require.ErrorContains(t, err, ErrAccessDenied.Error())
+
+	// HR and anything above can
+	store.EXPECT().GetEmployeeByID(gomock.Any(), int64(2)).Times(2).Return(employees.Employee{ID: 2, Role: employees.RoleEmployee}, nil)
+	sessionStore.EXPECT().RevokeEmployeeRefreshTokens(gomock.Any(), int64(2), gomock.Any()).Times(2).Return(nil)
+	sessionStore.EXPECT().RevokeEmployeeSessions(gomock.Any(), int64(2), gomock.Any()).Times(2).Return(nil)
+	revocationStore.EXPECT().RevokeEmployeeTokens(gomock.Any(), gomock.Any()).Times(2).Return(nil)
+	store.EXPECT().Delete(gomock.Any(), int64(2)).Times(2).Return(nil)
+	c.MustPost(`mutation { deleteEmployee(id: "2") }`, &resp, asRole(t, store, "hr", 3, employees.RoleHR))
+	require.True(t, resp.DeleteEmployee)
+	c.MustPost(`mutation { deleteEmployee(id: "2") }`, &resp, asRole(t, store, "admin", 4, employees.RoleAdmin))
+	require.True(t, resp.DeleteEmployee)
+}
+
+func TestRegisterEmployee(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+	sessionStore := mockdb.NewMockSessionStore(ctrl)
+	c := newTestClientWithStores(t, testStores{emp: store, sessions: sessionStore})
+	register := `mutation { registerEmployee(input: {firstName: "Ada", lastName: "Lovelace", username: "ada", password: "Analytical-Engine-1843", email: "ada@example.com", dob: "1815-12-10", department: "Engineering", position: "Engineer"}) { accessToken } }`
+
+	// without open registration no one can sign themselves up
+	var resp struct{ RegisterEmployee model.AuthToken }
+	err := c.Post(register, &resp)
+	require.ErrorContains(t, err, ErrAccessDenied.Error())
+	err = c.Post(register, &resp, asUser(t, store, "pascal", 1))
+	require.ErrorContains(t, err, ErrAccessDenied.Error())
+
+	// HR still can
+	store.EXPECT().HashPassword(gomock.Any(), "Analytical-Engine-1843").Times(2).Return("hashed", nil)
+	store.EXPECT().Save(gomock.Any(), gomock.Any()).Times(2).Return(int64(5), nil)
+	sessionStore.EXPECT().SaveSession(gomock.Any(), gomock.Any()).Times(2).Return(nil)
+	sessionStore.EXPECT().SaveRefreshToken(gomock.Any(), gomock.Any()).Times(2).Return(nil)
+	c.MustPost(register, &resp, asRole(t, store, "hr", 3, employees.RoleHR))
+	require.NotNil(t, resp.RegisterEmployee.AccessToken)
+
+	// and anyone can once it is opened
+	resolver := NewResolver(Services{
+		Employees:        store,
+		Tokens:           testIssuer,
+		Sessions:         sessions.NewManager(sessionStore, 0),
+		PasswordPolicy:   passwords.NewPolicy(10, 2),
+		OpenRegistration: true,
+	})
+	token, err := resolver.Mutation().RegisterEmployee(context.Background(), model.NewEmployee{
+		FirstName: "Ada", LastName: "Lovelace", Username: "ada", Password: "Analytical-Engine-1843",
+		Email: "ada@example.com", Dob: "1815-12-10", Department: "Engineering", Position: "Engineer",
+	})
+	require.NoError(t, err)
+	require.NotNil(t, token.AccessToken)
+}
+
+func TestEmployeeQueries(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+	c := newTestClient(t, store)
+	self := asUser(t, store, "pascal", 1)
+
+	store.EXPECT().GetEmployeesByIDs(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
+		func(_ interface{}, ids []int64) ([]employees.Employee, error) {
+			var found []employees.Employee
+			for _, id := range ids {
+				if id == 2 {
+					found = append(found, employees.Employee{ID: 2, Username: "jane"})
+				}
+			}
+			return found, nil
+		})
+	var byID struct{ Employee struct{ Username string } }
+	c.MustPost(`{ employee(id: "2") { username } }`, &byID, self)
+	require.Equal(t, "jane", byID.Employee.Username)
+	err := c.Post(`{ employee(id: "3") { username } }`, &byID, self)
+	require.ErrorContains(t, err, "employee not found")
+	err = c.Post(`{ employee(id: "jane") { username } }`, &byID, self)
+	require.ErrorContains(t, err, "invalid id")
+
+	store.EXPECT().GetEmployeeByUsername(gomock.Any(), "jane").Times(1).Return(employees.Employee{ID: 2, Username: "jane"}, nil)
+	store.EXPECT().GetEmployeeByUsername(gomock.Any(), "nobody").Times(1).Return(employees.Employee{}, &employees.EmployeeNotFoundError{})
+	var byUsername struct{ EmployeeByUsername struct{ ID string } }
+	c.MustPost(`{ employeeByUsername(username: "jane") { id } }`, &byUsername, self)
+	require.Equal(t, "2", byUsername.EmployeeByUsername.ID)
+	err = c.Post(`{ employeeByUsername(username: "nobody") { id } }`, &byUsername, self)
+	require.ErrorContains(t, err, "employee not found")
+
+	// me is whoever the token belongs to
+	store.EXPECT().GetEmployeeByID(gomock.Any(), int64(1)).Times(1).Return(employees.Employee{ID: 1, Username: "pascal"}, nil)
+	var me struct{ Me struct{ Username string } }
+	c.MustPost(`{ me { username } }`, &me, self)
+	require.Equal(t, "pascal", me.Me.Username)
+	err = c.Post(`{ me { username } }`, &me)
+	require.ErrorContains(t, err, ErrAccessDenied.Error())
+
+	// an account deleted since the token was issued
+	store.EXPECT().GetEmployeeByID(gomock.Any(), int64(1)).Times(1).Return(employees.Employee{}, &employees.EmployeeNotFoundError{})
+	err = c.Post(`{ me { username } }`, &me, self)
+	require.ErrorContains(t, err, "employee not found")
+}
+
+// TestEmployeesMemoryStore runs through the life of an employee on the in-memory
+// store rather than scripting every store call
+func TestEmployeesMemoryStore(t *testing.T) {
+	hasher, err := employees.NewBcryptHasher(bcrypt.MinCost)
+	require.NoError(t, err)
+	store := employees.NewMemoryStore(hasher)
+	_, err = store.Save(context.Background(), employees.Employee{Username: "hr", Email: "hr@example.com", DepartmentName: "People", Role: employees.RoleHR})
+	require.NoError(t, err)
+	token, err := testIssuer.GenerateToken(1, "hr", string(employees.RoleHR))
+	require.NoError(t, err)
+	hr := client.AddHeader("Authorization", "Bearer "+token)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	sessionStore := mockdb.NewMockSessionStore(ctrl)
+	revocationStore := mockdb.NewMockRevocationStore(ctrl)
+	c := newTestClientWithStores(t, testStores{emp: store, sessions: sessionStore, revocations: revocationStore})
+
+	// HR gets the new employee back, not a token to act as them
+	var created struct {
+		CreateEmployee struct {
+			ID         string
+			Username   string
+			Department struct{ Name string }
+		}
+	}
+	c.MustPost(`mutation { createEmployee(input: {firstName: "Ada", lastName: "Lovelace", username: "ada", password: "Analytical-Engine-1843", email: "ada@example.com", dob: "1815-12-10", department: "Engineering", position: "Engineer"}) { id username department { name } } }`, &created, hr)
+	require.NotEmpty(t, created.CreateEmployee.ID)
+	require.Equal(t, "ada", created.CreateEmployee.Username)
+	err = c.Post(`mutation { createEmployee(input: {firstName: "Ada", lastName: "Byron", username: "ada", password: "Analytical-Engine-1843", email: "byron@example.com", dob: "1815-12-10", department: "Engineering", position: "Engineer"}) { id } }`, &struct{ CreateEmployee struct{ ID string } }{}, hr)
+	require.Error(t, err, "usernames are unique")
+
+	var list struct {
+		Employees struct {
+			TotalCount int
+			Edges      []struct {
+				Node struct {
+					ID         string
+					Username   string
+					Department struct{ Name string }
+				}
+			}
+		}
+	}
+	c.MustPost(`{ employees(filter: {department: "Engineering"}) { totalCount edges { node { id username department { name } } } } }`, &list, hr)
+	require.Equal(t, 1, list.Employees.TotalCount)
+	ada := list.Employees.Edges[0].Node
+	require.Equal(t, created.CreateEmployee.ID, ada.ID)
+	require.Equal(t, "ada", ada.Username)
+	require.Equal(t, "Engineering", ada.Department.Name)
+
+	var updated struct{ UpdateEmployee struct{ Position string } }
+	c.MustPost(fmt.Sprintf(`mutation { updateEmployee(id: %q, input: {position: "Lead"}) { position } }`, ada.ID), &updated, hr)
+	require.Equal(t, "Lead", updated.UpdateEmployee.Position)
+
+	// deleting ada ends her sessions too
+	sessionStore.EXPECT().RevokeEmployeeRefreshTokens(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(nil)
+	sessionStore.EXPECT().RevokeEmployeeSessions(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(nil)
+	revocationStore.EXPECT().RevokeEmployeeTokens(gomock.Any(), gomock.Any()).Times(1).Return(nil)
+	c.MustPost(fmt.Sprintf(`mutation { deleteEmployee(id: %q) }`, ada.ID), &struct{ DeleteEmployee bool }{}, hr)
+	c.MustPost(`{ employees { totalCount edges { node { id username department { name } } } } }`, &list, hr)
+	require.Equal(t, 1, list.Employees.TotalCount, "only HR is left")
+}
+
+func TestConstraintErrorCodes(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+	c := newTestClient(t, store)
+	hr := asRole(t, store, "hr", 3, employees.RoleHR)
+
+	// the code and message say what was taken, not how the database put it
+	errorOf := func(query string) (string, string) {
+		raw, err := c.RawPost(query, hr)
+		require.NoError(t, err)
+		var errs []struct {
+			Message    string
+			Extensions struct{ Code string }
+		}
+		require.NoError(t, json.Unmarshal(raw.Errors, &errs))
+		require.Len(t, errs, 1)
+		return errs[0].Extensions.Code, errs[0].Message
+	}
+
+	store.EXPECT().HashPassword(gomock.Any(), gomock.Any()).Times(1).Return("hashed", nil)
+	store.EXPECT().Save(gomock.Any(), gomock.Any()).Times(1).Return(int64(0), fmt.Errorf("%w: UNIQUE constraint failed: employee_entities.username", employees.ErrDuplicateUsername))
+	code, message := errorOf(`mutation { createEmployee(input: {firstName: "Ada", lastName: "Lovelace", username: "ada", password: "Analytical-Engine-1843", email: "ada@example.com", dob: "1815-12-10", department: "Engineering", position: "Engineer"}) { id } }`)
+	require.Equal(t, "DUPLICATE_USERNAME", code)
+	require.Equal(t, employees.ErrDuplicateUsername.Error(), message)
+
+	store.EXPECT().GetEmployeeByID(gomock.Any(), int64(1)).Times(2).Return(employees.Employee{ID: 1, Username: "grace", Role: employees.RoleEmployee}, nil)
+	store.EXPECT().Update(gomock.Any(), int64(1), gomock.Any()).Times(1).Return(employees.Employee{}, fmt.Errorf("%w: UNIQUE constraint failed: employee_entities.email", employees.ErrDuplicateEmail))
+	code, message = errorOf(`mutation { updateEmployee(id: "1", input: {email: "grace@example.com"}) { id } }`)
+	require.Equal(t, "DUPLICATE_EMAIL", code)
+	require.Equal(t, employees.ErrDuplicateEmail.Error(), message)
+
+	store.EXPECT().Update(gomock.Any(), int64(1), gomock.Any()).Times(1).Return(employees.Employee{}, fmt.Errorf("%w: FOREIGN KEY constraint failed", employees.ErrUnknownDepartment))
+	code, _ = errorOf(`mutation { updateEmployee(id: "1", input: {department: "Research"}) { id } }`)
+	require.Equal(t, "UNKNOWN_DEPARTMENT", code)
+}
+
+func TestUpdateEmployeePermissions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+	c := newTestClient(t, store)
+	self := asUser(t, store, "pascal", 1)
+	hr := asRole(t, store, "hr", 3, employees.RoleHR)
+
+	var resp struct{ UpdateEmployee struct{ FirstName string } }
+
+	store.EXPECT().Update(gomock.Any(), int64(1), gomock.Any()).Times(1).Return(employees.Employee{ID: 1, FirstName: "Pascal", Role: employees.RoleEmployee}, nil)
+	c.MustPost(`mutation { updateEmployee(id: "1", input: {firstName: "Pascal"}) { firstName } }`, &resp, self)
+	require.Equal(t, "Pascal", resp.UpdateEmployee.FirstName)
+
+	// not someone else's profile, nor their own position or role
+	err := c.Post(`mutation { updateEmployee(id: "2", input: {firstName: "Mallory"}) { firstName } }`, &resp, self)
+	require.ErrorContains(t, err, ErrAccessDenied.Error())
+	err = c.Post(`mutation { updateEmployee(id: "1", input: {position: "CEO"}) { firstName } }`, &resp, self)
+	require.ErrorContains(t, err, ErrAccessDenied.Error())
+	err = c.Post(`mutation { updateEmployee(id: "1", input: {role: ADMIN}) { firstName } }`, &resp, self)
+	require.ErrorContains(t, err, ErrAccessDenied.Error())
+
+	// their own password only changes through changePassword, which checks the old one
+	err = c.Post(`mutation { updateEmployee(id: "1", input: {password: "Correct-Horse-9"}) { firstName } }`, &resp, self)
+	require.ErrorContains(t, err, ErrChangeOwnPassword.Error())
+	err = c.Post(`mutation { updateEmployee(id: "3", input: {password: "Correct-Horse-9"}) { firstName } }`, &resp, hr)
+	require.ErrorContains(t, err, ErrChangeOwnPassword.Error())
+
+	// HR can edit others but cannot hand out roles
+	store.EXPECT().GetEmployeeByID(gomock.Any(), int64(2)).Times(1).Return(employees.Employee{ID: 2, Username: "jane", Role: employees.RoleEmployee}, nil)
+	store.EXPECT().Update(gomock.Any(), int64(2), gomock.Any()).Times(1).Return(employees.Employee{ID: 2, FirstName: "Jane", Role: employees.RoleEmployee}, nil)
+	c.MustPost(`mutation { updateEmployee(id: "2", input: {position: "Lead"}) { firstName } }`, &resp, hr)
+	err = c.Post(`mutation { updateEmployee(id: "2", input: {role: HR}) { firstName } }`, &resp, hr)
+	require.ErrorContains(t, err, ErrAccessDenied.Error())
+
+	// nor touch the accounts of admins, which would let them take one over
+	store.EXPECT().GetEmployeeByID(gomock.Any(), int64(4)).Times(3).Return(employees.Employee{ID: 4, Username: "admin", Email: "admin@example.com", Role: employees.RoleAdmin}, nil)
+	for _, input := range []string{`{password: "Correct-Horse-9"}`, `{email: "mallory@example.com"}`, `{username: "mallory"}`} {
+		err = c.Post(`mutation { updateEmployee(id: "4", input: `+input+`) { firstName } }`, &resp, hr)
+		require.ErrorContains(t, err, ErrAccessDenied.Error())
+	}
+}
+
+func TestUpdateEmployee(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+	sessionStore := mockdb.NewMockSessionStore(ctrl)
+	revocationStore := mockdb.NewMockRevocationStore(ctrl)
+	c := newTestClientWithStores(t, testStores{emp: store, sessions: sessionStore, revocations: revocationStore})
+	hr := asRole(t, store, "hr", 3, employees.RoleHR)
+
+	var resp struct {
+		UpdateEmployee struct {
+			FirstName  string
+			Position   string
+			Department struct{ Name string }
+		}
+	}
+
+	// only the fields given are passed on, the department by its name
+	store.EXPECT().GetEmployeeByID(gomock.Any(), int64(2)).AnyTimes().Return(employees.Employee{ID: 2, Username: "jane", Email: "jane@example.com", Role: employees.RoleEmployee}, nil)
+	store.EXPECT().Update(gomock.Any(), int64(2), gomock.Any()).Times(1).DoAndReturn(
+		func(_ context.Context, id int64, upd employees.EmployeeUpdate) (employees.Employee, error) {
+			position, department := "Lead", "Research"
+			require.Equal(t, employees.EmployeeUpdate{Position: &position, DepartmentName: &department}, upd)
+			return employees.Employee{ID: 2, FirstName: "Jane", Position: position, DepartmentID: 7}, nil
+		})
+	store.EXPECT().GetDepartmentsByIDs(gomock.Any(), []int64{7}).Times(1).Return([]employees.Department{{ID: 7, Name: "Research"}}, nil)
+	c.MustPost(`mutation { updateEmployee(id: "2", input: {position: "Lead", department: "Research"}) { firstName position department { name } } }`, &resp, hr)
+	require.Equal(t, "Jane", resp.UpdateEmployee.FirstName)
+	require.Equal(t, "Lead", resp.UpdateEmployee.Position)
+	require.Equal(t, "Research", resp.UpdateEmployee.Department.Name)
+
+	// a new password is stored hashed and signs the employee out everywhere
+	store.EXPECT().HashPassword(gomock.Any(), "Correct-Horse-9").Times(1).Return("hashed", nil)
+	store.EXPECT().Update(gomock.Any(), int64(2), gomock.Any()).Times(1).DoAndReturn(
+		func(_ context.Context, id int64, upd employees.EmployeeUpdate) (employees.Employee, error) {
+			require.Equal(t, "hashed", *upd.Password)
+			require.Nil(t, upd.FirstName)
+			return employees.Employee{ID: 2, FirstName: "Jane"}, nil
+		})
+	sessionStore.EXPECT().RevokeEmployeeRefreshTokens(gomock.Any(), int64(2), gomock.Any()).Times(1).Return(nil)
+	sessionStore.EXPECT().RevokeEmployeeSessions(gomock.Any(), int64(2), gomock.Any()).Times(1).Return(nil)
+	revocationStore.EXPECT().RevokeEmployeeTokens(gomock.Any(), gomock.Any()).Times(1).DoAndReturn(
+		func(_ context.Context, revocation revocation.EmployeeRevocation) error {
+			require.Equal(t, int64(2), revocation.EmployeeID)
+			return nil
+		})
+	c.MustPost(`mutation { updateEmployee(id: "2", input: {password: "Correct-Horse-9"}) { firstName } }`, &resp, hr)
+
+	// so does a new username, tokens naming the old one must not outlive it
+	store.EXPECT().Update(gomock.Any(), int64(2), gomock.Any()).Times(2).Return(employees.Employee{ID: 2, FirstName: "Jane"}, nil)
+	sessionStore.EXPECT().RevokeEmployeeRefreshTokens(gomock.Any(), int64(2), gomock.Any()).Times(1).Return(nil)
+	sessionStore.EXPECT().RevokeEmployeeSessions(gomock.Any(), int64(2), gomock.Any()).Times(1).Return(nil)
+	revocationStore.EXPECT().RevokeEmployeeTokens(gomock.Any(), gomock.Any()).Times(1).Return(nil)
+	c.MustPost(`mutation { updateEmployee(id: "2", input: {username: "jane.doe"}) { firstName } }`, &resp, hr)
+	// while sending the current one again changes nothing
+	c.MustPost(`mutation { updateEmployee(id: "2", input: {username: "jane"}) { firstName } }`, &resp, hr)
+
+	// the password policy applies to updates too
+	err := c.Post(`mutation { updateEmployee(id: "2", input: {password: "short"}) { firstName } }`, &resp, hr)
+	require.ErrorContains(t, err, "password")
+
+	store.EXPECT().GetEmployeeByID(gomock.Any(), int64(42)).Times(1).Return(employees.Employee{}, &employees.EmployeeNotFoundError{})
+	err = c.Post(`mutation { updateEmployee(id: "42", input: {position: "Lead"}) { firstName } }`, &resp, hr)
+	require.ErrorContains(t, err, "employee not found")
+}
+
+func TestDeleteEmployee(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+	sessionStore := mockdb.NewMockSessionStore(ctrl)
+	revocationStore := mockdb.NewMockRevocationStore(ctrl)
+	c := newTestClientWithStores(t, testStores{emp: store, sessions: sessionStore, revocations: revocationStore})
+	hr := asRole(t, store, "hr", 3, employees.RoleHR)
+
+	// the employee is signed out everywhere before it goes, so that its tokens
+	// cannot pass for whoever takes the username next
+	var resp struct{ DeleteEmployee bool }
+	gomock.InOrder(
+		store.EXPECT().GetEmployeeByID(gomock.Any(), int64(2)).Times(1).Return(employees.Employee{ID: 2, Role: employees.RoleEmployee}, nil),
+		sessionStore.EXPECT().RevokeEmployeeRefreshTokens(gomock.Any(), int64(2), gomock.Any()).Times(1).Return(nil),
+		sessionStore.EXPECT().RevokeEmployeeSessions(gomock.Any(), int64(2), gomock.Any()).Times(1).Return(nil),
+		revocationStore.EXPECT().RevokeEmployeeTokens(gomock.Any(), gomock.Any()).Times(1).Return(nil),
+		store.EXPECT().Delete(gomock.Any(), int64(2)).Times(1).Return(nil),
+	)
+	c.MustPost(`mutation { deleteEmployee(id: "2") }`, &resp, hr)
+	require.True(t, resp.DeleteEmployee)
+
+	// HR cannot delete admins
+	store.EXPECT().GetEmployeeByID(gomock.Any(), int64(4)).Times(1).Return(employees.Employee{ID: 4, Role: employees.RoleAdmin}, nil)
+	err := c.Post(`mutation { deleteEmployee(id: "4") }`, &resp, hr)
+	require.ErrorContains(t, err, ErrAccessDenied.Error())
+
+	store.EXPECT().GetEmployeeByID(gomock.Any(), int64(42)).Times(1).Return(employees.Employee{}, &employees.EmployeeNotFoundError{})
+	err = c.Post(`mutation { deleteEmployee(id: "42") }`, &resp, hr)
+	require.ErrorContains(t, err, "employee not found")
+	err = c.Post(`mutation { deleteEmployee(id: "jane") }`, &resp, hr)
+	require.ErrorContains(t, err, "invalid id")
+}
+
+func TestDepartments(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+	c := newTestClient(t, store)
+	self := asUser(t, store, "pascal", 1)
+	hr := asRole(t, store, "hr", 3, employees.RoleHR)
+
+	store.EXPECT().GetDepartments(gomock.Any()).Times(1).Return([]employees.Department{{ID: 1, Name: "Engineering"}, {ID: 2, Name: "Research"}}, nil)
+	var list struct{ Departments []struct{ ID, Name string } }
+	c.MustPost(`{ departments { id name } }`, &list, self)
+	require.Len(t, list.Departments, 2)
+	require.Equal(t, "Research", list.Departments[1].Name)
+
+	store.EXPECT().GetDepartmentByID(gomock.Any(), int64(2)).Times(1).Return(employees.Department{ID: 2, Name: "Research"}, nil)
+	store.EXPECT().GetDepartmentByID(gomock.Any(), int64(42)).Times(1).Return(employees.Department{}, &employees.DepartmentNotFoundError{})
+	var one struct{ Department struct{ Name string } }
+	c.MustPost(`{ department(id: "2") { name } }`, &one, self)
+	require.Equal(t, "Research", one.Department.Name)
+	err := c.Post(`{ department(id: "42") { name } }`, &one, self)
+	require.ErrorContains(t, err, "department not found")
+
+	// only HR changes departments
+	var created struct{ CreateDepartment struct{ ID, Name string } }
+	err = c.Post(`mutation { createDepartment(name: "Sales") { id } }`, &created, self)
+	require.ErrorContains(t, err, ErrAccessDenied.Error())
+
+	store.EXPECT().GetDepartmentIdByName(gomock.Any(), "Sales").Times(1).Return(int64(0), nil)
+	store.EXPECT().SaveDepartment(gomock.Any(), employees.Department{Name: "Sales"}).Times(1).Return(int64(3), nil)
+	c.MustPost(`mutation { createDepartment(name: "Sales") { id name } }`, &created, hr)
+	require.Equal(t, "3", created.CreateDepartment.ID)
+	require.Equal(t, "Sales", created.CreateDepartment.Name)
+	store.EXPECT().GetDepartmentIdByName(gomock.Any(), "Research").Times(1).Return(int64(2), nil)
+	err = c.Post(`mutation { createDepartment(name: "Research") { id } }`, &created, hr)
+	require.ErrorContains(t, err, `department \"Research\" already exists`)
+
+	store.EXPECT().RenameDepartment(gomock.Any(), int64(2), "R&D").Times(1).Return(employees.Department{ID: 2, Name: "R&D"}, nil)
+	var renamed struct{ RenameDepartment struct{ Name string } }
+	c.MustPost(`mutation { renameDepartment(id: "2", name: "R&D") { name } }`, &renamed, hr)
+	require.Equal(t, "R&D", renamed.RenameDepartment.Name)
+	store.EXPECT().RenameDepartment(gomock.Any(), int64(2), "Engineering").Times(1).Return(employees.Department{}, &employees.DepartmentExistsError{Name: "Engineering"})
+	err = c.Post(`mutation { renameDepartment(id: "2", name: "Engineering") { name } }`, &renamed, hr)
+	require.ErrorContains(t, err, "already exists")
+
+	// departments with employees cannot be deleted
+	var deleted struct{ DeleteDepartment bool }
+	store.EXPECT().DeleteDepartment(gomock.Any(), int64(1)).Times(1).Return(&employees.DepartmentInUseError{Employees: 4})
+	err = c.Post(`mutation { deleteDepartment(id: "1") }`, &deleted, hr)
+	require.ErrorContains(t, err, "department still has 4 employee(s)")
+	store.EXPECT().DeleteDepartment(gomock.Any(), int64(3)).Times(1).Return(nil)
+	c.MustPost(`mutation { deleteDepartment(id: "3") }`, &deleted, hr)
+	require.True(t, deleted.DeleteDepartment)
+}
+
+func TestLoginAndRefreshToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+	sessionStore := mockdb.NewMockSessionStore(ctrl)
+	lockoutStore := mockdb.NewMockLockoutStore(ctrl)
+	twoFactorStore := mockdb.NewMockTwoFactorStore(ctrl)
+	c := newTestClientWithStores(t, testStores{emp: store, sessions: sessionStore, lockouts: lockoutStore, twoFactor: twoFactorStore})
+	twoFactorStore.EXPECT().GetEnrollment(gomock.Any(), int64(1)).Times(1).Return(twofactor.Enrollment{}, &twofactor.NotEnrolledError{})
+	lockoutAccount(lockoutStore, "pascal")
+
+	// remember what was saved so that the refresh token can be looked up again
+	saved := map[string]sessions.RefreshToken{}
+	sessionStore.EXPECT().SaveRefreshToken(gomock.Any(), gomock.Any()).Times(2).DoAndReturn(
+		func(_ context.Context, token sessions.RefreshToken) error {
+			saved[token.TokenHash] = token
+			return nil
+		})
+	sessionStore.EXPECT().GetRefreshTokenByHash(gomock.Any(), gomock.Any()).Times(1).DoAndReturn(
+		func(_ context.Context, hash string) (sessions.RefreshToken, error) {
+			return saved[hash], nil
+		})
+	sessionStore.EXPECT().MarkRefreshTokenRotated(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(true, nil)
+	var session sessions.Session
+	sessionStore.EXPECT().SaveSession(gomock.Any(), gomock.Any()).Times(1).DoAndReturn(
+		func(_ context.Context, s sessions.Session) error {
+			session = s
+			return nil
+		})
+	sessionStore.EXPECT().ExtendSession(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(nil)
+	sessionStore.EXPECT().TouchSession(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(nil)
+
+	store.EXPECT().Authenticate(gomock.Any(), gomock.Any()).Times(1).Return(nil)
+	store.EXPECT().GetEmployeeByUsername(gomock.Any(), "pascal").Times(1).Return(employees.Employee{ID: 1, Username: "pascal", Role: employees.RoleEmployee}, nil)
+	var login struct{ Login model.AuthToken }
+	c.MustPost(`mutation { login(input: {username: "pascal", password: "secret"}) { accessToken refreshToken } }`, &login, client.AddHeader("User-Agent", "test-agent"))
+	require.NotEmpty(t, login.Login.AccessToken)
+	require.NotEmpty(t, login.Login.RefreshToken)
+	require.Equal(t, "test-agent", session.UserAgent)
+	require.Equal(t, int64(1), session.EmployeeID)
+
+	// the role is read again when refreshing
+	store.EXPECT().GetEmployeeByID(gomock.Any(), int64(1)).Times(1).Return(employees.Employee{ID: 1, Username: "pascal", Role: employees.RoleHR}, nil)
+	var refresh struct{ RefreshToken model.AuthToken }
+	c.MustPost(`mutation($token: String!) { refreshToken(input: {token: $token}) { accessToken refreshToken } }`, &refresh, client.Var("token", *login.Login.RefreshToken))
+	require.NotEqual(t, *login.Login.RefreshToken, *refresh.RefreshToken.RefreshToken)
+
+	claims, err := testIssuer.ParseToken(*refresh.RefreshToken.AccessToken)
+	require.NoError(t, err)
+	require.Equal(t, string(employees.RoleHR), claims.Role)
+	require.Equal(t, session.ID, claims.SessionID)
+}
+
+func TestLogout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+	sessionStore := mockdb.NewMockSessionStore(ctrl)
+	revocationStore := mockdb.NewMockRevocationStore(ctrl)
+	c := newTestClientWithStores(t, testStores{emp: store, sessions: sessionStore, revocations: revocationStore})
+	self := asUser(t, store, "pascal", 1)
+
+	sessionStore.EXPECT().GetRefreshTokenByHash(gomock.Any(), gomock.Any()).Times(1).Return(sessions.RefreshToken{ID: "token-1", FamilyID: "family-1", EmployeeID: 1}, nil)
+	sessionStore.EXPECT().RevokeRefreshTokenFamily(gomock.Any(), "family-1", gomock.Any()).Times(1).Return(nil)
+	sessionStore.EXPECT().RevokeSession(gomock.Any(), "family-1", gomock.Any()).Times(1).Return(nil)
+	// the session of the refresh token and the access token itself
+	revocationStore.EXPECT().RevokeToken(gomock.Any(), gomock.Any()).Times(2).Return(nil)
+
+	var resp struct{ Logout bool }
+	c.MustPost(`mutation { logout(refreshToken: "refresh") }`, &resp, self)
+	require.True(t, resp.Logout)
+
+	// the access token used to log out no longer works
+	var me struct{ Me struct{ Username string } }
+	err := c.Post(`{ me { username } }`, &me, self)
+	require.ErrorContains(t, err, "http 403")
+}
+
+func TestRevokeAllSessions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+	sessionStore := mockdb.NewMockSessionStore(ctrl)
+	revocationStore := mockdb.NewMockRevocationStore(ctrl)
+	c := newTestClientWithStores(t, testStores{emp: store, sessions: sessionStore, revocations: revocationStore})
+	self := asUser(t, store, "pascal", 1)
+	hr := asRole(t, store, "hr", 3, employees.RoleHR)
+
+	var resp struct{ RevokeAllSessions bool }
+	err := c.Post(`mutation { revokeAllSessions(employeeID: "2") }`, &resp, self)
+	require.ErrorContains(t, err, ErrAccessDenied.Error())
+
+	sessionStore.EXPECT().RevokeEmployeeRefreshTokens(gomock.Any(), int64(1), gomock.Any()).Times(1).Return(nil)
+	sessionStore.EXPECT().RevokeEmployeeSessions(gomock.Any(), int64(1), gomock.Any()).Times(1).Return(nil)
+	revocationStore.EXPECT().RevokeEmployeeTokens(gomock.Any(), gomock.Any()).Times(1).DoAndReturn(
+		func(_ context.Context, revocation revocation.EmployeeRevocation) error {
+			require.Equal(t, int64(1), revocation.EmployeeID)
+			return nil
+		})
+	c.MustPost(`mutation { revokeAllSessions(employeeID: "1") }`, &resp, hr)
+	require.True(t, resp.RevokeAllSessions)
+
+	// tokens issued before the revocation are rejected, HR's own token still works
+	var me struct{ Me struct{ Username string } }
+	err = c.Post(`{ me { username } }`, &me, self)
+	require.ErrorContains(t, err, "http 403")
+	store.EXPECT().GetEmployeeByID(gomock.Any(), int64(3)).Times(1).Return(employees.Employee{ID: 3, Username: "hr", Role: employees.RoleHR}, nil)
+	c.MustPost(`{ me { username } }`, &me, hr)
+}
+
+// TestLoginAfterRevokeAllSessions logs in again within the same second as the
+// revocation, which must only reject the tokens issued before it
+func TestLoginAfterRevokeAllSessions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	hasher, err := employees.NewBcryptHasher(bcrypt.MinCost)
+	require.NoError(t, err)
+	store := employees.NewMemoryStore(hasher)
+	hash, err := store.HashPassword(context.Background(), "Correct-Horse-9")
+	require.NoError(t, err)
+	id, err := store.Save(context.Background(), employees.Employee{Username: "pascal", Email: "pascal@example.com", Password: hash, DepartmentName: "Engineering"})
+	require.NoError(t, err)
+
+	sessionStore := mockdb.NewMockSessionStore(ctrl)
+	revocationStore := mockdb.NewMockRevocationStore(ctrl)
+	lockoutStore := mockdb.NewMockLockoutStore(ctrl)
+	twoFactorStore := mockdb.NewMockTwoFactorStore(ctrl)
+	c := newTestClientWithStores(t, testStores{emp: store, sessions: sessionStore, revocations: revocationStore, lockouts: lockoutStore, twoFactor: twoFactorStore})
+	lockoutAccount(lockoutStore, "pascal")
+	twoFactorStore.EXPECT().GetEnrollment(gomock.Any(), id).AnyTimes().Return(twofactor.Enrollment{}, &twofactor.NotEnrolledError{})
+	sessionStore.EXPECT().SaveSession(gomock.Any(), gomock.Any()).Times(2).Return(nil)
+	sessionStore.EXPECT().SaveRefreshToken(gomock.Any(), gomock.Any()).Times(2).Return(nil)
+	sessionStore.EXPECT().TouchSession(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().Return(nil)
+	sessionStore.EXPECT().RevokeEmployeeRefreshTokens(gomock.Any(), id, gomock.Any()).Times(1).Return(nil)
+	sessionStore.EXPECT().RevokeEmployeeSessions(gomock.Any(), id, gomock.Any()).Times(1).Return(nil)
+	revocationStore.EXPECT().RevokeEmployeeTokens(gomock.Any(), gomock.Any()).Times(1).Return(nil)
+
+	login := func() client.Option {
+		var resp struct{ Login model.AuthToken }
+		c.MustPost(`mutation { login(input: {username: "pascal", password: "Correct-Horse-9"}) { accessToken } }`, &resp)
+		return client.AddHeader("Authorization", "Bearer "+*resp.Login.AccessToken)
+	}
+	before := login()
+	c.MustPost(fmt.Sprintf(`mutation { revokeAllSessions(employeeID: "%d") }`, id), &struct{ RevokeAllSessions bool }{}, before)
+	after := login()
+
+	var me struct{ Me struct{ Username string } }
+	c.MustPost(`{ me { username } }`, &me, after)
+	require.Equal(t, "pascal", me.Me.Username)
+	err = c.Post(`{ me { username } }`, &me, before)
+	require.ErrorContains(t, err, "http 403")
+}
+
+func TestSessions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+	sessionStore := mockdb.NewMockSessionStore(ctrl)
+	revocationStore := mockdb.NewMockRevocationStore(ctrl)
+	c := newTestClientWithStores(t, testStores{emp: store, sessions: sessionStore, revocations: revocationStore})
+	laptop := inSession(t, store, "pascal", 1, employees.RoleEmployee, "laptop")
+	admin := inSession(t, store, "admin", 4, employees.RoleAdmin, "admin-session")
+
+	// using a session records when and from where
+	sessionStore.EXPECT().TouchSession(gomock.Any(), "laptop", gomock.Any(), gomock.Any()).Times(1).Return(nil)
+	sessionStore.EXPECT().TouchSession(gomock.Any(), "admin-session", gomock.Any(), gomock.Any()).Times(1).Return(nil)
+
+	now := time.Now()
+	list := []sessions.Session{
+		{ID: "laptop", EmployeeID: 1, CreatedAt: now, LastSeenAt: now, UserAgent: "laptop-agent"},
+		{ID: "phone", EmployeeID: 1, CreatedAt: now, LastSeenAt: now, UserAgent: "phone-agent"},
+	}
+	sessionStore.EXPECT().GetEmployeeSessions(gomock.Any(), int64(1), gomock.Any()).Times(2).Return(list, nil)
+
+	var mine struct {
+		MySessions []struct {
+			ID      string
+			Current bool
+		}
+	}
+	c.MustPost(`{ mySessions { id current } }`, &mine, laptop)
+	require.Len(t, mine.MySessions, 2)
+	require.True(t, mine.MySessions[0].Current)
+	require.False(t, mine.MySessions[1].Current)
+
+	// only admins can look at other people's sessions
+	var theirs struct{ EmployeeSessions []struct{ UserAgent string } }
+	err := c.Post(`{ employeeSessions(id: "1") { userAgent } }`, &theirs, laptop)
+	require.ErrorContains(t, err, ErrAccessDenied.Error())
+	c.MustPost(`{ employeeSessions(id: "1") { userAgent } }`, &theirs, admin)
+	require.Len(t, theirs.EmployeeSessions, 2)
+
+	// an employee cannot end somebody else's session
+	sessionStore.EXPECT().GetSession(gomock.Any(), "admin-session").Times(1).Return(sessions.Session{ID: "admin-session", EmployeeID: 4}, nil)
+	var resp struct{ TerminateSession bool }
+	err = c.Post(`mutation { terminateSession(id: "admin-session") }`, &resp, laptop)
+	require.ErrorContains(t, err, "session not found")
+
+	// ending the phone session does not affect the laptop
+	sessionStore.EXPECT().GetSession(gomock.Any(), "phone").Times(1).Return(list[1], nil)
+	sessionStore.EXPECT().RevokeRefreshTokenFamily(gomock.Any(), "phone", gomock.Any()).Times(1).Return(nil)
+	sessionStore.EXPECT().RevokeSession(gomock.Any(), "phone", gomock.Any()).Times(1).Return(nil)
+	revocationStore.EXPECT().RevokeToken(gomock.Any(), gomock.Any()).Times(1).DoAndReturn(
+		func(_ context.Context, token revocation.RevokedToken) error {
+			require.Equal(t, "phone", token.ID)
+			return nil
+		})
+	c.MustPost(`mutation { terminateSession(id: "phone") }`, &resp, laptop)
+	require.True(t, resp.TerminateSession)
+
+	phone := inSession(t, store, "pascal", 1, employees.RoleEmployee, "phone")
+	var me struct{ Me struct{ Username string } }
+	err = c.Post(`{ me { username } }`, &me, phone)
+	require.ErrorContains(t, err, "http 403")
+	store.EXPECT().GetEmployeeByID(gomock.Any(), int64(1)).Times(1).Return(employees.Employee{ID: 1, Username: "pascal"}, nil)
+	c.MustPost(`{ me { username } }`, &me, laptop)
+}
+
+func TestPasswordReset(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+	sessionStore := mockdb.NewMockSessionStore(ctrl)
+	revocationStore := mockdb.NewMockRevocationStore(ctrl)
+	resetStore := mockdb.NewMockResetStore(ctrl)
+	var outbox bytes.Buffer
+	c := newTestClientWithStores(t, testStores{
+		emp:         store,
+		sessions:    sessionStore,
+		revocations: revocationStore,
+		resets:      resetStore,
+		mailer:      mail.NewLogMailer(&outbox, "ems@example.com"),
+	})
+
+	// unknown addresses look the same as known ones
+	store.EXPECT().GetEmployeeByEmail(gomock.Any(), "nobody@example.com").Times(1).Return(employees.Employee{}, &employees.EmployeeNotFoundError{})
+	var requested struct{ RequestPasswordReset bool }
+	c.MustPost(`mutation { requestPasswordReset(email: "nobody@example.com") }`, &requested)
+	require.True(t, requested.RequestPasswordReset)
+	require.Zero(t, outbox.Len())
+
+	var saved passwordreset.Token
+	store.EXPECT().GetEmployeeByEmail(gomock.Any(), "pascal@example.com").Times(1).Return(employees.Employee{ID: 1, Username: "pascal", Email: "pascal@example.com"}, nil)
+	resetStore.EXPECT().InvalidateResetTokens(gomock.Any(), int64(1), gomock.Any()).Times(1).Return(nil)
+	resetStore.EXPECT().SaveResetToken(gomock.Any(), gomock.Any()).Times(1).DoAndReturn(
+		func(_ context.Context, token passwordreset.Token) error {
+			saved = token
+			return nil
+		})
+	c.MustPost(`mutation { requestPasswordReset(email: "pascal@example.com") }`, &requested)
+	require.Contains(t, outbox.String(), "To: pascal@example.com")
+
+	token := regexp.MustCompile(`(?m)^[A-Za-z0-9_-]{43}\r$`).FindString(outbox.String())
+	token = strings.TrimSpace(token)
+	require.NotEmpty(t, token)
+	require.NotContains(t, saved.TokenHash, token)
+
+	pascal := employees.Employee{ID: 1, Username: "pascal", Email: "pascal@example.com"}
+	store.EXPECT().GetEmployeeByID(gomock.Any(), int64(1)).AnyTimes().Return(pascal, nil)
+
+	// a password rejected by the policy leaves the token usable
+	resetStore.EXPECT().GetResetTokenByHash(gomock.Any(), saved.TokenHash).Times(1).Return(saved, nil)
+	var reset struct{ ResetPassword bool }
+	err := c.Post(`mutation($token: String!) { resetPassword(token: $token, newPassword: "pascal-1234") }`, &reset, client.Var("token", token))
+	require.ErrorContains(t, err, "must not contain your username or email")
+
+	resetStore.EXPECT().GetResetTokenByHash(gomock.Any(), saved.TokenHash).Times(2).Return(saved, nil)
+	resetStore.EXPECT().MarkResetTokenUsed(gomock.Any(), saved.ID, gomock.Any()).Times(1).Return(true, nil)
+	store.EXPECT().HashPassword(gomock.Any(), "new-password").Times(1).Return("hashed", nil)
+	store.EXPECT().Update(gomock.Any(), int64(1), gomock.Any()).Times(1).DoAndReturn(
+		func(_ context.Context, id int64, upd employees.EmployeeUpdate) (employees.Employee, error) {
+			require.Equal(t, "hashed", *upd.Password)
+			return employees.Employee{ID: 1}, nil
+		})
+	sessionStore.EXPECT().RevokeEmployeeRefreshTokens(gomock.Any(), int64(1), gomock.Any()).Times(1).Return(nil)
+	sessionStore.EXPECT().RevokeEmployeeSessions(gomock.Any(), int64(1), gomock.Any()).Times(1).Return(nil)
+	revocationStore.EXPECT().RevokeEmployeeTokens(gomock.Any(), gomock.Any()).Times(1).Return(nil)
+
+	c.MustPost(`mutation($token: String!) { resetPassword(token: $token, newPassword: "new-password") }`, &reset, client.Var("token", token))
+	require.True(t, reset.ResetPassword)
+
+	// the token cannot be used twice
+	used := time.Now()
+	saved.UsedAt = &used
+	resetStore.EXPECT().GetResetTokenByHash(gomock.Any(), saved.TokenHash).Times(1).Return(saved, nil)
+	err = c.Post(`mutation($token: String!) { resetPassword(token: $token, newPassword: "again") }`, &reset, client.Var("token", token))
+	require.ErrorContains(t, err, "invalid or expired password reset token")
+}
+
+func TestChangePassword(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+	sessionStore := mockdb.NewMockSessionStore(ctrl)
+	revocationStore := mockdb.NewMockRevocationStore(ctrl)
+	lockoutStore := mockdb.NewMockLockoutStore(ctrl)
+	c := newTestClientWithStores(t, testStores{emp: store, sessions: sessionStore, revocations: revocationStore, lockouts: lockoutStore})
+	auth := inSession(t, store, "pascal", 1, employees.RoleEmployee, "laptop")
+	store.EXPECT().GetEmployeeByID(gomock.Any(), int64(1)).AnyTimes().Return(employees.Employee{ID: 1, Username: "pascal", Email: "pascal@example.com"}, nil)
+	sessionStore.EXPECT().TouchSession(gomock.Any(), "laptop", gomock.Any(), gomock.Any()).AnyTimes().Return(nil)
+	account := lockoutAccount(lockoutStore, "pascal")
+
+	var resp struct{ ChangePassword bool }
+	store.EXPECT().Authenticate(gomock.Any(), employees.Employee{Username: "pascal", Password: "wrong"}).Times(1).Return(&employees.WrongUsernameOrPasswordError{})
+	err := c.Post(`mutation { changePassword(oldPassword: "wrong", newPassword: "Correct-Horse-9") }`, &resp, auth)
+	require.ErrorContains(t, err, "current password is incorrect")
+	require.Equal(t, 1, account.Failures)
+
+	// every broken rule is listed in the error extensions
+	store.EXPECT().Authenticate(gomock.Any(), gomock.Any()).AnyTimes().Return(nil)
+	raw, err := c.RawPost(`mutation { changePassword(oldPassword: "old", newPassword: "pascal") }`, auth)
+	require.NoError(t, err)
+	var errs []struct {
+		Extensions struct {
+			Code       string
+			Violations []passwords.Violation
+		}
+	}
+	require.NoError(t, json.Unmarshal(raw.Errors, &errs))
+	require.Len(t, errs, 1)
+	require.Equal(t, "PASSWORD_POLICY", errs[0].Extensions.Code)
+	var rules []string
+	for _, v := range errs[0].Extensions.Violations {
+		rules = append(rules, v.Rule)
+	}
+	require.Equal(t, []string{passwords.RuleMinLength, passwords.RuleCharacterClasses, passwords.RulePersonalInfo}, rules)
+
+	store.EXPECT().HashPassword(gomock.Any(), "Correct-Horse-9").Times(1).Return("hashed", nil)
+	store.EXPECT().Update(gomock.Any(), int64(1), gomock.Any()).Times(1).DoAndReturn(
+		func(_ context.Context, id int64, upd employees.EmployeeUpdate) (employees.Employee, error) {
+			require.Equal(t, "hashed", *upd.Password)
+			return employees.Employee{ID: 1}, nil
+		})
+	// the other sessions end, the one changing the password stays
+	now := time.Now()
+	sessionStore.EXPECT().GetEmployeeSessions(gomock.Any(), int64(1), gomock.Any()).Times(1).Return([]sessions.Session{
+		{ID: "laptop", EmployeeID: 1, CreatedAt: now, LastSeenAt: now},
+		{ID: "phone", EmployeeID: 1, CreatedAt: now, LastSeenAt: now},
+	}, nil)
+	sessionStore.EXPECT().RevokeRefreshTokenFamily(gomock.Any(), "phone", gomock.Any()).Times(1).Return(nil)
+	sessionStore.EXPECT().RevokeSession(gomock.Any(), "phone", gomock.Any()).Times(1).Return(nil)
+	revocationStore.EXPECT().RevokeToken(gomock.Any(), gomock.Any()).Times(1).DoAndReturn(
+		func(_ context.Context, token revocation.RevokedToken) error {
+			require.Equal(t, "phone", token.ID)
+			return nil
+		})
+	c.MustPost(`mutation { changePassword(oldPassword: "old", newPassword: "Correct-Horse-9") }`, &resp, auth)
+	require.True(t, resp.ChangePassword)
+	// the right password is no failed login, nor does it forget the wrong one
+	require.Equal(t, 1, account.Failures)
+
+	phone := inSession(t, store, "pascal", 1, employees.RoleEmployee, "phone")
+	var me struct{ Me struct{ Username string } }
+	err = c.Post(`{ me { username } }`, &me, phone)
+	require.ErrorContains(t, err, "http 403")
+	c.MustPost(`{ me { username } }`, &me, auth)
+}
+
+// TestChangePasswordLockout guesses the current password with an access token
+func TestChangePasswordLockout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+	lockoutStore := mockdb.NewMockLockoutStore(ctrl)
+	c := newTestClientWithStores(t, testStores{emp: store, lockouts: lockoutStore})
+	auth := asUser(t, store, "pascal", 1)
+
+	lockoutAccount(lockoutStore, "pascal")
+	store.EXPECT().Authenticate(gomock.Any(), gomock.Any()).Times(3).Return(&employees.WrongUsernameOrPasswordError{})
+
+	change := `mutation { changePassword(oldPassword: "guess", newPassword: "Correct-Horse-9") }`
+	for i := 0; i < 3; i++ {
+		err := c.Post(change, &struct{}{}, auth)
+		require.ErrorContains(t, err, "current password is incorrect")
+	}
+
+	// once locked the password is not even checked, logging in is locked too
+	raw, err := c.RawPost(change, auth)
+	require.NoError(t, err)
+	var errs []struct{ Extensions struct{ Code string } }
+	require.NoError(t, json.Unmarshal(raw.Errors, &errs))
+	require.Len(t, errs, 1)
+	require.Equal(t, "ACCOUNT_LOCKED", errs[0].Extensions.Code)
+	err = c.Post(`mutation { login(input: {username: "pascal", password: "guess"}) { accessToken } }`, &struct{}{})
+	require.ErrorContains(t, err, "locked")
+}
+
+func TestLoginLockout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+	lockoutStore := mockdb.NewMockLockoutStore(ctrl)
+	c := newTestClientWithStores(t, testStores{emp: store, lockouts: lockoutStore})
+
+	account := lockoutAccount(lockoutStore, "pascal")
+	store.EXPECT().Authenticate(gomock.Any(), gomock.Any()).Times(3).Return(&employees.WrongUsernameOrPasswordError{})
+
+	login := `mutation { login(input: {username: "pascal", password: "guess"}) { accessToken } }`
+	for i := 0; i < 3; i++ {
+		err := c.Post(login, &struct{}{})
+		require.ErrorContains(t, err, "wrong username or password")
+	}
+
+	// once locked the password is not even checked
+	raw, err := c.RawPost(login)
+	require.NoError(t, err)
+	var errs []struct {
+		Message    string
+		Extensions struct {
+			Code       string
+			RetryAfter int
+		}
+	}
+	require.NoError(t, json.Unmarshal(raw.Errors, &errs))
+	require.Len(t, errs, 1)
+	require.Equal(t, "ACCOUNT_LOCKED", errs[0].Extensions.Code)
+	require.Equal(t, 60, errs[0].Extensions.RetryAfter)
+
+	// HR can see the failed attempts, other employees cannot
+	store.EXPECT().GetEmployeesByIDs(gomock.Any(), []int64{1}).AnyTimes().Return([]employees.Employee{{ID: 1, Username: "pascal"}}, nil)
+	store.EXPECT().GetEmployeeByID(gomock.Any(), int64(1)).AnyTimes().Return(employees.Employee{ID: 1, Username: "pascal"}, nil)
+	var employee struct {
+		Employee struct{ FailedLoginAttempts *int }
+	}
+	c.MustPost(`{ employee(id: "1") { failedLoginAttempts } }`, &employee, asRole(t, store, "hr", 2, employees.RoleHR))
+	require.Equal(t, 3, *employee.Employee.FailedLoginAttempts)
+	err = c.Post(`{ employee(id: "1") { failedLoginAttempts } }`, &employee, asUser(t, store, "someone", 3))
+	require.ErrorContains(t, err, ErrAccessDenied.Error())
+
+	// only admins can unlock
+	var unlocked struct{ UnlockEmployee bool }
+	err = c.Post(`mutation { unlockEmployee(id: "1") }`, &unlocked, asRole(t, store, "hr", 2, employees.RoleHR))
+	require.ErrorContains(t, err, ErrAccessDenied.Error())
+	c.MustPost(`mutation { unlockEmployee(id: "1") }`, &unlocked, asRole(t, store, "admin", 4, employees.RoleAdmin))
+	require.True(t, unlocked.UnlockEmployee)
+	require.Zero(t, account.Failures)
+
+	// after which the password is checked again
+	store.EXPECT().Authenticate(gomock.Any(), gomock.Any()).Times(1).Return(nil)
+	store.EXPECT().GetEmployeeByUsername(gomock.Any(), "pascal").Times(1).Return(employees.Employee{}, &employees.EmployeeNotFoundError{})
+	err = c.Post(`mutation { login(input: {username: "pascal", password: "right"}) { accessToken } }`, &struct{}{})
+	require.ErrorContains(t, err, "employee not found")
+
+	// errors other than wrong credentials say nothing about the password and
+	// never lock the account
+	store.EXPECT().Authenticate(gomock.Any(), gomock.Any()).Times(5).Return(errors.New("connection refused"))
+	for i := 0; i < 5; i++ {
+		err = c.Post(`mutation { login(input: {username: "pascal", password: "right"}) { accessToken } }`, &struct{}{})
+		require.ErrorContains(t, err, "connection refused")
+	}
+	require.Zero(t, account.Failures)
+	require.Nil(t, account.LockedUntil)
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+	sessionStore := mockdb.NewMockSessionStore(ctrl)
+	revocationStore := mockdb.NewMockRevocationStore(ctrl)
+	lockoutStore := mockdb.NewMockLockoutStore(ctrl)
+	twoFactorStore := mockdb.NewMockTwoFactorStore(ctrl)
+	c := newTestClientWithStores(t, testStores{
+		emp:         store,
+		sessions:    sessionStore,
+		revocations: revocationStore,
+		lockouts:    lockoutStore,
+		twoFactor:   twoFactorStore,
+	})
+	pascal := employees.Employee{ID: 1, Username: "pascal", Role: employees.RoleHR}
+	store.EXPECT().GetEmployeeByID(gomock.Any(), int64(1)).AnyTimes().Return(pascal, nil)
+	store.EXPECT().GetEmployeeByUsername(gomock.Any(), "pascal").AnyTimes().Return(pascal, nil)
+	account := lockoutAccount(lockoutStore, "pascal")
+	auth := asRole(t, store, "pascal", 1, employees.RoleHR)
+
+	// enrolling
+	var enrollment twofactor.Enrollment
+	twoFactorStore.EXPECT().GetEnrollment(gomock.Any(), int64(1)).AnyTimes().DoAndReturn(
+		func(context.Context, int64) (twofactor.Enrollment, error) {
+			if enrollment.Secret == "" {
+				return enrollment, &twofactor.NotEnrolledError{}
+			}
+			return enrollment, nil
+		})
+	twoFactorStore.EXPECT().SaveEnrollment(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).DoAndReturn(
+		func(_ context.Context, e twofactor.Enrollment, _ []twofactor.RecoveryCode) error {
+			enrollment = e
+			return nil
+		})
+	var enroll struct{ EnrollTOTP model.TOTPEnrollment }
+	c.MustPost(`mutation { enrollTOTP { uri secret recoveryCodes } }`, &enroll, auth)
+	require.Equal(t, enrollment.Secret, enroll.EnrollTOTP.Secret)
+	require.Len(t, enroll.EnrollTOTP.RecoveryCodes, 10)
+
+	twoFactorStore.EXPECT().UseStep(gomock.Any(), int64(1), gomock.Any()).AnyTimes().DoAndReturn(
+		func(_ context.Context, _ int64, step int64) (bool, error) {
+			if step <= enrollment.LastUsedStep {
+				return false, nil
+			}
+			enrollment.LastUsedStep = step
+			return true, nil
+		})
+	twoFactorStore.EXPECT().ConfirmEnrollment(gomock.Any(), int64(1), gomock.Any()).Times(1).DoAndReturn(
+		func(_ context.Context, _ int64, at time.Time) error {
+			enrollment.ConfirmedAt = &at
+			return nil
+		})
+	code, err := totp.Code(enrollment.Secret, time.Now())
+	require.NoError(t, err)
+	var confirm struct{ ConfirmTOTP bool }
+	c.MustPost(`mutation($code: String!) { confirmTOTP(code: $code) }`, &confirm, auth, client.Var("code", code))
+	require.True(t, confirm.ConfirmTOTP)
+
+	// the password alone only yields an mfa token, which is no access token
+	store.EXPECT().Authenticate(gomock.Any(), gomock.Any()).Times(1).Return(nil)
+	var login struct{ Login model.AuthToken }
+	c.MustPost(`mutation { login(input: {username: "pascal", password: "secret"}) { accessToken refreshToken mfaToken } }`, &login)
+	require.Nil(t, login.Login.AccessToken)
+	require.NotNil(t, login.Login.MfaToken)
+	var me struct{ Me struct{ Username string } }
+	err = c.Post(`{ me { username } }`, &me, client.AddHeader("Authorization", "Bearer "+*login.Login.MfaToken))
+	require.Error(t, err)
+
+	// the code that confirmed the enrollment cannot be used again
+	verify := `mutation($token: String!, $code: String!) { verifyMFA(mfaToken: $token, code: $code) { accessToken refreshToken } }`
+	var verified struct{ VerifyMFA model.AuthToken }
+	err = c.Post(verify, &verified, client.Var("token", *login.Login.MfaToken), client.Var("code", code))
+	require.ErrorContains(t, err, "invalid two-factor authentication code")
+	require.Equal(t, 1, account.Failures, "the wrong code counts, the right password does not")
+
+	// a recovery code completes the login
+	twoFactorStore.EXPECT().UseRecoveryCode(gomock.Any(), int64(1), gomock.Any(), gomock.Any()).Times(1).Return(true, nil)
+	revocationStore.EXPECT().RevokeToken(gomock.Any(), gomock.Any()).Times(1).Return(nil)
+	sessionStore.EXPECT().SaveSession(gomock.Any(), gomock.Any()).Times(1).Return(nil)
+	sessionStore.EXPECT().SaveRefreshToken(gomock.Any(), gomock.Any()).Times(1).Return(nil)
+	c.MustPost(verify, &verified, client.Var("token", *login.Login.MfaToken), client.Var("code", enroll.EnrollTOTP.RecoveryCodes[0]))
+	require.Zero(t, account.Failures)
+	require.NotNil(t, verified.VerifyMFA.AccessToken)
+	require.NotNil(t, verified.VerifyMFA.RefreshToken)
+
+	// and the mfa token cannot complete another one
+	err = c.Post(verify, &verified, client.Var("token", *login.Login.MfaToken), client.Var("code", enroll.EnrollTOTP.RecoveryCodes[1]))
+	require.ErrorContains(t, err, ErrAccessDenied.Error())
+}
+
+func TestAPIKeys(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+	keyStore := mockdb.NewMockAPIKeyStore(ctrl)
+	c := newTestClientWithStores(t, testStores{emp: store, apiKeys: keyStore})
+
+	var saved apikeys.Key
+	keyStore.EXPECT().GetServiceAccountByID(gomock.Any(), int64(3)).AnyTimes().Return(apikeys.ServiceAccount{ID: 3, Name: "payroll"}, nil)
+	keyStore.EXPECT().SaveKey(gomock.Any(), gomock.Any()).Times(1).DoAndReturn(func(_ context.Context, key apikeys.Key) error {
+		saved = key
+		return nil
+	})
+	createKey := `mutation { createAPIKey(serviceAccountID: "3", name: "export", scopes: [EMPLOYEES_READ]) { key apiKey { prefix scopes } } }`
+	var created struct {
+		CreateAPIKey struct {
+			Key    string
+			APIKey struct {
+				Prefix string
+				Scopes []string
+			}
+		}
+	}
+	// only admins manage API keys
+	err := c.Post(createKey, &created, asRole(t, store, "hr", 2, employees.RoleHR))
+	require.ErrorContains(t, err, ErrAccessDenied.Error())
+	c.MustPost(createKey, &created, asRole(t, store, "admin", 1, employees.RoleAdmin))
+	require.Equal(t, []string{"EMPLOYEES_READ"}, created.CreateAPIKey.APIKey.Scopes)
+	require.Equal(t, saved.Prefix, created.CreateAPIKey.APIKey.Prefix)
+	require.NotEqual(t, saved.KeyHash, created.CreateAPIKey.Key)
+
+	keyStore.EXPECT().GetKeyByHash(gomock.Any(), saved.KeyHash).AnyTimes().DoAndReturn(func(context.Context, string) (apikeys.Key, error) {
+		return saved, nil
+	})
+	keyStore.EXPECT().TouchKey(gomock.Any(), saved.ID, gomock.Any()).AnyTimes().Return(nil)
+	withKey := client.AddHeader(auth.APIKeyHeader, created.CreateAPIKey.Key)
+
+	// the key reaches the fields of its scope, without personal data
+	store.EXPECT().GetEmployeesByIDs(gomock.Any(), []int64{1}).Times(1).Return([]employees.Employee{{ID: 1, Username: "pascal", Email: "pascal@example.com"}}, nil)
+	var employee struct {
+		Employee struct{ Username, Email string }
+	}
+	c.MustPost(`{ employee(id: "1") { username email } }`, &employee, withKey)
+	require.Equal(t, "pascal", employee.Employee.Username)
+	require.Equal(t, RedactedValue, employee.Employee.Email)
+
+	// and nothing else
+	for _, query := range []string{
+		`{ departments { name } }`,
+		`{ me { username } }`,
+		`mutation { deleteEmployee(id: "1") }`,
+		createKey,
+	} {
+		err := c.Post(query, &struct{}{}, withKey)
+		require.ErrorContains(t, err, ErrAccessDenied.Error(), query)
+	}
+}
@@ -8,85 +8,767 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"strconv"
+	"log"
+	"time"
 
 	"github.com/pascaloseko/ems/graph/model"
+	"github.com/pascaloseko/ems/internal/apikeys"
 	"github.com/pascaloseko/ems/internal/auth"
 	"github.com/pascaloseko/ems/internal/employees"
-	"github.com/pascaloseko/ems/internal/pkg/jwt"
+	"github.com/pascaloseko/ems/internal/sessions"
 )
 
+// Department is the resolver for the department field.
+func (r *employeeResolver) Department(ctx context.Context, obj *model.Employee) (*model.Department, error) {
+	if obj.DepartmentID == 0 {
+		return nil, nil
+	}
+	department, found, err := r.loaders(ctx).DepartmentByID.Load(ctx, obj.DepartmentID)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, nil
+	}
+	return toModelDepartment(department), nil
+}
+
+// FailedLoginAttempts is the resolver for the failedLoginAttempts field.
+func (r *employeeResolver) FailedLoginAttempts(ctx context.Context, obj *model.Employee) (*int, error) {
+	attempts, err := r.lockout.FailedAttempts(ctx, obj.Username)
+	if err != nil {
+		return nil, err
+	}
+	return &attempts, nil
+}
+
 // CreateEmployee is the resolver for the createEmployee field.
-func (r *mutationResolver) CreateEmployee(ctx context.Context, input model.NewEmployee) (*string, error) {
-	var token = ""
+func (r *mutationResolver) CreateEmployee(ctx context.Context, input model.NewEmployee) (*model.Employee, error) {
+	if !auth.Authenticated(ctx) {
+		return nil, ErrAccessDenied
+	}
+	employee, err := r.saveEmployee(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	return toModelEmployee(employee), nil
+}
 
+// RegisterEmployee is the resolver for the registerEmployee field.
+func (r *mutationResolver) RegisterEmployee(ctx context.Context, input model.NewEmployee) (*model.AuthToken, error) {
+	scope := model.ScopeEmployeesWrite
+	if !r.openRegistration && !hasRole(ctx, model.RoleHr, &scope) {
+		return nil, ErrAccessDenied
+	}
+	employee, err := r.saveEmployee(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	return r.newAuthToken(ctx, employee)
+}
+
+// Login is the resolver for the login field.
+func (r *mutationResolver) Login(ctx context.Context, input model.Login) (*model.AuthToken, error) {
 	var employee employees.Employee
-	employee.FirstName = input.FirstName
-	employee.LastName = input.LastName
 	employee.Username = input.Username
-	employee.Email = input.Email
-	employee.DOB = input.Dob
-	employee.Password = r.emp.HashPassword(input.Password)
-	employee.Position = input.Position
+	employee.Password = input.Password
 
-	_, err := r.emp.Save(ctx, employee)
+	ip := sessions.ClientForContext(ctx).IPAddress
+	if err := r.lockout.Attempt(ctx, input.Username, ip); err != nil {
+		return nil, err
+	}
+	if err := r.emp.Authenticate(ctx, employee); err != nil {
+		r.attemptFailed(ctx, input.Username, ip, err)
+		return nil, err
+	}
+	// the right password is no failed login, but the failed logins are only
+	// forgotten once the second factor is proven too, otherwise the password
+	// would reset the count of wrong codes
+	r.releaseAttempt(ctx, input.Username)
+	employee, err := r.emp.GetEmployeeByUsername(ctx, input.Username)
 	if err != nil {
-		return nil, fmt.Errorf("failed to save employee: %w", err)
+		return nil, err
 	}
-	token, err = jwt.GenerateToken(employee.Username)
+	enabled, err := r.totp.Enabled(ctx, employee.ID)
 	if err != nil {
-		return &token, err
+		return nil, err
 	}
-	return &token, nil
+	if enabled {
+		mfaToken, err := r.tokens.GenerateMFAToken(employee.ID, employee.Username)
+		if err != nil {
+			return nil, err
+		}
+		return &model.AuthToken{MfaToken: &mfaToken}, nil
+	}
+	if err := r.lockout.Succeeded(ctx, input.Username); err != nil {
+		log.Printf("failed to reset failed logins of %q: %v", input.Username, err)
+	}
+	return r.newAuthToken(ctx, employee)
 }
 
-// RefreshToken is the resolver for the refreshToken field.
-func (r *mutationResolver) RefreshToken(ctx context.Context, input model.RefreshTokenInput) (string, error) {
-	username, err := jwt.ParseToken(input.Token)
+// UpdateEmployee is the resolver for the updateEmployee field.
+func (r *mutationResolver) UpdateEmployee(ctx context.Context, id string, input model.UpdateEmployee) (*model.Employee, error) {
+	employeeID, err := parseID(id)
 	if err != nil {
-		return "", ErrAccessDenied
+		return nil, err
+	}
+
+	// employees manage their own personal details, HR manages everything else.
+	// Service accounts allowed to write employees can do what HR can.
+	if auth.ServiceForContext(ctx) != nil {
+		if input.Role != nil {
+			return nil, ErrAccessDenied
+		}
+	} else {
+		user := auth.ForContext(ctx)
+		if user == nil {
+			return nil, ErrAccessDenied
+		}
+		isHR := user.Role.Satisfies(employees.RoleHR)
+		if user.ID != employeeID && !isHR {
+			return nil, ErrAccessDenied
+		}
+		if (input.Department != nil || input.Position != nil) && !isHR {
+			return nil, ErrAccessDenied
+		}
+		if input.Role != nil && !user.Role.Satisfies(employees.RoleAdmin) {
+			return nil, ErrAccessDenied
+		}
+		// a stolen access token alone must not be enough to take the account over
+		if input.Password != nil && user.ID == employeeID {
+			return nil, ErrChangeOwnPassword
+		}
+	}
+
+	upd := employees.EmployeeUpdate{
+		FirstName:      input.FirstName,
+		LastName:       input.LastName,
+		Username:       input.Username,
+		Email:          input.Email,
+		DOB:            input.Dob,
+		DepartmentName: input.Department,
+		Position:       input.Position,
+	}
+	self := auth.ServiceForContext(ctx) == nil && auth.ForContext(ctx).ID == employeeID
+	var current employees.Employee
+	if !self || input.Password != nil || input.Username != nil {
+		current, err = r.emp.GetEmployeeByID(ctx, employeeID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !self && !outranks(ctx, current) {
+		return nil, ErrAccessDenied
+	}
+	if input.Password != nil {
+		// the password must not contain the old nor the new username and email
+		personal := []string{current.Username, current.Email}
+		if input.Username != nil {
+			personal = append(personal, *input.Username)
+		}
+		if input.Email != nil {
+			personal = append(personal, *input.Email)
+		}
+		if err := r.checkPassword(*input.Password, personal...); err != nil {
+			return nil, err
+		}
+		hashed, err := r.emp.HashPassword(ctx, *input.Password)
+		if err != nil {
+			return nil, err
+		}
+		upd.Password = &hashed
+	}
+	if input.Role != nil {
+		role := toEmployeeRole(*input.Role)
+		upd.Role = &role
+	}
+
+	employee, err := r.emp.Update(ctx, employeeID, upd)
+	if err != nil {
+		return nil, err
+	}
+	// whoever knew the old password must not stay signed in, and tokens issued
+	// under the old username must not outlive it
+	renamed := input.Username != nil && *input.Username != current.Username
+	if upd.Password != nil || renamed {
+		if err := r.signOutEverywhere(ctx, employeeID); err != nil {
+			return nil, err
+		}
+	}
+	return toModelEmployee(employee), nil
+}
+
+// DeleteEmployee is the resolver for the deleteEmployee field.
+func (r *mutationResolver) DeleteEmployee(ctx context.Context, id string) (bool, error) {
+	if !auth.Authenticated(ctx) {
+		return false, ErrAccessDenied
+	}
+	employeeID, err := parseID(id)
+	if err != nil {
+		return false, err
+	}
+	target, err := r.emp.GetEmployeeByID(ctx, employeeID)
+	if err != nil {
+		return false, err
+	}
+	if !outranks(ctx, target) {
+		return false, ErrAccessDenied
+	}
+	// end the sessions first, they must not outlive the employee even if the
+	// delete fails halfway
+	if err := r.signOutEverywhere(ctx, employeeID); err != nil {
+		return false, err
+	}
+	if err := r.emp.Delete(ctx, employeeID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// CreateDepartment is the resolver for the createDepartment field.
+func (r *mutationResolver) CreateDepartment(ctx context.Context, name string) (*model.Department, error) {
+	if !auth.Authenticated(ctx) {
+		return nil, ErrAccessDenied
+	}
+	existingID, err := r.emp.GetDepartmentIdByName(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if existingID != 0 {
+		return nil, &employees.DepartmentExistsError{Name: name}
+	}
+	department := employees.Department{Name: name}
+	department.ID, err = r.emp.SaveDepartment(ctx, department)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save department: %w", err)
+	}
+	return toModelDepartment(department), nil
+}
+
+// RenameDepartment is the resolver for the renameDepartment field.
+func (r *mutationResolver) RenameDepartment(ctx context.Context, id string, name string) (*model.Department, error) {
+	if !auth.Authenticated(ctx) {
+		return nil, ErrAccessDenied
+	}
+	departmentID, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+	department, err := r.emp.RenameDepartment(ctx, departmentID, name)
+	if err != nil {
+		return nil, err
+	}
+	return toModelDepartment(department), nil
+}
+
+// DeleteDepartment is the resolver for the deleteDepartment field.
+func (r *mutationResolver) DeleteDepartment(ctx context.Context, id string) (bool, error) {
+	if !auth.Authenticated(ctx) {
+		return false, ErrAccessDenied
 	}
-	token, err := jwt.GenerateToken(username)
+	departmentID, err := parseID(id)
+	if err != nil {
+		return false, err
+	}
+	if err := r.emp.DeleteDepartment(ctx, departmentID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// RotateSigningKey is the resolver for the rotateSigningKey field.
+func (r *mutationResolver) RotateSigningKey(ctx context.Context) (string, error) {
+	kid, err := r.tokens.Rotate()
 	if err != nil {
 		return "", err
 	}
-	return token, nil
+	log.Printf("signing key rotated by %s, new kid %s", auth.ForContext(ctx).Username, kid)
+	return kid, nil
 }
 
-// Employees is the resolver for the employees field.
-func (r *queryResolver) Employees(ctx context.Context) ([]*model.Employee, error) {
+// RefreshToken is the resolver for the refreshToken field.
+func (r *mutationResolver) RefreshToken(ctx context.Context, input model.RefreshTokenInput) (*model.AuthToken, error) {
+	grant, err := r.sessions.Rotate(ctx, input.Token)
+	if err != nil {
+		var reused *sessions.RefreshTokenReusedError
+		if errors.As(err, &reused) {
+			// the access tokens of the session may have leaked too
+			if err := r.denylist.RevokeSession(ctx, reused.SessionID); err != nil {
+				log.Printf("failed to revoke tokens of session %s: %v", reused.SessionID, err)
+			}
+		}
+		return nil, err
+	}
+	// pick up role changes made since the last token was issued
+	employee, err := r.emp.GetEmployeeByID(ctx, grant.EmployeeID)
+	if err != nil {
+		return nil, ErrAccessDenied
+	}
+	accessToken, err := r.tokens.GenerateSessionToken(employee.ID, employee.Username, string(employee.Role), grant.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	return &model.AuthToken{
+		AccessToken:  &accessToken,
+		RefreshToken: &grant.RefreshToken,
+	}, nil
+}
+
+// Logout is the resolver for the logout field.
+func (r *mutationResolver) Logout(ctx context.Context, refreshToken *string) (bool, error) {
+	user := auth.ForContext(ctx)
+	claims := auth.TokenForContext(ctx)
+	if user == nil || claims == nil {
+		return false, ErrAccessDenied
+	}
+	if refreshToken != nil {
+		sessionID, err := r.sessions.Revoke(ctx, *refreshToken, user.ID)
+		if err != nil {
+			return false, err
+		}
+		if err := r.denylist.RevokeSession(ctx, sessionID); err != nil {
+			return false, fmt.Errorf("failed to revoke session tokens: %w", err)
+		}
+	}
+	// tokens issued outside of a session can only be revoked one by one
+	if claims.SessionID == "" {
+		if err := r.denylist.RevokeToken(ctx, claims); err != nil {
+			return false, fmt.Errorf("failed to revoke token: %w", err)
+		}
+		return true, nil
+	}
+	if err := r.endSession(ctx, claims.SessionID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// RevokeAllSessions is the resolver for the revokeAllSessions field.
+func (r *mutationResolver) RevokeAllSessions(ctx context.Context, employeeID string) (bool, error) {
+	user := auth.ForContext(ctx)
+	if user == nil {
+		return false, ErrAccessDenied
+	}
+	id, err := parseID(employeeID)
+	if err != nil {
+		return false, err
+	}
+	if user.ID != id && !user.Role.Satisfies(employees.RoleHR) {
+		return false, ErrAccessDenied
+	}
+
+	if err := r.signOutEverywhere(ctx, id); err != nil {
+		return false, err
+	}
+	log.Printf("all sessions of employee %d revoked by %s", id, user.Username)
+	return true, nil
+}
+
+// TerminateSession is the resolver for the terminateSession field.
+func (r *mutationResolver) TerminateSession(ctx context.Context, id string) (bool, error) {
+	user := auth.ForContext(ctx)
+	if user == nil {
+		return false, ErrAccessDenied
+	}
+	session, err := r.sessions.Get(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	// other people's sessions look like they do not exist
+	if session.EmployeeID != user.ID && !user.Role.Satisfies(employees.RoleAdmin) {
+		return false, &sessions.SessionNotFoundError{}
+	}
+	if err := r.endSession(ctx, id); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// RequestPasswordReset is the resolver for the requestPasswordReset field.
+func (r *mutationResolver) RequestPasswordReset(ctx context.Context, email string) (bool, error) {
+	employee, err := r.emp.GetEmployeeByEmail(ctx, email)
+	if err != nil {
+		var notFound *employees.EmployeeNotFoundError
+		if errors.As(err, &notFound) {
+			return true, nil
+		}
+		return false, err
+	}
+	// failing to send is only logged, telling the caller would reveal that the account exists
+	if err := r.resets.Request(ctx, employee); err != nil {
+		log.Printf("failed to send password reset to employee %d: %v", employee.ID, err)
+	}
+	return true, nil
+}
+
+// ResetPassword is the resolver for the resetPassword field.
+func (r *mutationResolver) ResetPassword(ctx context.Context, token string, newPassword string) (bool, error) {
+	// a password rejected by the policy must not use the token up
+	employeeID, err := r.resets.Check(ctx, token)
+	if err != nil {
+		return false, err
+	}
+	employee, err := r.emp.GetEmployeeByID(ctx, employeeID)
+	if err != nil {
+		return false, err
+	}
+	if err := r.checkPassword(newPassword, employee.Username, employee.Email); err != nil {
+		return false, err
+	}
+	if _, err := r.resets.Redeem(ctx, token); err != nil {
+		return false, err
+	}
+	if err := r.setPassword(ctx, employeeID, newPassword); err != nil {
+		return false, err
+	}
+	// whoever knew the old password must not stay signed in
+	if err := r.signOutEverywhere(ctx, employeeID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ChangePassword is the resolver for the changePassword field.
+func (r *mutationResolver) ChangePassword(ctx context.Context, oldPassword string, newPassword string) (bool, error) {
 	user := auth.ForContext(ctx)
 	if user == nil {
+		return false, ErrAccessDenied
+	}
+	// wrong old passwords count as failed logins so that a stolen access token
+	// cannot be used to guess the password. The right one does not reset the
+	// count, which would let wrong second factor codes be forgotten.
+	ip := sessions.ClientForContext(ctx).IPAddress
+	if err := r.lockout.Attempt(ctx, user.Username, ip); err != nil {
+		return false, err
+	}
+	if err := r.emp.Authenticate(ctx, employees.Employee{Username: user.Username, Password: oldPassword}); err != nil {
+		r.attemptFailed(ctx, user.Username, ip, err)
+		var wrongCredentials *employees.WrongUsernameOrPasswordError
+		if errors.As(err, &wrongCredentials) {
+			return false, &employees.WrongPasswordError{}
+		}
+		return false, err
+	}
+	r.releaseAttempt(ctx, user.Username)
+	employee, err := r.emp.GetEmployeeByID(ctx, user.ID)
+	if err != nil {
+		return false, err
+	}
+	if err := r.checkPassword(newPassword, employee.Username, employee.Email); err != nil {
+		return false, err
+	}
+	if err := r.setPassword(ctx, employee.ID, newPassword); err != nil {
+		return false, err
+	}
+	if err := r.signOutOtherSessions(ctx, employee.ID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// UnlockEmployee is the resolver for the unlockEmployee field.
+func (r *mutationResolver) UnlockEmployee(ctx context.Context, id string) (bool, error) {
+	user := auth.ForContext(ctx)
+	if user == nil {
+		return false, ErrAccessDenied
+	}
+	employeeID, err := parseID(id)
+	if err != nil {
+		return false, err
+	}
+	employee, err := r.emp.GetEmployeeByID(ctx, employeeID)
+	if err != nil {
+		return false, err
+	}
+	if err := r.lockout.Unlock(ctx, employee.Username); err != nil {
+		return false, err
+	}
+	log.Printf("employee %d unlocked by %s", employeeID, user.Username)
+	return true, nil
+}
+
+// EnrollTotp is the resolver for the enrollTOTP field.
+func (r *mutationResolver) EnrollTotp(ctx context.Context) (*model.TOTPEnrollment, error) {
+	user := auth.ForContext(ctx)
+	if user == nil {
+		return nil, ErrAccessDenied
+	}
+	employee, err := r.emp.GetEmployeeByID(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	setup, err := r.totp.Enroll(ctx, employee)
+	if err != nil {
+		return nil, err
+	}
+	return &model.TOTPEnrollment{
+		URI:           setup.URI,
+		Secret:        setup.Secret,
+		RecoveryCodes: setup.RecoveryCodes,
+	}, nil
+}
+
+// ConfirmTotp is the resolver for the confirmTOTP field.
+func (r *mutationResolver) ConfirmTotp(ctx context.Context, code string) (bool, error) {
+	user := auth.ForContext(ctx)
+	if user == nil {
+		return false, ErrAccessDenied
+	}
+	if err := r.totp.Confirm(ctx, user.ID, code); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// DisableTotp is the resolver for the disableTOTP field.
+func (r *mutationResolver) DisableTotp(ctx context.Context, code string) (bool, error) {
+	user := auth.ForContext(ctx)
+	if user == nil {
+		return false, ErrAccessDenied
+	}
+	// a stolen access token alone must not be enough to turn the second factor off
+	if err := r.verifySecondFactor(ctx, *user, code); err != nil {
+		return false, err
+	}
+	if err := r.totp.Disable(ctx, user.ID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// VerifyMfa is the resolver for the verifyMFA field.
+func (r *mutationResolver) VerifyMfa(ctx context.Context, mfaToken string, code string) (*model.AuthToken, error) {
+	claims, err := r.tokens.ParseMFAToken(mfaToken)
+	if err != nil {
+		return nil, ErrAccessDenied
+	}
+	employee, err := r.emp.GetEmployeeByID(ctx, claims.EmployeeID)
+	if err != nil {
+		return nil, ErrAccessDenied
+	}
+	if r.denylist.IsRevoked(claims, employee.ID) {
+		return nil, ErrAccessDenied
+	}
+	if err := r.verifySecondFactor(ctx, employee, code); err != nil {
+		return nil, err
+	}
+	// each mfa token completes a single login
+	if err := r.denylist.RevokeToken(ctx, claims); err != nil {
+		return nil, err
+	}
+	return r.newAuthToken(ctx, employee)
+}
+
+// CreateServiceAccount is the resolver for the createServiceAccount field.
+func (r *mutationResolver) CreateServiceAccount(ctx context.Context, name string) (*model.ServiceAccount, error) {
+	if auth.ForContext(ctx) == nil {
+		return nil, ErrAccessDenied
+	}
+	account, err := r.keys.CreateServiceAccount(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return toModelServiceAccount(account, nil), nil
+}
+
+// DeleteServiceAccount is the resolver for the deleteServiceAccount field.
+func (r *mutationResolver) DeleteServiceAccount(ctx context.Context, id string) (bool, error) {
+	if auth.ForContext(ctx) == nil {
+		return false, ErrAccessDenied
+	}
+	accountID, err := parseID(id)
+	if err != nil {
+		return false, err
+	}
+	if err := r.keys.DeleteServiceAccount(ctx, accountID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// CreateAPIKey is the resolver for the createAPIKey field.
+func (r *mutationResolver) CreateAPIKey(ctx context.Context, serviceAccountID string, name string, scopes []model.Scope, expiresAt *time.Time) (*model.NewAPIKey, error) {
+	if auth.ForContext(ctx) == nil {
+		return nil, ErrAccessDenied
+	}
+	accountID, err := parseID(serviceAccountID)
+	if err != nil {
+		return nil, err
+	}
+	keyScopes := make([]apikeys.Scope, 0, len(scopes))
+	for _, scope := range scopes {
+		keyScopes = append(keyScopes, toAPIScope(scope))
+	}
+	key, plain, err := r.keys.CreateKey(ctx, accountID, name, keyScopes, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+	return &model.NewAPIKey{Key: plain, APIKey: toModelAPIKey(key)}, nil
+}
+
+// RevokeAPIKey is the resolver for the revokeAPIKey field.
+func (r *mutationResolver) RevokeAPIKey(ctx context.Context, id string) (bool, error) {
+	if auth.ForContext(ctx) == nil {
+		return false, ErrAccessDenied
+	}
+	if err := r.keys.RevokeKey(ctx, id); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Employees is the resolver for the employees field.
+func (r *queryResolver) Employees(ctx context.Context, filter *model.EmployeeFilter, orderBy []*model.EmployeeOrder, first *int, after *string) (*model.EmployeeConnection, error) {
+	if !auth.Authenticated(ctx) {
 		return nil, ErrAccessDenied
 	}
-	var resultEmployees []*model.Employee
-	employees, err := r.emp.GetAllEmployees(ctx)
+	query, err := toEmployeeQuery(filter, orderBy, first, after)
+	if err != nil {
+		return nil, err
+	}
+	employees, total, err := r.emp.FindEmployees(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get employees: %w", err)
 	}
-	for _, employee := range employees {
+	return newEmployeeConnection(employees, total, query), nil
+}
+
+// Employee is the resolver for the employee field.
+func (r *queryResolver) Employee(ctx context.Context, id string) (*model.Employee, error) {
+	if !auth.Authenticated(ctx) {
+		return nil, ErrAccessDenied
+	}
+	employeeID, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+	employee, found, err := r.loaders(ctx).EmployeeByID.Load(ctx, employeeID)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, &employees.EmployeeNotFoundError{}
+	}
+	return toModelEmployee(employee), nil
+}
+
+// EmployeeByUsername is the resolver for the employeeByUsername field.
+func (r *queryResolver) EmployeeByUsername(ctx context.Context, username string) (*model.Employee, error) {
+	if !auth.Authenticated(ctx) {
+		return nil, ErrAccessDenied
+	}
+	employee, err := r.emp.GetEmployeeByUsername(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	return toModelEmployee(employee), nil
+}
+
+// Me is the resolver for the me field.
+func (r *queryResolver) Me(ctx context.Context) (*model.Employee, error) {
+	user := auth.ForContext(ctx)
+	if user == nil {
+		return nil, ErrAccessDenied
+	}
+	employee, err := r.emp.GetEmployeeByID(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	return toModelEmployee(employee), nil
+}
+
+// TotpEnabled is the resolver for the totpEnabled field.
+func (r *queryResolver) TotpEnabled(ctx context.Context) (bool, error) {
+	user := auth.ForContext(ctx)
+	if user == nil {
+		return false, ErrAccessDenied
+	}
+	return r.totp.Enabled(ctx, user.ID)
+}
+
+// Departments is the resolver for the departments field.
+func (r *queryResolver) Departments(ctx context.Context) ([]*model.Department, error) {
+	if !auth.Authenticated(ctx) {
+		return nil, ErrAccessDenied
+	}
+	departments, err := r.emp.GetDepartments(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get departments: %w", err)
+	}
+	resultDepartments := make([]*model.Department, 0, len(departments))
+	for _, department := range departments {
+		resultDepartments = append(resultDepartments, toModelDepartment(department))
+	}
+	return resultDepartments, nil
+}
+
+// Department is the resolver for the department field.
+func (r *queryResolver) Department(ctx context.Context, id string) (*model.Department, error) {
+	if !auth.Authenticated(ctx) {
+		return nil, ErrAccessDenied
+	}
+	departmentID, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+	department, err := r.emp.GetDepartmentByID(ctx, departmentID)
+	if err != nil {
+		return nil, err
+	}
+	return toModelDepartment(department), nil
+}
+
+// MySessions is the resolver for the mySessions field.
+func (r *queryResolver) MySessions(ctx context.Context) ([]*model.Session, error) {
+	user := auth.ForContext(ctx)
+	if user == nil {
+		return nil, ErrAccessDenied
+	}
+	return r.sessionsOf(ctx, user.ID)
+}
 
-		resultEmployees = append(resultEmployees, &model.Employee{
-			ID:        strconv.Itoa(int(employee.ID)),
-			FirstName: employee.FirstName,
-			LastName:  employee.LastName,
-			Username:  employee.Username,
-			Email:     employee.Email,
-			Dob:       employee.DOB,
-			Password:  employee.Password,
-			Position:  employee.Position,
-		})
+// EmployeeSessions is the resolver for the employeeSessions field.
+func (r *queryResolver) EmployeeSessions(ctx context.Context, id string) ([]*model.Session, error) {
+	user := auth.ForContext(ctx)
+	if user == nil {
+		return nil, ErrAccessDenied
+	}
+	employeeID, err := parseID(id)
+	if err != nil {
+		return nil, err
 	}
-	return resultEmployees, nil
+	return r.sessionsOf(ctx, employeeID)
 }
 
+// ServiceAccounts is the resolver for the serviceAccounts field.
+func (r *queryResolver) ServiceAccounts(ctx context.Context) ([]*model.ServiceAccount, error) {
+	if auth.ForContext(ctx) == nil {
+		return nil, ErrAccessDenied
+	}
+	accounts, err := r.keys.ServiceAccounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*model.ServiceAccount, 0, len(accounts))
+	for _, account := range accounts {
+		keys, err := r.keys.Keys(ctx, account.ID)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, toModelServiceAccount(account, keys))
+	}
+	return result, nil
+}
+
+// Employee returns EmployeeResolver implementation.
+func (r *Resolver) Employee() EmployeeResolver { return &employeeResolver{r} }
+
 // Mutation returns MutationResolver implementation.
 func (r *Resolver) Mutation() MutationResolver { return &mutationResolver{r} }
 
 // Query returns QueryResolver implementation.
 func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
 
+type employeeResolver struct{ *Resolver }
 type mutationResolver struct{ *Resolver }
 type queryResolver struct{ *Resolver }
-
-var ErrAccessDenied = errors.New("access denied")
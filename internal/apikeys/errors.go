@@ -0,0 +1,38 @@
+package apikeys
+
+import "fmt"
+
+// InvalidKeyError is returned for keys that are unknown, malformed or expired
+type InvalidKeyError struct{}
+
+func (m *InvalidKeyError) Error() string {
+	return "invalid API key"
+}
+
+type ServiceAccountNotFoundError struct{}
+
+func (m *ServiceAccountNotFoundError) Error() string {
+	return "service account not found"
+}
+
+type KeyNotFoundError struct{}
+
+func (m *KeyNotFoundError) Error() string {
+	return "API key not found"
+}
+
+// UnknownScopeError is returned when creating a key with a scope that does not exist
+type UnknownScopeError struct {
+	Scope Scope
+}
+
+func (m *UnknownScopeError) Error() string {
+	return fmt.Sprintf("unknown API key scope %q", m.Scope)
+}
+
+// InvalidExpiryError is returned when creating a key that would already be expired
+type InvalidExpiryError struct{}
+
+func (m *InvalidExpiryError) Error() string {
+	return "API key expiry must be in the future"
+}
@@ -0,0 +1,186 @@
+// Package apikeys lets service accounts call the API with long lived, scoped
+// keys instead of logging in as an employee.
+package apikeys
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/pascaloseko/ems/internal/pkg/secret"
+)
+
+const (
+	// keyPrefix marks EMS API keys so that leaked ones are easy to spot
+	keyPrefix = "ems_"
+	// displayPrefix is how many characters of a key are kept to tell it apart
+	displayPrefix = 12
+	// touchInterval limits how often the last used time of a key is written
+	touchInterval = time.Minute
+)
+
+// Scope is something an API key is allowed to do
+type Scope string
+
+const (
+	ScopeEmployeesRead Scope = "employees:read"
+	// ScopeEmployeesReadSensitive also shows the personal data of employees
+	ScopeEmployeesReadSensitive Scope = "employees:read_sensitive"
+	ScopeEmployeesWrite         Scope = "employees:write"
+	ScopeDepartmentsRead        Scope = "departments:read"
+	ScopeDepartmentsWrite       Scope = "departments:write"
+)
+
+// Valid reports whether s is one of the known scopes
+func (s Scope) Valid() bool {
+	switch s {
+	case ScopeEmployeesRead, ScopeEmployeesReadSensitive, ScopeEmployeesWrite, ScopeDepartmentsRead, ScopeDepartmentsWrite:
+		return true
+	}
+	return false
+}
+
+// Principal is a service account authenticated with one of its keys
+type Principal struct {
+	ServiceAccountID int64
+	ServiceAccount   string
+	KeyID            string
+	Scopes           []Scope
+}
+
+// HasScope reports whether the key the principal used grants scope
+func (p *Principal) HasScope(scope Scope) bool {
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Manager creates service accounts and their keys and authenticates requests
+// made with them
+type Manager struct {
+	store Store
+	now   func() time.Time
+}
+
+func NewManager(store Store) *Manager {
+	return &Manager{
+		store: store,
+		now:   time.Now,
+	}
+}
+
+// CreateServiceAccount adds a service account without any keys
+func (m *Manager) CreateServiceAccount(ctx context.Context, name string) (ServiceAccount, error) {
+	account := ServiceAccount{Name: name, CreatedAt: m.now()}
+	id, err := m.store.CreateServiceAccount(ctx, account)
+	if err != nil {
+		return ServiceAccount{}, err
+	}
+	account.ID = id
+	return account, nil
+}
+
+// ServiceAccounts lists every service account
+func (m *Manager) ServiceAccounts(ctx context.Context) ([]ServiceAccount, error) {
+	return m.store.GetServiceAccounts(ctx)
+}
+
+// DeleteServiceAccount deletes a service account, its keys stop working
+func (m *Manager) DeleteServiceAccount(ctx context.Context, id int64) error {
+	return m.store.DeleteServiceAccount(ctx, id)
+}
+
+// CreateKey issues a key for serviceAccountID granting scopes, which never
+// expires when expiresAt is nil. The key is returned once and cannot be
+// recovered afterwards.
+func (m *Manager) CreateKey(ctx context.Context, serviceAccountID int64, name string, scopes []Scope, expiresAt *time.Time) (Key, string, error) {
+	for _, scope := range scopes {
+		if !scope.Valid() {
+			return Key{}, "", &UnknownScopeError{Scope: scope}
+		}
+	}
+	now := m.now()
+	if expiresAt != nil && !expiresAt.After(now) {
+		return Key{}, "", &InvalidExpiryError{}
+	}
+	if _, err := m.store.GetServiceAccountByID(ctx, serviceAccountID); err != nil {
+		return Key{}, "", err
+	}
+	id, err := secret.ID()
+	if err != nil {
+		return Key{}, "", err
+	}
+	token, err := secret.Token()
+	if err != nil {
+		return Key{}, "", err
+	}
+	plain := keyPrefix + token
+	key := Key{
+		ID:               id,
+		ServiceAccountID: serviceAccountID,
+		Name:             name,
+		Prefix:           plain[:displayPrefix],
+		KeyHash:          secret.Hash(plain),
+		Scopes:           scopes,
+		CreatedAt:        now,
+		ExpiresAt:        expiresAt,
+	}
+	if err := m.store.SaveKey(ctx, key); err != nil {
+		return Key{}, "", err
+	}
+	return key, plain, nil
+}
+
+// Keys lists the keys of serviceAccountID
+func (m *Manager) Keys(ctx context.Context, serviceAccountID int64) ([]Key, error) {
+	return m.store.GetKeysByServiceAccount(ctx, serviceAccountID)
+}
+
+// RevokeKey deletes a key, requests made with it are rejected from then on
+func (m *Manager) RevokeKey(ctx context.Context, id string) error {
+	return m.store.DeleteKey(ctx, id)
+}
+
+// Authenticate returns the service account plain is a valid key of
+func (m *Manager) Authenticate(ctx context.Context, plain string) (*Principal, error) {
+	if !strings.HasPrefix(plain, keyPrefix) {
+		return nil, &InvalidKeyError{}
+	}
+	key, err := m.store.GetKeyByHash(ctx, secret.Hash(plain))
+	if err != nil {
+		var notFound *KeyNotFoundError
+		if errors.As(err, &notFound) {
+			return nil, &InvalidKeyError{}
+		}
+		return nil, err
+	}
+	now := m.now()
+	if key.ExpiresAt != nil && !now.Before(*key.ExpiresAt) {
+		return nil, &InvalidKeyError{}
+	}
+	account, err := m.store.GetServiceAccountByID(ctx, key.ServiceAccountID)
+	if err != nil {
+		var notFound *ServiceAccountNotFoundError
+		if errors.As(err, &notFound) {
+			return nil, &InvalidKeyError{}
+		}
+		return nil, err
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= touchInterval {
+		if err := m.store.TouchKey(ctx, key.ID, now); err != nil {
+			log.Printf("failed to record use of API key %s: %v", key.ID, err)
+		}
+	}
+	return &Principal{
+		ServiceAccountID: account.ID,
+		ServiceAccount:   account.Name,
+		KeyID:            key.ID,
+		Scopes:           key.Scopes,
+	}, nil
+}
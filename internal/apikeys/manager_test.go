@@ -0,0 +1,141 @@
+package apikeys_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pascaloseko/ems/internal/apikeys"
+	"github.com/pascaloseko/ems/internal/mockdb"
+	"github.com/pascaloseko/ems/internal/pkg/secret"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateKey(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockAPIKeyStore(ctrl)
+	manager := apikeys.NewManager(store)
+	ctx := context.Background()
+
+	var saved apikeys.Key
+	store.EXPECT().GetServiceAccountByID(gomock.Any(), int64(3)).Times(1).Return(apikeys.ServiceAccount{ID: 3, Name: "payroll"}, nil)
+	store.EXPECT().SaveKey(gomock.Any(), gomock.Any()).Times(1).DoAndReturn(func(_ context.Context, key apikeys.Key) error {
+		saved = key
+		return nil
+	})
+	scopes := []apikeys.Scope{apikeys.ScopeEmployeesRead, apikeys.ScopeEmployeesReadSensitive}
+	key, plain, err := manager.CreateKey(ctx, 3, "nightly export", scopes, nil)
+	require.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(plain, "ems_"), plain)
+	assert.True(t, strings.HasPrefix(plain, saved.Prefix))
+	assert.Equal(t, secret.Hash(plain), saved.KeyHash)
+	assert.NotContains(t, saved.KeyHash, plain)
+	assert.Equal(t, saved, key)
+	assert.Equal(t, scopes, saved.Scopes)
+	assert.Nil(t, saved.ExpiresAt)
+}
+
+func TestCreateKeyRejected(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockAPIKeyStore(ctrl)
+	store.EXPECT().SaveKey(gomock.Any(), gomock.Any()).Times(0)
+	manager := apikeys.NewManager(store)
+	ctx := context.Background()
+
+	_, _, err := manager.CreateKey(ctx, 3, "export", []apikeys.Scope{"employees:delete_all"}, nil)
+	assert.IsType(t, &apikeys.UnknownScopeError{}, err)
+
+	past := time.Now().Add(-time.Hour)
+	_, _, err = manager.CreateKey(ctx, 3, "export", []apikeys.Scope{apikeys.ScopeEmployeesRead}, &past)
+	assert.IsType(t, &apikeys.InvalidExpiryError{}, err)
+
+	store.EXPECT().GetServiceAccountByID(gomock.Any(), int64(4)).Times(1).Return(apikeys.ServiceAccount{}, &apikeys.ServiceAccountNotFoundError{})
+	_, _, err = manager.CreateKey(ctx, 4, "export", []apikeys.Scope{apikeys.ScopeEmployeesRead}, nil)
+	assert.IsType(t, &apikeys.ServiceAccountNotFoundError{}, err)
+}
+
+func TestAuthenticate(t *testing.T) {
+	const plain = "ems_0123456789abcdef"
+	account := apikeys.ServiceAccount{ID: 3, Name: "payroll"}
+	recently := time.Now().Add(-time.Second)
+	expired := time.Now().Add(-time.Second)
+	later := time.Now().Add(time.Hour)
+
+	testCases := []struct {
+		name       string
+		plain      string
+		buildStubs func(store *mockdb.MockAPIKeyStore)
+		wantErr    error
+	}{
+		{
+			name:  "FirstUse",
+			plain: plain,
+			buildStubs: func(store *mockdb.MockAPIKeyStore) {
+				store.EXPECT().GetKeyByHash(gomock.Any(), secret.Hash(plain)).Times(1).Return(apikeys.Key{ID: "k1", ServiceAccountID: 3, Scopes: []apikeys.Scope{apikeys.ScopeEmployeesRead}, ExpiresAt: &later}, nil)
+				store.EXPECT().GetServiceAccountByID(gomock.Any(), int64(3)).Times(1).Return(account, nil)
+				store.EXPECT().TouchKey(gomock.Any(), "k1", gomock.Any()).Times(1).Return(nil)
+			},
+		},
+		{
+			name:  "UsedRecently",
+			plain: plain,
+			buildStubs: func(store *mockdb.MockAPIKeyStore) {
+				store.EXPECT().GetKeyByHash(gomock.Any(), secret.Hash(plain)).Times(1).Return(apikeys.Key{ID: "k1", ServiceAccountID: 3, Scopes: []apikeys.Scope{apikeys.ScopeEmployeesRead}, LastUsedAt: &recently}, nil)
+				store.EXPECT().GetServiceAccountByID(gomock.Any(), int64(3)).Times(1).Return(account, nil)
+				// the last use is only written once a minute
+				store.EXPECT().TouchKey(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+		},
+		{
+			name:  "Expired",
+			plain: plain,
+			buildStubs: func(store *mockdb.MockAPIKeyStore) {
+				store.EXPECT().GetKeyByHash(gomock.Any(), secret.Hash(plain)).Times(1).Return(apikeys.Key{ID: "k1", ServiceAccountID: 3, ExpiresAt: &expired}, nil)
+			},
+			wantErr: &apikeys.InvalidKeyError{},
+		},
+		{
+			name:  "Unknown",
+			plain: plain,
+			buildStubs: func(store *mockdb.MockAPIKeyStore) {
+				store.EXPECT().GetKeyByHash(gomock.Any(), secret.Hash(plain)).Times(1).Return(apikeys.Key{}, &apikeys.KeyNotFoundError{})
+			},
+			wantErr: &apikeys.InvalidKeyError{},
+		},
+		{
+			name:  "NotAnAPIKey",
+			plain: "eyJhbGciOiJIUzI1NiJ9",
+			buildStubs: func(store *mockdb.MockAPIKeyStore) {
+				store.EXPECT().GetKeyByHash(gomock.Any(), gomock.Any()).Times(0)
+			},
+			wantErr: &apikeys.InvalidKeyError{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockAPIKeyStore(ctrl)
+			tc.buildStubs(store)
+
+			principal, err := apikeys.NewManager(store).Authenticate(context.Background(), tc.plain)
+			if tc.wantErr != nil {
+				assert.IsType(t, tc.wantErr, err)
+				assert.Nil(t, principal)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "payroll", principal.ServiceAccount)
+			assert.Equal(t, "k1", principal.KeyID)
+			assert.True(t, principal.HasScope(apikeys.ScopeEmployeesRead))
+			assert.False(t, principal.HasScope(apikeys.ScopeEmployeesWrite))
+		})
+	}
+}
@@ -0,0 +1,255 @@
+package apikeys
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ServiceAccount is a non-human client of the API, such as an integration. It
+// authenticates with its API keys instead of logging in.
+type ServiceAccount struct {
+	ID        int64
+	Name      string
+	CreatedAt time.Time
+}
+
+// Key is an API key of a service account. Only the SHA-256 of the key is
+// stored, Prefix is kept so that admins can tell keys apart.
+type Key struct {
+	ID               string
+	ServiceAccountID int64
+	Name             string
+	Prefix           string
+	KeyHash          string
+	Scopes           []Scope
+	CreatedAt        time.Time
+	ExpiresAt        *time.Time
+	LastUsedAt       *time.Time
+}
+
+type Store interface {
+	CreateServiceAccount(ctx context.Context, account ServiceAccount) (int64, error)
+	GetServiceAccounts(ctx context.Context) ([]ServiceAccount, error)
+	GetServiceAccountByID(ctx context.Context, id int64) (ServiceAccount, error)
+	// DeleteServiceAccount deletes the account together with its keys
+	DeleteServiceAccount(ctx context.Context, id int64) error
+	SaveKey(ctx context.Context, key Key) error
+	GetKeyByHash(ctx context.Context, hash string) (Key, error)
+	GetKeysByServiceAccount(ctx context.Context, serviceAccountID int64) ([]Key, error)
+	TouchKey(ctx context.Context, id string, at time.Time) error
+	DeleteKey(ctx context.Context, id string) error
+}
+
+type APIKeyStore struct {
+	store *sql.DB
+}
+
+func NewAPIKeyStore(db *sql.DB) Store {
+	return &APIKeyStore{
+		store: db,
+	}
+}
+
+const selectKey = `
+	SELECT ID, Service_Account_Id, Name, Prefix, Key_Hash, Scopes, Created_At, Expires_At, Last_Used_At
+	FROM Api_Keys
+	`
+
+// CreateServiceAccount implements Store.
+func (s *APIKeyStore) CreateServiceAccount(ctx context.Context, account ServiceAccount) (int64, error) {
+	_, err := s.store.ExecContext(
+		ctx,
+		"INSERT INTO Service_Accounts (Name, Created_At) VALUES (@Name, @Created_At)",
+		sql.Named("Name", account.Name),
+		sql.Named("Created_At", account.CreatedAt))
+	if err != nil {
+		return 0, err
+	}
+	// names are unique, which finds the new ID the same way on every database
+	row := s.store.QueryRowContext(ctx, "SELECT ID FROM Service_Accounts WHERE Name = @Name", sql.Named("Name", account.Name))
+	var id int64
+	if err := row.Scan(&id); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// GetServiceAccounts implements Store.
+func (s *APIKeyStore) GetServiceAccounts(ctx context.Context) ([]ServiceAccount, error) {
+	rows, err := s.store.QueryContext(ctx, "SELECT ID, Name, Created_At FROM Service_Accounts ORDER BY Name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accounts []ServiceAccount
+	for rows.Next() {
+		var a ServiceAccount
+		if err := rows.Scan(&a.ID, &a.Name, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, a)
+	}
+	return accounts, rows.Err()
+}
+
+// GetServiceAccountByID implements Store.
+func (s *APIKeyStore) GetServiceAccountByID(ctx context.Context, id int64) (ServiceAccount, error) {
+	row := s.store.QueryRowContext(ctx, "SELECT ID, Name, Created_At FROM Service_Accounts WHERE ID = @ID", sql.Named("ID", id))
+	var a ServiceAccount
+	if err := row.Scan(&a.ID, &a.Name, &a.CreatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ServiceAccount{}, &ServiceAccountNotFoundError{}
+		}
+		return ServiceAccount{}, err
+	}
+	return a, nil
+}
+
+// DeleteServiceAccount implements Store.
+func (s *APIKeyStore) DeleteServiceAccount(ctx context.Context, id int64) error {
+	tx, err := s.store.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM Api_Keys WHERE Service_Account_Id = @ID", sql.Named("ID", id)); err != nil {
+		return err
+	}
+	result, err := tx.ExecContext(ctx, "DELETE FROM Service_Accounts WHERE ID = @ID", sql.Named("ID", id))
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return &ServiceAccountNotFoundError{}
+	}
+	return tx.Commit()
+}
+
+// SaveKey implements Store.
+func (s *APIKeyStore) SaveKey(ctx context.Context, key Key) error {
+	tsql := `
+	INSERT INTO Api_Keys (ID, Service_Account_Id, Name, Prefix, Key_Hash, Scopes, Created_At, Expires_At)
+	VALUES (@ID, @Service_Account_Id, @Name, @Prefix, @Key_Hash, @Scopes, @Created_At, @Expires_At)
+	`
+	var expiresAt sql.NullTime
+	if key.ExpiresAt != nil {
+		expiresAt = sql.NullTime{Time: *key.ExpiresAt, Valid: true}
+	}
+	_, err := s.store.ExecContext(
+		ctx,
+		tsql,
+		sql.Named("ID", key.ID),
+		sql.Named("Service_Account_Id", key.ServiceAccountID),
+		sql.Named("Name", key.Name),
+		sql.Named("Prefix", key.Prefix),
+		sql.Named("Key_Hash", key.KeyHash),
+		sql.Named("Scopes", joinScopes(key.Scopes)),
+		sql.Named("Created_At", key.CreatedAt),
+		sql.Named("Expires_At", expiresAt))
+	return err
+}
+
+// GetKeyByHash implements Store.
+func (s *APIKeyStore) GetKeyByHash(ctx context.Context, hash string) (Key, error) {
+	row := s.store.QueryRowContext(ctx, selectKey+"WHERE Key_Hash = @Key_Hash", sql.Named("Key_Hash", hash))
+	key, err := scanKey(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Key{}, &KeyNotFoundError{}
+		}
+		return Key{}, err
+	}
+	return key, nil
+}
+
+// GetKeysByServiceAccount implements Store.
+func (s *APIKeyStore) GetKeysByServiceAccount(ctx context.Context, serviceAccountID int64) ([]Key, error) {
+	rows, err := s.store.QueryContext(
+		ctx,
+		selectKey+"WHERE Service_Account_Id = @Service_Account_Id ORDER BY Created_At",
+		sql.Named("Service_Account_Id", serviceAccountID))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []Key
+	for rows.Next() {
+		key, err := scanKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// TouchKey implements Store.
+func (s *APIKeyStore) TouchKey(ctx context.Context, id string, at time.Time) error {
+	_, err := s.store.ExecContext(ctx, "UPDATE Api_Keys SET Last_Used_At = @At WHERE ID = @ID", sql.Named("At", at), sql.Named("ID", id))
+	return err
+}
+
+// DeleteKey implements Store.
+func (s *APIKeyStore) DeleteKey(ctx context.Context, id string) error {
+	result, err := s.store.ExecContext(ctx, "DELETE FROM Api_Keys WHERE ID = @ID", sql.Named("ID", id))
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return &KeyNotFoundError{}
+	}
+	return nil
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanKey(row scanner) (Key, error) {
+	var k Key
+	var scopes string
+	var expiresAt, lastUsedAt sql.NullTime
+	err := row.Scan(&k.ID, &k.ServiceAccountID, &k.Name, &k.Prefix, &k.KeyHash, &scopes, &k.CreatedAt, &expiresAt, &lastUsedAt)
+	if err != nil {
+		return Key{}, err
+	}
+	k.Scopes = splitScopes(scopes)
+	if expiresAt.Valid {
+		k.ExpiresAt = &expiresAt.Time
+	}
+	if lastUsedAt.Valid {
+		k.LastUsedAt = &lastUsedAt.Time
+	}
+	return k, nil
+}
+
+// scopes are stored space separated, like the scope parameter of OAuth
+func joinScopes(scopes []Scope) string {
+	s := make([]string, len(scopes))
+	for i, scope := range scopes {
+		s[i] = string(scope)
+	}
+	return strings.Join(s, " ")
+}
+
+func splitScopes(s string) []Scope {
+	var scopes []Scope
+	for _, scope := range strings.Fields(s) {
+		scopes = append(scopes, Scope(scope))
+	}
+	return scopes
+}
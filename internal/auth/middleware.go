@@ -2,52 +2,122 @@ package auth
 
 import (
 	"context"
+	"errors"
 	"log"
 	"net/http"
 	"strings"
 
+	"github.com/pascaloseko/ems/internal/apikeys"
 	"github.com/pascaloseko/ems/internal/employees"
 	"github.com/pascaloseko/ems/internal/pkg/jwt"
+	"github.com/pascaloseko/ems/internal/revocation"
+	"github.com/pascaloseko/ems/internal/sessions"
 )
 
-var userCtxKey = &contextKey{"user"}
+var (
+	userCtxKey    = &contextKey{"user"}
+	tokenCtxKey   = &contextKey{"token"}
+	serviceCtxKey = &contextKey{"service"}
+)
+
+// APIKeyHeader carries the API key of a service account, the Authorization
+// header with the ApiKey scheme works too
+const APIKeyHeader = "X-API-Key"
 
 type contextKey struct {
 	name string
 }
 
 func splitBearer(header string) string {
+	return splitScheme(header, "bearer")
+}
+
+func splitScheme(header, scheme string) string {
 	parts := strings.Split(header, " ")
-	if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
+	if len(parts) != 2 || strings.ToLower(parts[0]) != scheme {
 		return ""
 	}
 	return parts[1]
 }
 
-func Middleware(emp employees.Store) func(http.Handler) http.Handler {
+// apiKey returns the API key the request carries, if any
+func apiKey(r *http.Request) string {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		return key
+	}
+	return splitScheme(r.Header.Get("Authorization"), "apikey")
+}
+
+// Middleware rejects any request that does not carry a valid, unrevoked bearer token
+// or API key, and records the activity of the session the token belongs to
+func Middleware(emp employees.Store, tokens *jwt.Issuer, denylist *revocation.Denylist, sessions *sessions.Manager, keys *apikeys.Manager) func(http.Handler) http.Handler {
+	return middleware(emp, tokens, denylist, sessions, keys, false)
+}
+
+// OptionalMiddleware lets requests without an Authorization header through
+// anonymously, so that public operations such as the login mutation can be
+// served. Resolvers must check ForContext themselves.
+func OptionalMiddleware(emp employees.Store, tokens *jwt.Issuer, denylist *revocation.Denylist, sessions *sessions.Manager, keys *apikeys.Manager) func(http.Handler) http.Handler {
+	return middleware(emp, tokens, denylist, sessions, keys, true)
+}
+
+func middleware(emp employees.Store, tokens *jwt.Issuer, denylist *revocation.Denylist, sessions *sessions.Manager, keys *apikeys.Manager, optional bool) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if key := apiKey(r); key != "" {
+				service, err := keys.Authenticate(r.Context(), key)
+				if err != nil {
+					var invalid *apikeys.InvalidKeyError
+					if !errors.As(err, &invalid) {
+						log.Printf("Authenticate API key: %v", err)
+					}
+					http.Error(w, "Invalid API key", http.StatusForbidden)
+					return
+				}
+				// service accounts are not employees, ForContext stays nil for them
+				ctx := context.WithValue(r.Context(), serviceCtxKey, service)
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
 			header := r.Header.Get("Authorization")
+			if header == "" && optional {
+				next.ServeHTTP(w, r)
+				return
+			}
 
 			//validate jwt token
 			tokenStr := splitBearer(header)
-			username, err := jwt.ParseToken(tokenStr)
+			claims, err := tokens.ParseToken(tokenStr)
 			if err != nil {
 				http.Error(w, "Invalid token", http.StatusForbidden)
 				return
 			}
 
-			user := employees.Employee{Username: username}
-			id, err := emp.GetEmployeeIdByUsername(r.Context(), username)
-			if err != nil || id == 0 {
+			user := employees.Employee{Username: claims.Username, Role: employees.Role(claims.Role)}
+			if !user.Role.Valid() {
+				user.Role = employees.RoleEmployee
+			}
+			// the username must still belong to the employee the token was
+			// issued to, it may have been renamed or deleted and taken since
+			id, err := emp.GetEmployeeIdByUsername(r.Context(), claims.Username)
+			if err != nil || id == 0 || id != claims.EmployeeID {
 				log.Printf("GetEmployeeIdByUsername: %v", err)
 				http.Error(w, "Invalid token: user not found", http.StatusForbidden)
 				return
 			}
+			if denylist.IsRevoked(claims, id) {
+				http.Error(w, "Invalid token: revoked", http.StatusForbidden)
+				return
+			}
+			if claims.SessionID != "" {
+				sessions.Touch(r.Context(), claims.SessionID)
+			}
 
 			user.ID = id
 			// put it in context
 			ctx := context.WithValue(r.Context(), userCtxKey, &user)
+			ctx = context.WithValue(ctx, tokenCtxKey, claims)
 
 			// and call the next with our new context
 			r = r.WithContext(ctx)
@@ -63,3 +133,21 @@ func ForContext(ctx context.Context) *employees.Employee {
 	raw, _ := ctx.Value(userCtxKey).(*employees.Employee)
 	return raw
 }
+
+// ServiceForContext finds the service account of a request made with an API key
+func ServiceForContext(ctx context.Context) *apikeys.Principal {
+	raw, _ := ctx.Value(serviceCtxKey).(*apikeys.Principal)
+	return raw
+}
+
+// Authenticated reports whether the request was made by an employee or a
+// service account
+func Authenticated(ctx context.Context) bool {
+	return ForContext(ctx) != nil || ServiceForContext(ctx) != nil
+}
+
+// TokenForContext finds the claims of the token the request was authenticated with
+func TokenForContext(ctx context.Context) *jwt.Claims {
+	raw, _ := ctx.Value(tokenCtxKey).(*jwt.Claims)
+	return raw
+}
@@ -1,26 +1,38 @@
 package auth
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
+	"github.com/pascaloseko/ems/internal/apikeys"
 	"github.com/pascaloseko/ems/internal/employees"
 	"github.com/pascaloseko/ems/internal/mockdb"
 	"github.com/pascaloseko/ems/internal/pkg/jwt"
+	"github.com/pascaloseko/ems/internal/revocation"
+	"github.com/pascaloseko/ems/internal/sessions"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
+func newTestIssuer(t *testing.T) *jwt.Issuer {
+	issuer, err := jwt.NewIssuer(jwt.Config{Algorithm: jwt.HS256, Secret: []byte("test-secret")})
+	require.NoError(t, err)
+	return issuer
+}
+
 func addAuthorization(
 	t *testing.T,
+	issuer *jwt.Issuer,
 	request *http.Request,
 	authorizationType string,
 	username string,
 ) {
-	tkn, err := jwt.GenerateToken(username)
+	tkn, err := issuer.GenerateToken(1, username, string(employees.RoleHR))
 	require.NoError(t, err)
 	require.NotEmpty(t, tkn)
 
@@ -37,7 +49,7 @@ func TestMiddleware(t *testing.T) {
 		args          args
 		want          *employees.Employee
 		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
-		setupAuth     func(t *testing.T, request *http.Request)
+		setupAuth     func(t *testing.T, issuer *jwt.Issuer, request *http.Request)
 	}{
 		{
 			name: "valid token",
@@ -49,12 +61,13 @@ func TestMiddleware(t *testing.T) {
 			want: &employees.Employee{
 				Username: "pascal",
 				ID:       int64(1),
+				Role:     employees.RoleHR,
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusOK, recorder.Code)
 			},
-			setupAuth: func(t *testing.T, request *http.Request) {
-				addAuthorization(t, request, "bearer", "pascal")
+			setupAuth: func(t *testing.T, issuer *jwt.Issuer, request *http.Request) {
+				addAuthorization(t, issuer, request, "bearer", "pascal")
 			},
 		},
 		{
@@ -68,7 +81,23 @@ func TestMiddleware(t *testing.T) {
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusForbidden, recorder.Code)
 			},
-			setupAuth: func(t *testing.T, request *http.Request) {
+			setupAuth: func(t *testing.T, issuer *jwt.Issuer, request *http.Request) {
+			},
+		},
+		{
+			// pascal was deleted or renamed and someone else took the username
+			name: "username taken by another employee",
+			args: args{
+				buildStubs: func(store *mockdb.MockStore) {
+					store.EXPECT().GetEmployeeIdByUsername(gomock.Any(), "pascal").AnyTimes().Return(int64(2), nil)
+				},
+			},
+			want: nil,
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+			setupAuth: func(t *testing.T, issuer *jwt.Issuer, request *http.Request) {
+				addAuthorization(t, issuer, request, "bearer", "pascal")
 			},
 		},
 		{
@@ -82,7 +111,7 @@ func TestMiddleware(t *testing.T) {
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusForbidden, recorder.Code)
 			},
-			setupAuth: func(t *testing.T, request *http.Request) {
+			setupAuth: func(t *testing.T, issuer *jwt.Issuer, request *http.Request) {
 			},
 		},
 	}
@@ -98,9 +127,10 @@ func TestMiddleware(t *testing.T) {
 			if err != nil {
 				t.Fatal(err)
 			}
-			tt.setupAuth(t, req)
+			issuer := newTestIssuer(t)
+			tt.setupAuth(t, issuer, req)
 			rr := httptest.NewRecorder()
-			handler := Middleware(store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handler := Middleware(store, issuer, revocation.NewDenylist(nil, issuer.MaxTokenAge()), sessions.NewManager(nil, 0), apikeys.NewManager(nil))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 				user := ForContext(r.Context())
 				assert.Equal(t, tt.want, user)
 			}))
@@ -109,3 +139,125 @@ func TestMiddleware(t *testing.T) {
 		})
 	}
 }
+
+func TestOptionalMiddleware(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+
+	req, err := http.NewRequest("POST", "/query", nil)
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	called := false
+	issuer := newTestIssuer(t)
+	handler := OptionalMiddleware(store, issuer, revocation.NewDenylist(nil, issuer.MaxTokenAge()), sessions.NewManager(nil, 0), apikeys.NewManager(nil))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		assert.Nil(t, ForContext(r.Context()))
+	}))
+	handler.ServeHTTP(rr, req)
+	require.True(t, called)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	// a token that is present but invalid is still rejected
+	req.Header.Set("Authorization", "bearer not-a-token")
+	rr = httptest.NewRecorder()
+	called = false
+	handler.ServeHTTP(rr, req)
+	require.False(t, called)
+	require.Equal(t, http.StatusForbidden, rr.Code)
+}
+
+func TestMiddlewareRevokedToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().GetEmployeeIdByUsername(gomock.Any(), "pascal").AnyTimes().Return(int64(1), nil)
+	revocationStore := mockdb.NewMockRevocationStore(ctrl)
+	revocationStore.EXPECT().RevokeToken(gomock.Any(), gomock.Any()).Times(1).Return(nil)
+
+	issuer := newTestIssuer(t)
+	denylist := revocation.NewDenylist(revocationStore, issuer.MaxTokenAge())
+	var claims *jwt.Claims
+	handler := Middleware(store, issuer, denylist, sessions.NewManager(nil, 0), apikeys.NewManager(nil))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims = TokenForContext(r.Context())
+	}))
+
+	req, err := http.NewRequest("GET", "/", nil)
+	require.NoError(t, err)
+	addAuthorization(t, issuer, req, "bearer", "pascal")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NotNil(t, claims)
+
+	require.NoError(t, denylist.RevokeToken(context.Background(), claims))
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusForbidden, rr.Code)
+}
+
+func TestMiddlewareAPIKey(t *testing.T) {
+	const key = "ems_0123456789abcdef"
+	tests := []struct {
+		name       string
+		setHeader  func(request *http.Request)
+		found      bool
+		wantStatus int
+	}{
+		{
+			name:       "X-API-Key header",
+			setHeader:  func(request *http.Request) { request.Header.Set(APIKeyHeader, key) },
+			found:      true,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "ApiKey scheme",
+			setHeader:  func(request *http.Request) { request.Header.Set("Authorization", "ApiKey "+key) },
+			found:      true,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "unknown key",
+			setHeader:  func(request *http.Request) { request.Header.Set(APIKeyHeader, key) },
+			wantStatus: http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			keyStore := mockdb.NewMockAPIKeyStore(ctrl)
+			if tt.found {
+				now := time.Now()
+				keyStore.EXPECT().GetKeyByHash(gomock.Any(), gomock.Any()).Times(1).Return(apikeys.Key{ID: "k1", ServiceAccountID: 3, Scopes: []apikeys.Scope{apikeys.ScopeEmployeesRead}, LastUsedAt: &now}, nil)
+				keyStore.EXPECT().GetServiceAccountByID(gomock.Any(), int64(3)).Times(1).Return(apikeys.ServiceAccount{ID: 3, Name: "payroll"}, nil)
+			} else {
+				keyStore.EXPECT().GetKeyByHash(gomock.Any(), gomock.Any()).Times(1).Return(apikeys.Key{}, &apikeys.KeyNotFoundError{})
+			}
+
+			issuer := newTestIssuer(t)
+			called := false
+			handler := OptionalMiddleware(store, issuer, revocation.NewDenylist(nil, issuer.MaxTokenAge()), sessions.NewManager(nil, 0), apikeys.NewManager(keyStore))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+				// service accounts are never mistaken for employees
+				assert.Nil(t, ForContext(r.Context()))
+				service := ServiceForContext(r.Context())
+				require.NotNil(t, service)
+				assert.Equal(t, "payroll", service.ServiceAccount)
+				assert.True(t, Authenticated(r.Context()))
+			}))
+
+			req, err := http.NewRequest("POST", "/query", nil)
+			require.NoError(t, err)
+			tt.setHeader(req)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			require.Equal(t, tt.wantStatus, rr.Code)
+			require.Equal(t, tt.found, called)
+		})
+	}
+}
@@ -0,0 +1,298 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pascaloseko/ems/internal/employees"
+	"github.com/pascaloseko/ems/internal/lockout"
+	"github.com/pascaloseko/ems/internal/mail"
+	"github.com/pascaloseko/ems/internal/oidc"
+	"github.com/pascaloseko/ems/internal/pkg/db/database"
+	"github.com/pascaloseko/ems/internal/pkg/jwt"
+)
+
+const (
+	defaultPort                    = "8080"
+	defaultRevocationSweepInterval = time.Minute
+	defaultMailFrom                = "no-reply@localhost"
+	defaultPasswordMinLength       = 10
+	defaultPasswordMinClasses      = 2
+)
+
+// Config holds the server settings, read from the environment
+type Config struct {
+	Port string
+	// DatabaseURL selects the database and its driver, see database.ParseURL
+	DatabaseURL string
+	JWT         jwt.Config
+	// KeyRotationInterval rotates the JWT signing key on a schedule when set
+	KeyRotationInterval time.Duration
+	// RefreshTokenTTL is how long an unused refresh token stays valid
+	RefreshTokenTTL time.Duration
+	// RevocationSweepInterval is how often expired denylist entries are pruned
+	// and revocations made by other replicas are picked up
+	RevocationSweepInterval time.Duration
+
+	// SMTP is used to send email when SMTP.Host is set, otherwise emails are
+	// written to MailFile, or stdout when that is empty too
+	SMTP     mail.SMTPConfig
+	MailFile string
+	// PasswordResetTTL is how long a password reset token can be used
+	PasswordResetTTL time.Duration
+	// PasswordResetURL is the page password reset emails link to
+	PasswordResetURL string
+
+	// PasswordMinLength and PasswordMinClasses are the password policy, see
+	// passwords.Policy
+	PasswordMinLength  int
+	PasswordMinClasses int
+	// PasswordDenylistFile lists passwords to reject on top of the built in
+	// common ones, one per line
+	PasswordDenylistFile string
+	// PasswordHashing is how new passwords are hashed, stored hashes made
+	// differently are replaced at the next login
+	PasswordHashing employees.HasherConfig
+
+	// Lockout limits failed logins per account and per client IP
+	Lockout lockout.Config
+	// TOTPIssuer is the name authenticator apps show for EMS accounts
+	TOTPIssuer string
+	// OpenRegistration lets anyone sign up through /register and
+	// registerEmployee, otherwise only HR can
+	OpenRegistration bool
+
+	// OIDC enables single sign-on through an identity provider when
+	// OIDC.IssuerURL is set
+	OIDC oidc.Config
+}
+
+// Load reads the configuration from environment variables:
+//
+//	PORT                  port to listen on (default 8080)
+//	EMS_DATABASE_URL      sqlserver://..., postgres://... or sqlite:<file> (default the SQL Server of docker-compose.yaml)
+//	EMS_JWT_ALGORITHM     HS256, RS256 or ES256 (default HS256)
+//	EMS_JWT_SECRET        HMAC secret, required for HS256
+//	EMS_JWT_PRIVATE_KEY   path to the PEM private key, required for RS256 and ES256
+//	EMS_JWT_PREVIOUS_KEYS comma separated paths of retired keys whose tokens are still accepted
+//	EMS_JWT_KEY_DIR       directory shared by every replica where rotated signing keys are kept
+//	EMS_JWT_ROTATION_INTERVAL  generate a new RS256/ES256 signing key this often, e.g. 24h, requires EMS_JWT_KEY_DIR
+//	EMS_JWT_ISSUER        iss claim, checked on every token when set
+//	EMS_JWT_AUDIENCE      aud claim, checked on every token when set
+//	EMS_JWT_ACCESS_TTL    access token lifetime, e.g. 5m (default 15m)
+//	EMS_REFRESH_TOKEN_TTL refresh token lifetime (default 168h)
+//	EMS_JWT_CLOCK_SKEW    leeway when checking token times (default 0)
+//	EMS_REVOCATION_SWEEP_INTERVAL  how often the token denylist is pruned and synced (default 1m)
+//	EMS_SMTP_HOST, EMS_SMTP_PORT   SMTP relay used to send email (port defaults to 587)
+//	EMS_SMTP_USERNAME, EMS_SMTP_PASSWORD  SMTP credentials, optional
+//	EMS_MAIL_FROM         sender address (default no-reply@localhost)
+//	EMS_MAIL_FILE         file emails are appended to when no SMTP host is set (default stdout)
+//	EMS_PASSWORD_RESET_TTL  how long password reset tokens last (default 1h)
+//	EMS_PASSWORD_RESET_URL  page the password reset link points at, the token is added as ?token=
+//	EMS_PASSWORD_MIN_LENGTH   shortest password accepted (default 10)
+//	EMS_PASSWORD_MIN_CLASSES  how many of lowercase, uppercase, digits and symbols a password mixes (default 2)
+//	EMS_PASSWORD_DENYLIST_FILE  file of extra passwords to reject, one per line
+//	EMS_PASSWORD_HASH         argon2id or bcrypt (default argon2id)
+//	EMS_BCRYPT_COST           bcrypt cost (default 10)
+//	EMS_ARGON2_MEMORY         argon2id memory in KiB (default 19456)
+//	EMS_ARGON2_ITERATIONS     argon2id passes over the memory (default 2)
+//	EMS_ARGON2_PARALLELISM    argon2id threads (default 1)
+//	EMS_PASSWORD_HASH_CONCURRENCY  passwords hashed at once, further logins wait (default number of CPUs)
+//	EMS_LOCKOUT_THRESHOLD     failed logins in a row that lock an account (default 5)
+//	EMS_LOCKOUT_DURATION      how long the first lock lasts, doubling with every further failure (default 1m)
+//	EMS_LOCKOUT_MAX_DURATION  longest lock (default 1h)
+//	EMS_LOCKOUT_RESET_AFTER   time without failed logins after which they are forgotten (default 24h)
+//	EMS_LOGIN_CLIENT_LIMIT    failed logins a client IP may make per window (default 50)
+//	EMS_LOGIN_CLIENT_WINDOW   window of EMS_LOGIN_CLIENT_LIMIT (default 15m)
+//	EMS_TOTP_ISSUER       name of EMS in authenticator apps (default EMS)
+//	EMS_OPEN_REGISTRATION  let anyone sign up through /register and registerEmployee (default false)
+//	EMS_OIDC_ISSUER       issuer URL of the identity provider, enables single sign-on
+//	EMS_OIDC_CLIENT_ID, EMS_OIDC_CLIENT_SECRET  client EMS is registered as with the provider
+//	EMS_OIDC_REDIRECT_URL  public URL of /auth/oidc/callback
+//	EMS_OIDC_SCOPES       comma separated scopes to request (default openid,email,profile)
+func Load() (*Config, error) {
+	cfg := &Config{
+		Port:        getenv("PORT", defaultPort),
+		DatabaseURL: getenv("EMS_DATABASE_URL", database.DefaultURL),
+		JWT: jwt.Config{
+			Algorithm:        getenv("EMS_JWT_ALGORITHM", jwt.HS256),
+			Secret:           []byte(os.Getenv("EMS_JWT_SECRET")),
+			PrivateKeyPath:   os.Getenv("EMS_JWT_PRIVATE_KEY"),
+			PreviousKeyPaths: list("EMS_JWT_PREVIOUS_KEYS"),
+			KeyDir:           os.Getenv("EMS_JWT_KEY_DIR"),
+			Issuer:           os.Getenv("EMS_JWT_ISSUER"),
+			Audience:         os.Getenv("EMS_JWT_AUDIENCE"),
+		},
+		SMTP: mail.SMTPConfig{
+			Host:     os.Getenv("EMS_SMTP_HOST"),
+			Port:     os.Getenv("EMS_SMTP_PORT"),
+			Username: os.Getenv("EMS_SMTP_USERNAME"),
+			Password: os.Getenv("EMS_SMTP_PASSWORD"),
+			From:     getenv("EMS_MAIL_FROM", defaultMailFrom),
+		},
+		MailFile:             os.Getenv("EMS_MAIL_FILE"),
+		PasswordResetURL:     os.Getenv("EMS_PASSWORD_RESET_URL"),
+		PasswordDenylistFile: os.Getenv("EMS_PASSWORD_DENYLIST_FILE"),
+		TOTPIssuer:           os.Getenv("EMS_TOTP_ISSUER"),
+		PasswordHashing: employees.HasherConfig{
+			Algorithm: getenv("EMS_PASSWORD_HASH", employees.Argon2id),
+		},
+		OIDC: oidc.Config{
+			IssuerURL:    os.Getenv("EMS_OIDC_ISSUER"),
+			ClientID:     os.Getenv("EMS_OIDC_CLIENT_ID"),
+			ClientSecret: os.Getenv("EMS_OIDC_CLIENT_SECRET"),
+			RedirectURL:  os.Getenv("EMS_OIDC_REDIRECT_URL"),
+			Scopes:       list("EMS_OIDC_SCOPES"),
+		},
+	}
+
+	var err error
+	if cfg.JWT.AccessTTL, err = duration("EMS_JWT_ACCESS_TTL"); err != nil {
+		return nil, err
+	}
+	if cfg.RefreshTokenTTL, err = duration("EMS_REFRESH_TOKEN_TTL"); err != nil {
+		return nil, err
+	}
+	if cfg.JWT.ClockSkew, err = duration("EMS_JWT_CLOCK_SKEW"); err != nil {
+		return nil, err
+	}
+	if cfg.KeyRotationInterval, err = duration("EMS_JWT_ROTATION_INTERVAL"); err != nil {
+		return nil, err
+	}
+	if cfg.KeyRotationInterval > 0 && cfg.JWT.KeyDir == "" {
+		return nil, fmt.Errorf("config: EMS_JWT_ROTATION_INTERVAL requires EMS_JWT_KEY_DIR")
+	}
+	if cfg.RevocationSweepInterval, err = duration("EMS_REVOCATION_SWEEP_INTERVAL"); err != nil {
+		return nil, err
+	}
+	if cfg.RevocationSweepInterval <= 0 {
+		cfg.RevocationSweepInterval = defaultRevocationSweepInterval
+	}
+	if cfg.PasswordResetTTL, err = duration("EMS_PASSWORD_RESET_TTL"); err != nil {
+		return nil, err
+	}
+	if cfg.PasswordMinLength, err = integer("EMS_PASSWORD_MIN_LENGTH", defaultPasswordMinLength); err != nil {
+		return nil, err
+	}
+	if cfg.PasswordMinClasses, err = integer("EMS_PASSWORD_MIN_CLASSES", defaultPasswordMinClasses); err != nil {
+		return nil, err
+	}
+	if err := loadHasherConfig(&cfg.PasswordHashing); err != nil {
+		return nil, err
+	}
+	if cfg.Lockout.Threshold, err = integer("EMS_LOCKOUT_THRESHOLD", 0); err != nil {
+		return nil, err
+	}
+	if cfg.Lockout.LockDuration, err = duration("EMS_LOCKOUT_DURATION"); err != nil {
+		return nil, err
+	}
+	if cfg.Lockout.MaxLockDuration, err = duration("EMS_LOCKOUT_MAX_DURATION"); err != nil {
+		return nil, err
+	}
+	if cfg.Lockout.ResetAfter, err = duration("EMS_LOCKOUT_RESET_AFTER"); err != nil {
+		return nil, err
+	}
+	if cfg.Lockout.ClientLimit, err = integer("EMS_LOGIN_CLIENT_LIMIT", 0); err != nil {
+		return nil, err
+	}
+	if cfg.Lockout.ClientWindow, err = duration("EMS_LOGIN_CLIENT_WINDOW"); err != nil {
+		return nil, err
+	}
+	if cfg.OpenRegistration, err = boolean("EMS_OPEN_REGISTRATION"); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func loadHasherConfig(cfg *employees.HasherConfig) error {
+	var err error
+	if cfg.BcryptCost, err = integer("EMS_BCRYPT_COST", 0); err != nil {
+		return err
+	}
+	if cfg.Concurrency, err = integer("EMS_PASSWORD_HASH_CONCURRENCY", 0); err != nil {
+		return err
+	}
+	params := employees.DefaultArgon2Params
+	memory, err := integer("EMS_ARGON2_MEMORY", int(params.Memory))
+	if err != nil {
+		return err
+	}
+	iterations, err := integer("EMS_ARGON2_ITERATIONS", int(params.Iterations))
+	if err != nil {
+		return err
+	}
+	parallelism, err := integer("EMS_ARGON2_PARALLELISM", int(params.Parallelism))
+	if err != nil {
+		return err
+	}
+	if memory > math.MaxUint32 || iterations > math.MaxUint32 || parallelism > math.MaxUint8 {
+		return fmt.Errorf("config: argon2 parameters out of range")
+	}
+	cfg.Argon2 = employees.Argon2Params{
+		Memory:      uint32(memory),
+		Iterations:  uint32(iterations),
+		Parallelism: uint8(parallelism),
+	}
+	return nil
+}
+
+func getenv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// list splits the comma separated environment variable key
+func list(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// duration parses the environment variable key, returning zero when it is unset
+func duration(key string) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("config: invalid %s: %w", key, err)
+	}
+	return d, nil
+}
+
+// boolean parses the environment variable key, returning false when it is unset
+func boolean(key string) (bool, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("config: invalid %s: %q", key, value)
+	}
+	return b, nil
+}
+
+// integer parses the environment variable key, returning fallback when it is unset
+func integer(key string, fallback int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("config: invalid %s: %q", key, value)
+	}
+	return n, nil
+}
@@ -0,0 +1,92 @@
+package employees
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	mssqldb "github.com/microsoft/go-mssqldb"
+)
+
+// violation is the kind of constraint a statement broke
+type violation int
+
+const (
+	noViolation violation = iota
+	uniqueViolation
+	foreignKeyViolation
+)
+
+// constraintViolation reports which kind of constraint err broke, if any, along
+// with the message naming the index or constraint
+func constraintViolation(err error) (violation, string) {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "23505":
+			return uniqueViolation, pgErr.ConstraintName
+		case "23503":
+			return foreignKeyViolation, pgErr.ConstraintName
+		}
+		return noViolation, ""
+	}
+	var msErr mssqldb.Error
+	if errors.As(err, &msErr) {
+		switch msErr.Number {
+		case 2601, 2627:
+			return uniqueViolation, msErr.Message
+		case 547:
+			return foreignKeyViolation, msErr.Message
+		}
+		return noViolation, ""
+	}
+	// the error type of the SQLite driver only exists in cgo builds, its
+	// messages name the columns of a unique index
+	message := err.Error()
+	switch {
+	case strings.HasPrefix(message, "UNIQUE constraint failed"):
+		return uniqueViolation, message
+	case strings.HasPrefix(message, "FOREIGN KEY constraint failed"):
+		return foreignKeyViolation, message
+	}
+	return noViolation, ""
+}
+
+// employeeError translates the constraint violations of saving or updating an
+// employee into ErrDuplicateUsername, ErrDuplicateEmail and ErrUnknownDepartment
+func employeeError(err error) error {
+	kind, name := constraintViolation(err)
+	switch {
+	case kind == uniqueViolation && strings.Contains(name, "username"):
+		return fmt.Errorf("%w: %v", ErrDuplicateUsername, err)
+	case kind == uniqueViolation && strings.Contains(name, "email"):
+		return fmt.Errorf("%w: %v", ErrDuplicateEmail, err)
+	case kind == foreignKeyViolation:
+		return fmt.Errorf("%w: %v", ErrUnknownDepartment, err)
+	}
+	return err
+}
+
+// errConflict fails a transaction that raced with another one, to be retried
+var errConflict = errors.New("conflict with a concurrent transaction")
+
+// retryable reports whether err ended a transaction that may succeed when run
+// again: it lost a race, a deadlock or serializability to another transaction,
+// or waited too long for SQLite's write lock
+func retryable(err error) bool {
+	if errors.Is(err, errConflict) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// serialization_failure and deadlock_detected
+		return pgErr.Code == "40001" || pgErr.Code == "40P01"
+	}
+	var msErr mssqldb.Error
+	if errors.As(err, &msErr) {
+		// chosen as deadlock victim
+		return msErr.Number == 1205
+	}
+	return strings.HasPrefix(err.Error(), "database is locked")
+}
@@ -0,0 +1,105 @@
+package employees
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	_ "github.com/mattn/go-sqlite3"
+	mssqldb "github.com/microsoft/go-mssqldb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmployeeError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"postgres username", &pgconn.PgError{Code: "23505", ConstraintName: "idx_employee_entities_username"}, ErrDuplicateUsername},
+		{"postgres email", &pgconn.PgError{Code: "23505", ConstraintName: "idx_employee_entities_email"}, ErrDuplicateEmail},
+		{"postgres department", &pgconn.PgError{Code: "23503", ConstraintName: "fk_employee_entities_department_id"}, ErrUnknownDepartment},
+		{
+			"sqlserver username",
+			mssqldb.Error{Number: 2601, Message: "Cannot insert duplicate key row in object 'dbo.employee_entities' with unique index 'idx_employee_entities_username'. The duplicate key value is (ada)."},
+			ErrDuplicateUsername,
+		},
+		{
+			"sqlserver email",
+			mssqldb.Error{Number: 2601, Message: "Cannot insert duplicate key row in object 'dbo.employee_entities' with unique index 'idx_employee_entities_email'. The duplicate key value is (ada@example.com)."},
+			ErrDuplicateEmail,
+		},
+		{
+			"sqlserver department",
+			mssqldb.Error{Number: 547, Message: `The INSERT statement conflicted with the FOREIGN KEY constraint "fk_employee_entities_department_id".`},
+			ErrUnknownDepartment,
+		},
+		{"sqlite username", errors.New("UNIQUE constraint failed: employee_entities.username"), ErrDuplicateUsername},
+		{"sqlite email", errors.New("UNIQUE constraint failed: employee_entities.email"), ErrDuplicateEmail},
+		{"sqlite department", errors.New("FOREIGN KEY constraint failed"), ErrUnknownDepartment},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := employeeError(tt.err)
+			assert.ErrorIs(t, err, tt.want)
+			assert.Contains(t, err.Error(), tt.err.Error(), "the driver's error is kept")
+		})
+	}
+
+	// other errors pass through untouched
+	other := &pgconn.PgError{Code: "40001"}
+	assert.Same(t, other, employeeError(other))
+	timeout := errors.New("i/o timeout")
+	assert.Equal(t, timeout, employeeError(timeout))
+}
+
+func TestRetryable(t *testing.T) {
+	assert.True(t, retryable(fmt.Errorf("%w: department %q was created meanwhile", errConflict, "Research")))
+	assert.True(t, retryable(&pgconn.PgError{Code: "40001"}))
+	assert.True(t, retryable(&pgconn.PgError{Code: "40P01"}))
+	assert.True(t, retryable(mssqldb.Error{Number: 1205}))
+	assert.True(t, retryable(errors.New("database is locked")))
+
+	assert.False(t, retryable(&pgconn.PgError{Code: "23505"}))
+	assert.False(t, retryable(mssqldb.Error{Number: 2601}))
+	assert.False(t, retryable(ErrDuplicateUsername))
+}
+
+func TestWithTxRetries(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+	store := NewSQLiteStore(db, nil)
+	ctx := context.Background()
+
+	attempts := 0
+	err = store.WithTx(ctx, func(Store) error {
+		attempts++
+		if attempts < 3 {
+			return errConflict
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+
+	attempts = 0
+	err = store.WithTx(ctx, func(Store) error {
+		attempts++
+		return errConflict
+	})
+	assert.ErrorIs(t, err, errConflict)
+	assert.Equal(t, maxTxRetries+1, attempts, "gives up")
+
+	attempts = 0
+	err = store.WithTx(ctx, func(Store) error {
+		attempts++
+		return ErrDuplicateEmail
+	})
+	assert.ErrorIs(t, err, ErrDuplicateEmail)
+	assert.Equal(t, 1, attempts, "other errors are not retried")
+}
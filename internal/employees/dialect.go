@@ -0,0 +1,76 @@
+package employees
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// dialect is the SQL that differs between the databases EmployeeStore runs on.
+// Every query uses @Name parameters, which the SQL Server and SQLite drivers
+// bind natively and the PostgreSQL connection of the database package rewrites.
+type dialect struct {
+	// returningID completes an INSERT so that it returns the new ID
+	returningID string
+	// page selects @Limit rows starting at @Offset of an ordered query
+	page string
+	// concat joins two string expressions
+	concat string
+	// like is the case insensitive LIKE operator
+	like string
+	// bracketWildcards is set when [ and ] are LIKE wildcards
+	bracketWildcards bool
+}
+
+var (
+	mssql = dialect{
+		returningID:      "; SELECT ID = convert(bigint, SCOPE_IDENTITY())",
+		page:             "OFFSET @Offset ROWS FETCH NEXT @Limit ROWS ONLY",
+		concat:           "+",
+		like:             "LIKE",
+		bracketWildcards: true,
+	}
+	postgres = dialect{
+		returningID: " RETURNING ID",
+		page:        "OFFSET @Offset ROWS FETCH NEXT @Limit ROWS ONLY",
+		concat:      "||",
+		like:        "ILIKE",
+	}
+	sqlite = dialect{
+		returningID: " RETURNING ID",
+		page:        "LIMIT @Limit OFFSET @Offset",
+		concat:      "||",
+		// LIKE ignores the case of ASCII letters in SQLite
+		like: "LIKE",
+	}
+)
+
+// NewPostgresStore returns a Store for a PostgreSQL database opened by the
+// database package
+func NewPostgresStore(db *sql.DB, hasher PasswordHasher) Store {
+	return &EmployeeStore{
+		store:   db,
+		db:      db,
+		hasher:  hasher,
+		decoy:   &decoyHash{},
+		dialect: postgres,
+	}
+}
+
+// NewSQLiteStore returns a Store for a SQLite database
+func NewSQLiteStore(db *sql.DB, hasher PasswordHasher) Store {
+	return &EmployeeStore{
+		store:   db,
+		db:      db,
+		hasher:  hasher,
+		decoy:   &decoyHash{},
+		dialect: sqlite,
+	}
+}
+
+// escapeLike escapes the LIKE wildcards in s so it is matched literally
+func (d dialect) escapeLike(s string) string {
+	if d.bracketWildcards {
+		return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`, `[`, `\[`).Replace(s)
+	}
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
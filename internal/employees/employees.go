@@ -4,91 +4,411 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"log"
+	"strings"
+	"time"
 
-	"golang.org/x/crypto/bcrypt"
+	"github.com/cenkalti/backoff/v4"
 )
 
+// maxTxRetries is how many times a transaction is retried after failing with a
+// retryable error
+const maxTxRetries = 5
+
 type Store interface {
 	GetEmployeeIdByUsername(ctx context.Context, username string) (int64, error)
 	GetDepartmentIdByName(ctx context.Context, name string) (int64, error)
-	GetDepartmentNameById(ctx context.Context, id int64) (string, error)
-	GetAllEmployees(ctx context.Context) ([]Employee, error)
+	FindEmployees(ctx context.Context, q EmployeeQuery) ([]Employee, int, error)
+	GetEmployeeByID(ctx context.Context, id int64) (Employee, error)
+	GetEmployeeByUsername(ctx context.Context, username string) (Employee, error)
+	GetEmployeeByEmail(ctx context.Context, email string) (Employee, error)
+	GetEmployeesByIDs(ctx context.Context, ids []int64) ([]Employee, error)
 	Save(ctx context.Context, emp Employee) (int64, error)
-	Authenticate(ctx context.Context, emp Employee) bool
+	Update(ctx context.Context, id int64, upd EmployeeUpdate) (Employee, error)
+	Delete(ctx context.Context, id int64) error
+	// Authenticate checks the password of emp.Username, failing with a
+	// *WrongUsernameOrPasswordError when the username or password is wrong
+	Authenticate(ctx context.Context, emp Employee) error
 	SaveDepartment(ctx context.Context, dept Department) (int64, error)
-	HashPassword(password string) string
+	GetDepartments(ctx context.Context) ([]Department, error)
+	GetDepartmentByID(ctx context.Context, id int64) (Department, error)
+	GetDepartmentsByIDs(ctx context.Context, ids []int64) ([]Department, error)
+	RenameDepartment(ctx context.Context, id int64, name string) (Department, error)
+	DeleteDepartment(ctx context.Context, id int64) error
+	HashPassword(ctx context.Context, password string) (string, error)
+	// WithTx runs fn with a Store whose reads and writes happen in one
+	// transaction, committed when fn returns nil and rolled back otherwise.
+	// fn may run more than once, as transactions that lose a deadlock or a
+	// race with another one are retried. Called on the Store given to fn, it
+	// runs fn in the same transaction.
+	WithTx(ctx context.Context, fn func(Store) error) error
+}
+
+// querier is implemented by both *sql.DB and *sql.Tx
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
 }
 
 type EmployeeStore struct {
-	store *sql.DB
+	store querier
+	// db begins transactions, it is nil for the store of one
+	db      *sql.DB
+	hasher  PasswordHasher
+	decoy   *decoyHash
+	dialect dialect
 }
 
-func NewEmployeeStore(db *sql.DB) Store {
+// NewEmployeeStore returns a Store for a SQL Server database, see
+// NewPostgresStore and NewSQLiteStore for the other databases
+func NewEmployeeStore(db *sql.DB, hasher PasswordHasher) Store {
 	return &EmployeeStore{
-		store: db,
+		store:   db,
+		db:      db,
+		hasher:  hasher,
+		decoy:   &decoyHash{},
+		dialect: mssql,
 	}
 }
 
-// SaveDepartment implements Store.
-func (e *EmployeeStore) SaveDepartment(ctx context.Context, d Department) (int64, error) {
-	tsql := `
-	INSERT INTO Department_Entities(Name) VALUES(@Name);
-	SELECT ID = convert(bigint, SCOPE_IDENTITY());
-	`
-	stmt, err := e.store.Prepare(tsql)
-	if err != nil {
-		return 0, err
+// WithTx implements Store.
+func (e *EmployeeStore) WithTx(ctx context.Context, fn func(Store) error) error {
+	return e.inTx(ctx, func(tx *EmployeeStore) error {
+		return fn(tx)
+	})
+}
+
+// inTx runs fn in a transaction, retrying it while it fails with a retryable
+// error, or in the transaction e is already in
+func (e *EmployeeStore) inTx(ctx context.Context, fn func(tx *EmployeeStore) error) error {
+	if e.db == nil {
+		return fn(e)
 	}
-	defer stmt.Close()
-	row := stmt.QueryRowContext(ctx, sql.Named("Name", d.Name))
+	b := backoff.NewExponentialBackOff()
+	b.InitialInterval = 10 * time.Millisecond
+	b.MaxInterval = 500 * time.Millisecond
+	return backoff.Retry(func() error {
+		err := e.runTx(ctx, fn)
+		if err != nil && !retryable(err) {
+			return backoff.Permanent(err)
+		}
+		return err
+	}, backoff.WithContext(backoff.WithMaxRetries(b, maxTxRetries), ctx))
+}
+
+func (e *EmployeeStore) runTx(ctx context.Context, fn func(tx *EmployeeStore) error) error {
+	tx, err := e.db.BeginTx(ctx, nil)
 	if err != nil {
-		return 0, err
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(&EmployeeStore{store: tx, hasher: e.hasher, decoy: e.decoy, dialect: e.dialect}); err != nil {
+		return err
 	}
+	return tx.Commit()
+}
+
+// SaveDepartment implements Store. Names that are taken fail with DepartmentExistsError.
+func (e *EmployeeStore) SaveDepartment(ctx context.Context, d Department) (int64, error) {
+	tsql := "INSERT INTO Department_Entities(Name) VALUES(@Name)" + e.dialect.returningID
+	row := e.store.QueryRowContext(ctx, tsql, sql.Named("Name", d.Name))
 	var newID int64
-	err = row.Scan(&newID)
+	err := row.Scan(&newID)
 	if err != nil {
+		if kind, _ := constraintViolation(err); kind == uniqueViolation {
+			return 0, &DepartmentExistsError{Name: d.Name}
+		}
 		return 0, err
 	}
 	log.Print("Row inserted!")
 	return newID, nil
 }
 
+// GetDepartments implements Store.
+func (e *EmployeeStore) GetDepartments(ctx context.Context) ([]Department, error) {
+	rows, err := e.store.QueryContext(ctx, "SELECT ID, Name FROM Department_Entities ORDER BY Name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var departments []Department
+	for rows.Next() {
+		var department Department
+		if err := rows.Scan(&department.ID, &department.Name); err != nil {
+			return nil, err
+		}
+		departments = append(departments, department)
+	}
+	return departments, rows.Err()
+}
+
+// GetDepartmentByID implements Store.
+func (e *EmployeeStore) GetDepartmentByID(ctx context.Context, id int64) (Department, error) {
+	row := e.store.QueryRowContext(ctx, "SELECT ID, Name FROM Department_Entities WHERE ID = @ID", sql.Named("ID", id))
+	var department Department
+	err := row.Scan(&department.ID, &department.Name)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Department{}, &DepartmentNotFoundError{}
+		}
+		return Department{}, err
+	}
+	return department, nil
+}
+
+// GetDepartmentsByIDs implements Store. Unknown ids are skipped.
+func (e *EmployeeStore) GetDepartmentsByIDs(ctx context.Context, ids []int64) ([]Department, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	in, args := inClause(ids)
+	rows, err := e.store.QueryContext(ctx, "SELECT ID, Name FROM Department_Entities WHERE ID IN ("+in+")", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var departments []Department
+	for rows.Next() {
+		var department Department
+		if err := rows.Scan(&department.ID, &department.Name); err != nil {
+			return nil, err
+		}
+		departments = append(departments, department)
+	}
+	return departments, rows.Err()
+}
+
+// RenameDepartment implements Store.
+func (e *EmployeeStore) RenameDepartment(ctx context.Context, id int64, name string) (Department, error) {
+	err := e.inTx(ctx, func(tx *EmployeeStore) error {
+		existingID, err := tx.GetDepartmentIdByName(ctx, name)
+		if err != nil {
+			return err
+		}
+		if existingID != 0 && existingID != id {
+			return &DepartmentExistsError{Name: name}
+		}
+
+		result, err := tx.store.ExecContext(ctx, "UPDATE Department_Entities SET Name = @Name WHERE ID = @ID", sql.Named("Name", name), sql.Named("ID", id))
+		if err != nil {
+			if kind, _ := constraintViolation(err); kind == uniqueViolation {
+				return &DepartmentExistsError{Name: name}
+			}
+			return err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return &DepartmentNotFoundError{}
+		}
+		return nil
+	})
+	if err != nil {
+		return Department{}, err
+	}
+	return Department{ID: id, Name: name}, nil
+}
+
+// DeleteDepartment implements Store. Departments that still have employees are not deleted.
+func (e *EmployeeStore) DeleteDepartment(ctx context.Context, id int64) error {
+	return e.inTx(ctx, func(tx *EmployeeStore) error {
+		var members int
+		err := tx.store.QueryRowContext(ctx, "SELECT COUNT(*) FROM Employee_Entities WHERE Department_Id = @ID", sql.Named("ID", id)).Scan(&members)
+		if err != nil {
+			return err
+		}
+		if members > 0 {
+			return &DepartmentInUseError{Employees: members}
+		}
+
+		result, err := tx.store.ExecContext(ctx, "DELETE FROM Department_Entities WHERE ID = @ID", sql.Named("ID", id))
+		if err != nil {
+			// an employee joined the department since it was counted, so it has at least one
+			if kind, _ := constraintViolation(err); kind == foreignKeyViolation {
+				return &DepartmentInUseError{Employees: 1}
+			}
+			return err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return &DepartmentNotFoundError{}
+		}
+		return nil
+	})
+}
+
 // Authenticate implements Store.
-func (e *EmployeeStore) Authenticate(ctx context.Context, user Employee) bool {
+func (e *EmployeeStore) Authenticate(ctx context.Context, user Employee) error {
 	row := e.store.QueryRowContext(ctx, "SELECT Password FROM Employee_Entities WHERE Username = @Username", sql.Named("Username", user.Username))
 	var hashedPassword string
 	err := row.Scan(&hashedPassword)
+	if errors.Is(err, sql.ErrNoRows) {
+		if err := e.decoy.verify(ctx, e.hasher, user.Password); err != nil {
+			return err
+		}
+		return &WrongUsernameOrPasswordError{}
+	}
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return false
+		return err
+	}
+
+	ok, err := e.hasher.Verify(ctx, user.Password, hashedPassword)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return &WrongUsernameOrPasswordError{}
+	}
+	if e.hasher.NeedsRehash(hashedPassword) {
+		e.rehash(ctx, user.Username, user.Password, hashedPassword)
+	}
+	return nil
+}
+
+// rehash replaces the stored hash of a password that was just verified with one
+// made with the current algorithm and parameters. Failing to do so is logged
+// and retried on the next login.
+func (e *EmployeeStore) rehash(ctx context.Context, username, password, old string) {
+	hash, err := e.hasher.Hash(ctx, password)
+	if err != nil {
+		log.Printf("rehash: %v", err)
+		return
+	}
+	// the old hash is compared so that a password changed meanwhile is kept
+	_, err = e.store.ExecContext(
+		ctx,
+		"UPDATE Employee_Entities SET Password = @Password WHERE Username = @Username AND Password = @Old",
+		sql.Named("Password", hash),
+		sql.Named("Username", username),
+		sql.Named("Old", old))
+	if err != nil {
+		log.Printf("rehash: %v", err)
+	}
+}
+
+// FindEmployees implements Store. It returns one page of employees matching q
+// together with the total number of matches.
+func (e *EmployeeStore) FindEmployees(ctx context.Context, q EmployeeQuery) ([]Employee, int, error) {
+	where, orderBy, args, err := buildEmployeeQuery(e.dialect, q)
+	if err != nil {
+		return nil, 0, err
+	}
+	from := `
+	FROM Employee_Entities e
+	LEFT JOIN Department_Entities d ON d.ID = e.Department_Id
+	` + where
+
+	var total int
+	err = e.store.QueryRowContext(ctx, "SELECT COUNT(*)"+from, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+	// FETCH NEXT and LIMIT require a positive row count
+	if q.Limit <= 0 {
+		return nil, total, nil
+	}
+
+	tsql := `
+	SELECT e.ID, e.First_Name, e.Last_Name, e.Username, e.Email, e.DOB, e.Department_Id, e.Position, e.Role
+	` + from + `
+	` + orderBy + `
+	` + e.dialect.page
+	args = append(args, sql.Named("Offset", q.Offset), sql.Named("Limit", q.Limit))
+	rows, err := e.store.QueryContext(ctx, tsql, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	var employees []Employee
+	for rows.Next() {
+		employee, err := scanEmployee(rows)
+		if err != nil {
+			return nil, 0, err
 		}
-		log.Fatal(err)
+		employees = append(employees, employee)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
 	}
+	return employees, total, nil
+}
 
-	return CheckPasswordHash(user.Password, hashedPassword)
+// GetEmployeeByID implements Store.
+func (e *EmployeeStore) GetEmployeeByID(ctx context.Context, id int64) (Employee, error) {
+	tsql := `
+	SELECT ID, First_Name, Last_Name, Username, Email, DOB, Department_Id, Position, Role FROM Employee_Entities WHERE ID = @ID
+	`
+	row := e.store.QueryRowContext(ctx, tsql, sql.Named("ID", id))
+	return scanEmployee(row)
 }
 
-// GetAllEmployees implements Store.
-func (e *EmployeeStore) GetAllEmployees(ctx context.Context) ([]Employee, error) {
+// GetEmployeeByUsername implements Store.
+func (e *EmployeeStore) GetEmployeeByUsername(ctx context.Context, username string) (Employee, error) {
 	tsql := `
-	SELECT ID, First_Name, Last_Name, Username, Email, DOB, Position FROM Employee_Entities
+	SELECT ID, First_Name, Last_Name, Username, Email, DOB, Department_Id, Position, Role FROM Employee_Entities WHERE Username = @Username
 	`
-	rows, err := e.store.QueryContext(ctx, tsql)
+	row := e.store.QueryRowContext(ctx, tsql, sql.Named("Username", username))
+	return scanEmployee(row)
+}
+
+// GetEmployeeByEmail implements Store.
+func (e *EmployeeStore) GetEmployeeByEmail(ctx context.Context, email string) (Employee, error) {
+	tsql := `
+	SELECT ID, First_Name, Last_Name, Username, Email, DOB, Department_Id, Position, Role FROM Employee_Entities WHERE Email = @Email ORDER BY ID
+	`
+	row := e.store.QueryRowContext(ctx, tsql, sql.Named("Email", email))
+	return scanEmployee(row)
+}
+
+// GetEmployeesByIDs implements Store. Unknown ids are skipped.
+func (e *EmployeeStore) GetEmployeesByIDs(ctx context.Context, ids []int64) ([]Employee, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	in, args := inClause(ids)
+	tsql := `
+	SELECT ID, First_Name, Last_Name, Username, Email, DOB, Department_Id, Position, Role FROM Employee_Entities WHERE ID IN (` + in + `)
+	`
+	rows, err := e.store.QueryContext(ctx, tsql, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 	var employees []Employee
 	for rows.Next() {
-		var employee Employee
-		err = rows.Scan(&employee.ID, &employee.FirstName, &employee.LastName, &employee.Username, &employee.Email, &employee.DOB, &employee.Position)
+		employee, err := scanEmployee(rows)
 		if err != nil {
 			return nil, err
 		}
 		employees = append(employees, employee)
 	}
-	return employees, nil
+	return employees, rows.Err()
+}
+
+// scanner is implemented by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanEmployee reads a single employee row, mapping a missing row to EmployeeNotFoundError
+func scanEmployee(row scanner) (Employee, error) {
+	var employee Employee
+	err := row.Scan(&employee.ID, &employee.FirstName, &employee.LastName, &employee.Username, &employee.Email, &employee.DOB, &employee.DepartmentID, &employee.Position, &employee.Role)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Employee{}, &EmployeeNotFoundError{}
+		}
+		return Employee{}, err
+	}
+	return employee, nil
 }
 
 // GetDepartmentIdByName implements Store.
@@ -109,21 +429,6 @@ func (e *EmployeeStore) GetDepartmentIdByName(ctx context.Context, name string)
 	return id, nil
 }
 
-// GetDepartmentNameById implements Store.
-func (e *EmployeeStore) GetDepartmentNameById(ctx context.Context, id int64) (string, error) {
-	row := e.store.QueryRowContext(ctx, "SELECT Name FROM Department WHERE ID = ?", id)
-	var name string
-	err := row.Scan(&name)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return "", nil
-		}
-		return "", err
-	}
-
-	return name, nil
-}
-
 // GetEmployeeIdByUsername implements Store.
 func (e *EmployeeStore) GetEmployeeIdByUsername(ctx context.Context, username string) (int64, error) {
 	row := e.store.QueryRowContext(ctx, "SELECT ID FROM Employee_Entities WHERE Username = @Username", sql.Named("Username", username))
@@ -139,64 +444,172 @@ func (e *EmployeeStore) GetEmployeeIdByUsername(ctx context.Context, username st
 	return id, nil
 }
 
-// Save implements Store.
+// Save implements Store. The department of emp is created along with it if it
+// does not exist yet. Taken usernames and emails fail with ErrDuplicateUsername
+// and ErrDuplicateEmail.
 func (e *EmployeeStore) Save(ctx context.Context, emp Employee) (int64, error) {
-	// if there is no department with the name provided go ahead and create the department
-	departmentID, err := e.GetDepartmentIdByName(ctx, emp.DepartmentName)
-	if errors.Is(err, sql.ErrNoRows) {
-		departmentName := Department{Name: emp.DepartmentName}
-		departmentID, err = e.SaveDepartment(ctx, departmentName)
+	role := emp.Role
+	if role == "" {
+		role = RoleEmployee
+	}
+
+	tsql := `
+	INSERT INTO Employee_Entities (First_Name, Last_Name, Username, Password, Email, DOB, Department_Id, Position, Role)
+	VALUES (@First_Name, @Last_Name, @Username, @Password, @Email, @DOB, @Department_Id, @Position, @Role)` + e.dialect.returningID
+
+	var newID int64
+	err := e.inTx(ctx, func(tx *EmployeeStore) error {
+		departmentID, err := tx.resolveDepartment(ctx, emp.DepartmentName)
 		if err != nil {
-			return 0, err
+			return err
 		}
-	} else if err != nil {
+		row := tx.store.QueryRowContext(
+			ctx,
+			tsql,
+			sql.Named("First_Name", emp.FirstName),
+			sql.Named("Last_Name", emp.LastName),
+			sql.Named("Username", emp.Username),
+			sql.Named("Password", emp.Password),
+			sql.Named("Email", emp.Email),
+			sql.Named("DOB", emp.DOB),
+			sql.Named("Department_Id", departmentID),
+			sql.Named("Position", emp.Position),
+			sql.Named("Role", role))
+		if err := row.Scan(&newID); err != nil {
+			return employeeError(err)
+		}
+		return nil
+	})
+	if err != nil {
 		return 0, err
 	}
+	log.Print("Row inserted!")
+	return newID, nil
+}
 
-	tsql := `
-	INSERT INTO Employee_Entities (First_Name, Last_Name, Username, Password, Email, DOB, Department_Id, Position)
-	VALUES (@First_Name, @Last_Name, @Username, @Password, @Email, @DOB, @Department_Id, @Position);
-	SELECT ID = convert(bigint, SCOPE_IDENTITY());
-	`
+// Update implements Store. Taken usernames and emails fail with
+// ErrDuplicateUsername and ErrDuplicateEmail.
+func (e *EmployeeStore) Update(ctx context.Context, id int64, upd EmployeeUpdate) (Employee, error) {
+	var sets []string
+	args := []interface{}{sql.Named("ID", id)}
+	set := func(column string, value interface{}) {
+		sets = append(sets, fmt.Sprintf("%s = @%s", column, column))
+		args = append(args, sql.Named(column, value))
+	}
 
-	stmt, err := e.store.Prepare(tsql)
+	if upd.FirstName != nil {
+		set("First_Name", *upd.FirstName)
+	}
+	if upd.LastName != nil {
+		set("Last_Name", *upd.LastName)
+	}
+	if upd.Username != nil {
+		set("Username", *upd.Username)
+	}
+	if upd.Password != nil {
+		set("Password", *upd.Password)
+	}
+	if upd.Email != nil {
+		set("Email", *upd.Email)
+	}
+	if upd.DOB != nil {
+		set("DOB", *upd.DOB)
+	}
+	if upd.Position != nil {
+		set("Position", *upd.Position)
+	}
+	if upd.Role != nil {
+		set("Role", *upd.Role)
+	}
+
+	// nothing to change, just hand back the current state
+	if len(sets) == 0 && upd.DepartmentName == nil {
+		return e.GetEmployeeByID(ctx, id)
+	}
+
+	var employee Employee
+	err := e.inTx(ctx, func(tx *EmployeeStore) error {
+		// copied, so that a retried transaction starts afresh
+		assignments := append([]string{}, sets...)
+		values := append([]interface{}{}, args...)
+		if upd.DepartmentName != nil {
+			departmentID, err := tx.resolveDepartment(ctx, *upd.DepartmentName)
+			if err != nil {
+				return err
+			}
+			assignments = append(assignments, "Department_Id = @Department_Id")
+			values = append(values, sql.Named("Department_Id", departmentID))
+		}
+
+		tsql := fmt.Sprintf("UPDATE Employee_Entities SET %s WHERE ID = @ID", strings.Join(assignments, ", "))
+		result, err := tx.store.ExecContext(ctx, tsql, values...)
+		if err != nil {
+			return employeeError(err)
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return &EmployeeNotFoundError{}
+		}
+		employee, err = tx.GetEmployeeByID(ctx, id)
+		return err
+	})
 	if err != nil {
-		return 0, err
+		return Employee{}, err
 	}
-	defer stmt.Close()
-	row := stmt.QueryRowContext(
-		ctx,
-		sql.Named("First_Name", emp.FirstName),
-		sql.Named("Last_Name", emp.LastName),
-		sql.Named("Username", emp.Username),
-		sql.Named("Password", emp.Password),
-		sql.Named("Email", emp.Email),
-		sql.Named("DOB", emp.DOB),
-		sql.Named("Department_Id", departmentID),
-		sql.Named("Position", emp.Position))
+	return employee, nil
+}
+
+// Delete implements Store.
+func (e *EmployeeStore) Delete(ctx context.Context, id int64) error {
+	result, err := e.store.ExecContext(ctx, "DELETE FROM Employee_Entities WHERE ID = @ID", sql.Named("ID", id))
 	if err != nil {
-		return 0, err
+		return err
 	}
-	var newID int64
-	err = row.Scan(&newID)
+	affected, err := result.RowsAffected()
 	if err != nil {
-		return 0, err
+		return err
 	}
-	log.Print("Row inserted!")
-	return newID, nil
+	if affected == 0 {
+		return &EmployeeNotFoundError{}
+	}
+	return nil
 }
 
-// HashPassword hashes given password
-func (e *EmployeeStore) HashPassword(password string) string {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
+// resolveDepartment looks up the department by name, creating it if it does not
+// exist yet. A transaction that creates it at the same time makes this one fail
+// with errConflict, to be retried once that department can be seen.
+func (e *EmployeeStore) resolveDepartment(ctx context.Context, name string) (int64, error) {
+	departmentID, err := e.GetDepartmentIdByName(ctx, name)
 	if err != nil {
-		log.Fatal(err)
+		return 0, err
+	}
+	if departmentID != 0 {
+		return departmentID, nil
+	}
+	departmentID, err = e.SaveDepartment(ctx, Department{Name: name})
+	var exists *DepartmentExistsError
+	if errors.As(err, &exists) {
+		return 0, fmt.Errorf("%w: department %q was created meanwhile", errConflict, name)
+	}
+	return departmentID, err
+}
+
+// inClause builds the parameter list of an IN (...) condition for ids
+func inClause(ids []int64) (string, []interface{}) {
+	params := make([]string, len(ids))
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		name := fmt.Sprintf("ID%d", i)
+		params[i] = "@" + name
+		args[i] = sql.Named(name, id)
 	}
-	return string(bytes)
+	return strings.Join(params, ", "), args
 }
 
-// CheckPassword hash compares raw password with it's hashed values
-func CheckPasswordHash(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil
+// HashPassword hashes given password for storage
+func (e *EmployeeStore) HashPassword(ctx context.Context, password string) (string, error) {
+	return e.hasher.Hash(ctx, password)
 }
@@ -1,7 +1,56 @@
 package employees
 
+import (
+	"errors"
+	"fmt"
+)
+
+// The errors of saving or updating an employee that breaks a constraint of the
+// database. The SQL stores wrap them around the error of the driver.
+var (
+	ErrDuplicateUsername = errors.New("username is already taken")
+	ErrDuplicateEmail    = errors.New("email is already taken")
+	ErrUnknownDepartment = errors.New("department does not exist")
+)
+
 type WrongUsernameOrPasswordError struct{}
 
 func (m *WrongUsernameOrPasswordError) Error() string {
 	return "wrong username or password"
-}
\ No newline at end of file
+}
+
+// WrongPasswordError is returned when the current password given to change it is wrong
+type WrongPasswordError struct{}
+
+func (m *WrongPasswordError) Error() string {
+	return "current password is incorrect"
+}
+
+type EmployeeNotFoundError struct{}
+
+func (m *EmployeeNotFoundError) Error() string {
+	return "employee not found"
+}
+
+type DepartmentNotFoundError struct{}
+
+func (m *DepartmentNotFoundError) Error() string {
+	return "department not found"
+}
+
+type DepartmentExistsError struct {
+	Name string
+}
+
+func (m *DepartmentExistsError) Error() string {
+	return fmt.Sprintf("department %q already exists", m.Name)
+}
+
+// DepartmentInUseError is returned when deleting a department that employees still belong to
+type DepartmentInUseError struct {
+	Employees int
+}
+
+func (m *DepartmentInUseError) Error() string {
+	return fmt.Sprintf("department still has %d employee(s)", m.Employees)
+}
@@ -0,0 +1,306 @@
+package employees
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	Argon2id = "argon2id"
+	Bcrypt   = "bcrypt"
+
+	argon2idPrefix = "$argon2id$"
+	argon2SaltLen  = 16
+	argon2KeyLen   = 32
+)
+
+// ErrUnknownHashFormat is returned when verifying a hash made by none of the
+// supported algorithms
+var ErrUnknownHashFormat = errors.New("unknown password hash format")
+
+// PasswordHasher hashes passwords for storage. Hashes carry their algorithm
+// and parameters so that every hasher can verify hashes of the others.
+type PasswordHasher interface {
+	Hash(ctx context.Context, password string) (string, error)
+	Verify(ctx context.Context, password, hash string) (bool, error)
+	// NeedsRehash reports whether hash was made with another algorithm or other
+	// parameters than the ones Hash uses now
+	NeedsRehash(hash string) bool
+}
+
+// HasherConfig selects the algorithm new passwords are hashed with
+type HasherConfig struct {
+	// Algorithm is Argon2id or Bcrypt
+	Algorithm  string
+	BcryptCost int
+	Argon2     Argon2Params
+	// Concurrency caps how many hashes are computed at once, the rest wait so
+	// that a burst of logins cannot take all CPUs and memory
+	Concurrency int
+}
+
+// Argon2Params are the argon2id cost parameters, Memory is in KiB
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+}
+
+// DefaultArgon2Params follow the OWASP recommendation for argon2id
+var DefaultArgon2Params = Argon2Params{Memory: 19 * 1024, Iterations: 2, Parallelism: 1}
+
+// NewPasswordHasher returns the hasher described by cfg
+func NewPasswordHasher(cfg HasherConfig) (PasswordHasher, error) {
+	var hasher PasswordHasher
+	switch cfg.Algorithm {
+	case "", Argon2id:
+		params := cfg.Argon2
+		if params == (Argon2Params{}) {
+			params = DefaultArgon2Params
+		}
+		h, err := NewArgon2idHasher(params)
+		if err != nil {
+			return nil, err
+		}
+		hasher = h
+	case Bcrypt:
+		h, err := NewBcryptHasher(cfg.BcryptCost)
+		if err != nil {
+			return nil, err
+		}
+		hasher = h
+	default:
+		return nil, fmt.Errorf("unknown password hash algorithm %q", cfg.Algorithm)
+	}
+
+	concurrency := cfg.Concurrency
+	if concurrency <= 0 {
+		concurrency = runtime.NumCPU()
+	}
+	return &limitedHasher{hasher: hasher, slots: make(chan struct{}, concurrency)}, nil
+}
+
+// BcryptHasher hashes passwords with bcrypt
+type BcryptHasher struct {
+	cost int
+}
+
+// NewBcryptHasher returns a BcryptHasher, cost 0 means bcrypt.DefaultCost
+func NewBcryptHasher(cost int) (*BcryptHasher, error) {
+	if cost == 0 {
+		cost = bcrypt.DefaultCost
+	}
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return nil, fmt.Errorf("bcrypt cost %d is not between %d and %d", cost, bcrypt.MinCost, bcrypt.MaxCost)
+	}
+	return &BcryptHasher{cost: cost}, nil
+}
+
+// Hash implements PasswordHasher.
+func (b *BcryptHasher) Hash(_ context.Context, password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), b.cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// Verify implements PasswordHasher.
+func (b *BcryptHasher) Verify(_ context.Context, password, hash string) (bool, error) {
+	return verifyHash(password, hash)
+}
+
+// NeedsRehash implements PasswordHasher.
+func (b *BcryptHasher) NeedsRehash(hash string) bool {
+	if !isBcrypt(hash) {
+		return true
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != b.cost
+}
+
+// Argon2idHasher hashes passwords with argon2id into the PHC string format
+// $argon2id$v=19$m=<memory>,t=<iterations>,p=<parallelism>$<salt>$<key>
+type Argon2idHasher struct {
+	params Argon2Params
+}
+
+// NewArgon2idHasher returns an Argon2idHasher using params
+func NewArgon2idHasher(params Argon2Params) (*Argon2idHasher, error) {
+	if params.Iterations < 1 || params.Parallelism < 1 {
+		return nil, errors.New("argon2id needs at least one iteration and one thread")
+	}
+	if params.Memory < 8*uint32(params.Parallelism) {
+		return nil, fmt.Errorf("argon2id needs at least %d KiB of memory", 8*uint32(params.Parallelism))
+	}
+	return &Argon2idHasher{params: params}, nil
+}
+
+// Hash implements PasswordHasher.
+func (a *Argon2idHasher) Hash(_ context.Context, password string) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, a.params.Iterations, a.params.Memory, a.params.Parallelism, argon2KeyLen)
+	return encodeArgon2id(a.params, salt, key), nil
+}
+
+// Verify implements PasswordHasher.
+func (a *Argon2idHasher) Verify(_ context.Context, password, hash string) (bool, error) {
+	return verifyHash(password, hash)
+}
+
+// NeedsRehash implements PasswordHasher.
+func (a *Argon2idHasher) NeedsRehash(hash string) bool {
+	params, _, key, err := decodeArgon2id(hash)
+	return err != nil || params != a.params || len(key) != argon2KeyLen
+}
+
+// limitedHasher lets a bounded number of hashes be computed at once, callers
+// give up waiting when their context is done
+type limitedHasher struct {
+	hasher PasswordHasher
+	slots  chan struct{}
+}
+
+func (l *limitedHasher) Hash(ctx context.Context, password string) (string, error) {
+	if err := l.acquire(ctx); err != nil {
+		return "", err
+	}
+	defer l.release()
+	return l.hasher.Hash(ctx, password)
+}
+
+func (l *limitedHasher) Verify(ctx context.Context, password, hash string) (bool, error) {
+	if err := l.acquire(ctx); err != nil {
+		return false, err
+	}
+	defer l.release()
+	return l.hasher.Verify(ctx, password, hash)
+}
+
+func (l *limitedHasher) NeedsRehash(hash string) bool {
+	return l.hasher.NeedsRehash(hash)
+}
+
+func (l *limitedHasher) acquire(ctx context.Context) error {
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *limitedHasher) release() {
+	<-l.slots
+}
+
+// decoyHash stands in for the hash of unknown usernames, so that rejecting them
+// takes as long as rejecting a wrong password and does not reveal which
+// usernames exist. It is made on first use by the hasher of the store, whose
+// cost it must match.
+type decoyHash struct {
+	mu   sync.Mutex
+	hash string
+}
+
+// verify checks password against the decoy hash, whose result is always false
+func (d *decoyHash) verify(ctx context.Context, hasher PasswordHasher, password string) error {
+	d.mu.Lock()
+	if d.hash == "" {
+		hash, err := hasher.Hash(ctx, "not the password of anyone")
+		if err != nil {
+			d.mu.Unlock()
+			return err
+		}
+		d.hash = hash
+	}
+	hash := d.hash
+	d.mu.Unlock()
+	_, err := hasher.Verify(ctx, password, hash)
+	return err
+}
+
+// CheckPasswordHash compares raw password with its hash made by any of the
+// supported algorithms
+func CheckPasswordHash(password, hash string) bool {
+	ok, err := verifyHash(password, hash)
+	return err == nil && ok
+}
+
+func verifyHash(password, hash string) (bool, error) {
+	switch {
+	case strings.HasPrefix(hash, argon2idPrefix):
+		params, salt, key, err := decodeArgon2id(hash)
+		if err != nil {
+			return false, err
+		}
+		other := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+		return subtle.ConstantTimeCompare(key, other) == 1, nil
+	case isBcrypt(hash):
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+		return err == nil, err
+	default:
+		return false, ErrUnknownHashFormat
+	}
+}
+
+func isBcrypt(hash string) bool {
+	for _, prefix := range []string{"$2a$", "$2b$", "$2y$"} {
+		if strings.HasPrefix(hash, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func encodeArgon2id(params Argon2Params, salt, key []byte) string {
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2idPrefix, argon2.Version,
+		params.Memory, params.Iterations, params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key))
+}
+
+func decodeArgon2id(hash string) (Argon2Params, []byte, []byte, error) {
+	// "", "argon2id", "v=19", "m=..,t=..,p=..", salt, key
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != Argon2id {
+		return Argon2Params{}, nil, nil, ErrUnknownHashFormat
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return Argon2Params{}, nil, nil, fmt.Errorf("unsupported argon2id version %q", parts[2])
+	}
+	var params Argon2Params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return Argon2Params{}, nil, nil, fmt.Errorf("malformed argon2id parameters: %w", err)
+	}
+	if params.Iterations < 1 || params.Parallelism < 1 {
+		return Argon2Params{}, nil, nil, errors.New("malformed argon2id parameters")
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return Argon2Params{}, nil, nil, err
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return Argon2Params{}, nil, nil, errors.New("malformed argon2id key")
+	}
+	return params, salt, key, nil
+}
@@ -0,0 +1,139 @@
+package employees
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var testArgon2Params = Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1}
+
+func TestPasswordHashers(t *testing.T) {
+	ctx := context.Background()
+	legacy, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		cfg    HasherConfig
+		prefix string
+	}{
+		{"Argon2id", HasherConfig{Algorithm: Argon2id, Argon2: testArgon2Params}, "$argon2id$v=19$m=64,t=1,p=1$"},
+		{"Bcrypt", HasherConfig{Algorithm: Bcrypt, BcryptCost: bcrypt.MinCost + 1}, "$2a$05$"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hasher, err := NewPasswordHasher(tt.cfg)
+			require.NoError(t, err)
+
+			hash, err := hasher.Hash(ctx, "secret")
+			require.NoError(t, err)
+			assert.True(t, strings.HasPrefix(hash, tt.prefix), hash)
+			assert.False(t, hasher.NeedsRehash(hash))
+
+			ok, err := hasher.Verify(ctx, "secret", hash)
+			require.NoError(t, err)
+			assert.True(t, ok)
+			ok, err = hasher.Verify(ctx, "wrong", hash)
+			require.NoError(t, err)
+			assert.False(t, ok)
+
+			// hashes of other algorithms and costs are still verified but replaced
+			ok, err = hasher.Verify(ctx, "secret", string(legacy))
+			require.NoError(t, err)
+			assert.True(t, ok)
+			assert.True(t, hasher.NeedsRehash(string(legacy)))
+		})
+	}
+}
+
+func TestArgon2idNeedsRehash(t *testing.T) {
+	ctx := context.Background()
+	old, err := NewArgon2idHasher(testArgon2Params)
+	require.NoError(t, err)
+	hash, err := old.Hash(ctx, "secret")
+	require.NoError(t, err)
+
+	stronger, err := NewArgon2idHasher(Argon2Params{Memory: 128, Iterations: 1, Parallelism: 1})
+	require.NoError(t, err)
+	assert.True(t, stronger.NeedsRehash(hash))
+	ok, err := stronger.Verify(ctx, "secret", hash)
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestCheckPasswordHash(t *testing.T) {
+	hasher, err := NewArgon2idHasher(testArgon2Params)
+	require.NoError(t, err)
+	hash, err := hasher.Hash(context.Background(), "secret")
+	require.NoError(t, err)
+
+	assert.True(t, CheckPasswordHash("secret", hash))
+	assert.False(t, CheckPasswordHash("wrong", hash))
+	assert.False(t, CheckPasswordHash("secret", "plain text"))
+	assert.False(t, CheckPasswordHash("secret", "$argon2id$v=19$m=64,t=1,p=1$c2FsdA"))
+
+	_, err = verifyHash("secret", "plain text")
+	assert.ErrorIs(t, err, ErrUnknownHashFormat)
+}
+
+func TestNewPasswordHasherErrors(t *testing.T) {
+	for _, cfg := range []HasherConfig{
+		{Algorithm: "md5"},
+		{Algorithm: Bcrypt, BcryptCost: 40},
+		{Algorithm: Argon2id, Argon2: Argon2Params{Memory: 64, Iterations: 0, Parallelism: 1}},
+		{Algorithm: Argon2id, Argon2: Argon2Params{Memory: 4, Iterations: 1, Parallelism: 1}},
+	} {
+		_, err := NewPasswordHasher(cfg)
+		assert.Error(t, err, cfg)
+	}
+}
+
+func TestHasherConcurrencyLimit(t *testing.T) {
+	hasher, err := NewPasswordHasher(HasherConfig{Algorithm: Argon2id, Argon2: testArgon2Params, Concurrency: 1})
+	require.NoError(t, err)
+	limited := hasher.(*limitedHasher)
+
+	// with the only slot taken callers wait until their context is done
+	require.NoError(t, limited.acquire(context.Background()))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = hasher.Hash(ctx, "secret")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	limited.release()
+	_, err = hasher.Hash(context.Background(), "secret")
+	assert.NoError(t, err)
+}
+
+// countingHasher counts the passwords it verifies
+type countingHasher struct {
+	PasswordHasher
+	verified int
+}
+
+func (c *countingHasher) Verify(ctx context.Context, password, hash string) (bool, error) {
+	c.verified++
+	return c.PasswordHasher.Verify(ctx, password, hash)
+}
+
+func TestAuthenticateUnknownUsername(t *testing.T) {
+	ctx := context.Background()
+	bcryptHasher, err := NewBcryptHasher(bcrypt.MinCost)
+	require.NoError(t, err)
+	hasher := &countingHasher{PasswordHasher: bcryptHasher}
+	store := NewMemoryStore(hasher)
+
+	// unknown usernames cost a hash like wrong passwords, so they cannot be told apart
+	for i := 0; i < 2; i++ {
+		err := store.Authenticate(ctx, Employee{Username: "nobody", Password: "secret"})
+		var wrong *WrongUsernameOrPasswordError
+		require.ErrorAs(t, err, &wrong)
+	}
+	assert.Equal(t, 2, hasher.verified)
+}
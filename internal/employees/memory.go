@@ -0,0 +1,536 @@
+package employees
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MemoryStore is a Store that keeps employees and departments in memory, for
+// tests and demos. Usernames and emails are unique, like the unique indexes of
+// the database make them, and taking one again fails with ErrDuplicateUsername
+// or ErrDuplicateEmail.
+type MemoryStore struct {
+	hasher PasswordHasher
+	decoy  *decoyHash
+
+	// writeMu is held by every write and for the whole of a transaction,
+	// which works on a copy of the store that replaces it on commit
+	writeMu sync.Mutex
+	// inTx is set on the copy a transaction works on
+	inTx bool
+
+	mu          sync.Mutex
+	employees   map[int64]Employee
+	departments map[int64]Department
+	usernames   map[string]int64
+	emails      map[string]int64
+	// lastEmployeeID and lastDepartmentID are the auto-increment counters,
+	// IDs are never reused
+	lastEmployeeID   int64
+	lastDepartmentID int64
+}
+
+// NewMemoryStore returns an empty MemoryStore
+func NewMemoryStore(hasher PasswordHasher) Store {
+	return &MemoryStore{
+		hasher:      hasher,
+		decoy:       &decoyHash{},
+		employees:   map[int64]Employee{},
+		departments: map[int64]Department{},
+		usernames:   map[string]int64{},
+		emails:      map[string]int64{},
+	}
+}
+
+// GetEmployeeIdByUsername implements Store.
+func (m *MemoryStore) GetEmployeeIdByUsername(ctx context.Context, username string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.usernames[username], nil
+}
+
+// GetDepartmentIdByName implements Store.
+func (m *MemoryStore) GetDepartmentIdByName(ctx context.Context, name string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.departmentIDByName(name), nil
+}
+
+// FindEmployees implements Store.
+func (m *MemoryStore) FindEmployees(ctx context.Context, q EmployeeQuery) ([]Employee, int, error) {
+	for _, o := range q.OrderBy {
+		if _, ok := orderColumns[o.Field]; !ok {
+			return nil, 0, fmt.Errorf("cannot order employees by %q", o.Field)
+		}
+	}
+
+	m.mu.Lock()
+	var matches []Employee
+	for _, employee := range m.employees {
+		if m.matches(employee, q.Filter) {
+			matches = append(matches, public(employee))
+		}
+	}
+	m.mu.Unlock()
+
+	sort.Slice(matches, func(i, j int) bool {
+		return compareEmployees(matches[i], matches[j], q.OrderBy) < 0
+	})
+	total := len(matches)
+	if q.Limit <= 0 || q.Offset >= total {
+		return nil, total, nil
+	}
+	end := q.Offset + q.Limit
+	if end > total {
+		end = total
+	}
+	return matches[q.Offset:end], total, nil
+}
+
+// matches applies f like the WHERE clause of buildEmployeeQuery, with m.mu held
+func (m *MemoryStore) matches(e Employee, f EmployeeFilter) bool {
+	if f.Name != "" {
+		name := strings.ToLower(f.Name)
+		if !strings.Contains(strings.ToLower(e.FirstName), name) &&
+			!strings.Contains(strings.ToLower(e.LastName), name) &&
+			!strings.Contains(strings.ToLower(e.FirstName+" "+e.LastName), name) {
+			return false
+		}
+	}
+	if f.Department != "" && m.departments[e.DepartmentID].Name != f.Department {
+		return false
+	}
+	if f.Position != "" && e.Position != f.Position {
+		return false
+	}
+	if f.EmailDomain != "" {
+		domain := "@" + strings.ToLower(strings.TrimPrefix(f.EmailDomain, "@"))
+		if !strings.HasSuffix(strings.ToLower(e.Email), domain) {
+			return false
+		}
+	}
+	if f.DOBFrom != "" && e.DOB < f.DOBFrom {
+		return false
+	}
+	if f.DOBTo != "" && e.DOB > f.DOBTo {
+		return false
+	}
+	return true
+}
+
+// compareEmployees orders a and b by order, then by ID like buildEmployeeQuery
+func compareEmployees(a, b Employee, order []EmployeeOrder) int {
+	for _, o := range order {
+		var c int
+		switch o.Field {
+		case OrderByID:
+			c = compareIDs(a.ID, b.ID)
+		case OrderByFirstName:
+			c = strings.Compare(a.FirstName, b.FirstName)
+		case OrderByLastName:
+			c = strings.Compare(a.LastName, b.LastName)
+		case OrderByUsername:
+			c = strings.Compare(a.Username, b.Username)
+		case OrderByEmail:
+			c = strings.Compare(a.Email, b.Email)
+		case OrderByDOB:
+			c = strings.Compare(a.DOB, b.DOB)
+		case OrderByPosition:
+			c = strings.Compare(a.Position, b.Position)
+		}
+		if o.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return compareIDs(a.ID, b.ID)
+}
+
+func compareIDs(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// GetEmployeeByID implements Store.
+func (m *MemoryStore) GetEmployeeByID(ctx context.Context, id int64) (Employee, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	employee, ok := m.employees[id]
+	if !ok {
+		return Employee{}, &EmployeeNotFoundError{}
+	}
+	return public(employee), nil
+}
+
+// GetEmployeeByUsername implements Store.
+func (m *MemoryStore) GetEmployeeByUsername(ctx context.Context, username string) (Employee, error) {
+	m.mu.Lock()
+	id, ok := m.usernames[username]
+	m.mu.Unlock()
+	if !ok {
+		return Employee{}, &EmployeeNotFoundError{}
+	}
+	return m.GetEmployeeByID(ctx, id)
+}
+
+// GetEmployeeByEmail implements Store.
+func (m *MemoryStore) GetEmployeeByEmail(ctx context.Context, email string) (Employee, error) {
+	m.mu.Lock()
+	id, ok := m.emails[email]
+	m.mu.Unlock()
+	if !ok {
+		return Employee{}, &EmployeeNotFoundError{}
+	}
+	return m.GetEmployeeByID(ctx, id)
+}
+
+// GetEmployeesByIDs implements Store. Unknown ids are skipped.
+func (m *MemoryStore) GetEmployeesByIDs(ctx context.Context, ids []int64) ([]Employee, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var employees []Employee
+	for _, id := range ids {
+		if employee, ok := m.employees[id]; ok {
+			employees = append(employees, public(employee))
+		}
+	}
+	return employees, nil
+}
+
+// Save implements Store.
+func (m *MemoryStore) Save(ctx context.Context, emp Employee) (int64, error) {
+	m.writeMu.Lock()
+	defer m.writeMu.Unlock()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.usernames[emp.Username]; ok {
+		return 0, ErrDuplicateUsername
+	}
+	if _, ok := m.emails[emp.Email]; ok {
+		return 0, ErrDuplicateEmail
+	}
+
+	if emp.Role == "" {
+		emp.Role = RoleEmployee
+	}
+	emp.DepartmentID = m.resolveDepartment(emp.DepartmentName)
+	emp.DepartmentName = ""
+	m.lastEmployeeID++
+	emp.ID = m.lastEmployeeID
+	m.employees[emp.ID] = emp
+	m.usernames[emp.Username] = emp.ID
+	m.emails[emp.Email] = emp.ID
+	return emp.ID, nil
+}
+
+// Update implements Store.
+func (m *MemoryStore) Update(ctx context.Context, id int64, upd EmployeeUpdate) (Employee, error) {
+	m.writeMu.Lock()
+	defer m.writeMu.Unlock()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	employee, ok := m.employees[id]
+	if !ok {
+		return Employee{}, &EmployeeNotFoundError{}
+	}
+	if upd.Username != nil {
+		if other, ok := m.usernames[*upd.Username]; ok && other != id {
+			return Employee{}, ErrDuplicateUsername
+		}
+	}
+	if upd.Email != nil {
+		if other, ok := m.emails[*upd.Email]; ok && other != id {
+			return Employee{}, ErrDuplicateEmail
+		}
+	}
+
+	if upd.FirstName != nil {
+		employee.FirstName = *upd.FirstName
+	}
+	if upd.LastName != nil {
+		employee.LastName = *upd.LastName
+	}
+	if upd.Username != nil {
+		delete(m.usernames, employee.Username)
+		employee.Username = *upd.Username
+		m.usernames[employee.Username] = id
+	}
+	if upd.Password != nil {
+		employee.Password = *upd.Password
+	}
+	if upd.Email != nil {
+		delete(m.emails, employee.Email)
+		employee.Email = *upd.Email
+		m.emails[employee.Email] = id
+	}
+	if upd.DOB != nil {
+		employee.DOB = *upd.DOB
+	}
+	if upd.DepartmentName != nil {
+		employee.DepartmentID = m.resolveDepartment(*upd.DepartmentName)
+	}
+	if upd.Position != nil {
+		employee.Position = *upd.Position
+	}
+	if upd.Role != nil {
+		employee.Role = *upd.Role
+	}
+	m.employees[id] = employee
+	return public(employee), nil
+}
+
+// Delete implements Store.
+func (m *MemoryStore) Delete(ctx context.Context, id int64) error {
+	m.writeMu.Lock()
+	defer m.writeMu.Unlock()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	employee, ok := m.employees[id]
+	if !ok {
+		return &EmployeeNotFoundError{}
+	}
+	delete(m.employees, id)
+	delete(m.usernames, employee.Username)
+	delete(m.emails, employee.Email)
+	return nil
+}
+
+// Authenticate implements Store.
+func (m *MemoryStore) Authenticate(ctx context.Context, user Employee) error {
+	m.mu.Lock()
+	id, ok := m.usernames[user.Username]
+	hashedPassword := m.employees[id].Password
+	m.mu.Unlock()
+
+	// hashing is slow, so the lock is not held meanwhile
+	if !ok {
+		if err := m.decoy.verify(ctx, m.hasher, user.Password); err != nil {
+			return err
+		}
+		return &WrongUsernameOrPasswordError{}
+	}
+	ok, err := m.hasher.Verify(ctx, user.Password, hashedPassword)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return &WrongUsernameOrPasswordError{}
+	}
+	if m.hasher.NeedsRehash(hashedPassword) {
+		m.rehash(ctx, id, user.Password, hashedPassword)
+	}
+	return nil
+}
+
+// rehash replaces the hash of a password that was just verified, unless the
+// password was changed meanwhile
+func (m *MemoryStore) rehash(ctx context.Context, id int64, password, old string) {
+	hash, err := m.hasher.Hash(ctx, password)
+	if err != nil {
+		log.Printf("rehash: %v", err)
+		return
+	}
+	m.writeMu.Lock()
+	defer m.writeMu.Unlock()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if employee, ok := m.employees[id]; ok && employee.Password == old {
+		employee.Password = hash
+		m.employees[id] = employee
+	}
+}
+
+// SaveDepartment implements Store. Names that are taken fail with DepartmentExistsError.
+func (m *MemoryStore) SaveDepartment(ctx context.Context, d Department) (int64, error) {
+	m.writeMu.Lock()
+	defer m.writeMu.Unlock()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.departmentIDByName(d.Name) != 0 {
+		return 0, &DepartmentExistsError{Name: d.Name}
+	}
+	return m.saveDepartment(d.Name), nil
+}
+
+// GetDepartments implements Store.
+func (m *MemoryStore) GetDepartments(ctx context.Context) ([]Department, error) {
+	m.mu.Lock()
+	var departments []Department
+	for _, department := range m.departments {
+		departments = append(departments, department)
+	}
+	m.mu.Unlock()
+	sort.Slice(departments, func(i, j int) bool {
+		if departments[i].Name != departments[j].Name {
+			return departments[i].Name < departments[j].Name
+		}
+		return departments[i].ID < departments[j].ID
+	})
+	return departments, nil
+}
+
+// GetDepartmentByID implements Store.
+func (m *MemoryStore) GetDepartmentByID(ctx context.Context, id int64) (Department, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	department, ok := m.departments[id]
+	if !ok {
+		return Department{}, &DepartmentNotFoundError{}
+	}
+	return department, nil
+}
+
+// GetDepartmentsByIDs implements Store. Unknown ids are skipped.
+func (m *MemoryStore) GetDepartmentsByIDs(ctx context.Context, ids []int64) ([]Department, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var departments []Department
+	for _, id := range ids {
+		if department, ok := m.departments[id]; ok {
+			departments = append(departments, department)
+		}
+	}
+	return departments, nil
+}
+
+// RenameDepartment implements Store.
+func (m *MemoryStore) RenameDepartment(ctx context.Context, id int64, name string) (Department, error) {
+	m.writeMu.Lock()
+	defer m.writeMu.Unlock()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if existingID := m.departmentIDByName(name); existingID != 0 && existingID != id {
+		return Department{}, &DepartmentExistsError{Name: name}
+	}
+	if _, ok := m.departments[id]; !ok {
+		return Department{}, &DepartmentNotFoundError{}
+	}
+	m.departments[id] = Department{ID: id, Name: name}
+	return m.departments[id], nil
+}
+
+// DeleteDepartment implements Store. Departments that still have employees are not deleted.
+func (m *MemoryStore) DeleteDepartment(ctx context.Context, id int64) error {
+	m.writeMu.Lock()
+	defer m.writeMu.Unlock()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var members int
+	for _, employee := range m.employees {
+		if employee.DepartmentID == id {
+			members++
+		}
+	}
+	if members > 0 {
+		return &DepartmentInUseError{Employees: members}
+	}
+	if _, ok := m.departments[id]; !ok {
+		return &DepartmentNotFoundError{}
+	}
+	delete(m.departments, id)
+	return nil
+}
+
+// WithTx implements Store. Transactions and writes take turns, and fn works on
+// a copy of the store that replaces it when fn succeeds.
+func (m *MemoryStore) WithTx(ctx context.Context, fn func(Store) error) error {
+	if m.inTx {
+		return fn(m)
+	}
+	m.writeMu.Lock()
+	defer m.writeMu.Unlock()
+
+	m.mu.Lock()
+	tx := m.copy()
+	m.mu.Unlock()
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.employees, m.departments = tx.employees, tx.departments
+	m.usernames, m.emails = tx.usernames, tx.emails
+	m.lastEmployeeID, m.lastDepartmentID = tx.lastEmployeeID, tx.lastDepartmentID
+	return nil
+}
+
+// copy returns a copy of m for a transaction, with m.mu held
+func (m *MemoryStore) copy() *MemoryStore {
+	tx := &MemoryStore{
+		hasher:           m.hasher,
+		decoy:            m.decoy,
+		inTx:             true,
+		employees:        make(map[int64]Employee, len(m.employees)),
+		departments:      make(map[int64]Department, len(m.departments)),
+		usernames:        make(map[string]int64, len(m.usernames)),
+		emails:           make(map[string]int64, len(m.emails)),
+		lastEmployeeID:   m.lastEmployeeID,
+		lastDepartmentID: m.lastDepartmentID,
+	}
+	for id, employee := range m.employees {
+		tx.employees[id] = employee
+	}
+	for id, department := range m.departments {
+		tx.departments[id] = department
+	}
+	for username, id := range m.usernames {
+		tx.usernames[username] = id
+	}
+	for email, id := range m.emails {
+		tx.emails[email] = id
+	}
+	return tx
+}
+
+// HashPassword hashes given password for storage
+func (m *MemoryStore) HashPassword(ctx context.Context, password string) (string, error) {
+	return m.hasher.Hash(ctx, password)
+}
+
+// resolveDepartment looks up the department by name, creating it if it does
+// not exist yet, with m.mu held
+func (m *MemoryStore) resolveDepartment(name string) int64 {
+	if id := m.departmentIDByName(name); id != 0 {
+		return id
+	}
+	return m.saveDepartment(name)
+}
+
+func (m *MemoryStore) saveDepartment(name string) int64 {
+	m.lastDepartmentID++
+	m.departments[m.lastDepartmentID] = Department{ID: m.lastDepartmentID, Name: name}
+	return m.lastDepartmentID
+}
+
+// departmentIDByName returns 0 for unknown departments, with m.mu held
+func (m *MemoryStore) departmentIDByName(name string) int64 {
+	var id int64
+	for _, department := range m.departments {
+		// like SELECT ID ... WHERE Name = @Name, the first one if names repeat
+		if department.Name == name && (id == 0 || department.ID < id) {
+			id = department.ID
+		}
+	}
+	return id
+}
+
+// public is employee as the SQL stores read it back, without its password hash
+// and department name
+func public(employee Employee) Employee {
+	employee.Password = ""
+	employee.DepartmentName = ""
+	return employee
+}
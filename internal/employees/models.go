@@ -5,12 +5,13 @@ type Employee struct {
 	FirstName      string `json:"first_name"`
 	LastName       string `json:"last_name"`
 	Username       string `json:"username"`
-	Password       string `json:"password"`
+	Password       string `json:"-"`
 	Email          string `json:"email"`
 	DOB            string `json:"dob"`
 	DepartmentName string `json:"department_name"`
 	DepartmentID   int64  `json:"department_id"`
 	Position       string `json:"position"`
+	Role           Role   `json:"role"`
 }
 
 type Department struct {
@@ -18,3 +19,16 @@ type Department struct {
 	Name string `json:"name"`
 }
 
+// EmployeeUpdate describes a partial update of an employee, nil fields are left untouched.
+// Password is expected to be hashed already.
+type EmployeeUpdate struct {
+	FirstName      *string
+	LastName       *string
+	Username       *string
+	Password       *string
+	Email          *string
+	DOB            *string
+	DepartmentName *string
+	Position       *string
+	Role           *Role
+}
@@ -0,0 +1,111 @@
+package employees
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// EmployeeOrderField is a column employees can be sorted by
+type EmployeeOrderField string
+
+const (
+	OrderByID        EmployeeOrderField = "ID"
+	OrderByFirstName EmployeeOrderField = "FIRST_NAME"
+	OrderByLastName  EmployeeOrderField = "LAST_NAME"
+	OrderByUsername  EmployeeOrderField = "USERNAME"
+	OrderByEmail     EmployeeOrderField = "EMAIL"
+	OrderByDOB       EmployeeOrderField = "DOB"
+	OrderByPosition  EmployeeOrderField = "POSITION"
+)
+
+// orderColumns whitelists the columns that can end up in an ORDER BY clause
+var orderColumns = map[EmployeeOrderField]string{
+	OrderByID:        "e.ID",
+	OrderByFirstName: "e.First_Name",
+	OrderByLastName:  "e.Last_Name",
+	OrderByUsername:  "e.Username",
+	OrderByEmail:     "e.Email",
+	OrderByDOB:       "e.DOB",
+	OrderByPosition:  "e.Position",
+}
+
+// EmployeeFilter narrows down a listing, empty fields are ignored
+type EmployeeFilter struct {
+	Name        string
+	Department  string
+	Position    string
+	EmailDomain string
+	DOBFrom     string
+	DOBTo       string
+}
+
+type EmployeeOrder struct {
+	Field EmployeeOrderField
+	Desc  bool
+}
+
+// EmployeeQuery describes one page of a filtered and sorted employee listing
+type EmployeeQuery struct {
+	Filter  EmployeeFilter
+	OrderBy []EmployeeOrder
+	Offset  int
+	Limit   int
+}
+
+// buildEmployeeQuery turns q into a parameterised WHERE and ORDER BY clause
+func buildEmployeeQuery(d dialect, q EmployeeQuery) (string, string, []interface{}, error) {
+	var conditions []string
+	var args []interface{}
+	add := func(condition string, name string, value interface{}) {
+		conditions = append(conditions, condition)
+		args = append(args, sql.Named(name, value))
+	}
+
+	f := q.Filter
+	if f.Name != "" {
+		name := fmt.Sprintf(`(e.First_Name %[1]s @Name ESCAPE '\' OR e.Last_Name %[1]s @Name ESCAPE '\' OR e.First_Name %[2]s ' ' %[2]s e.Last_Name %[1]s @Name ESCAPE '\')`, d.like, d.concat)
+		add(name, "Name", "%"+d.escapeLike(f.Name)+"%")
+	}
+	if f.Department != "" {
+		add("d.Name = @Department", "Department", f.Department)
+	}
+	if f.Position != "" {
+		add("e.Position = @Position", "Position", f.Position)
+	}
+	if f.EmailDomain != "" {
+		add("e.Email "+d.like+` @EmailDomain ESCAPE '\'`, "EmailDomain", "%@"+d.escapeLike(strings.TrimPrefix(f.EmailDomain, "@")))
+	}
+	if f.DOBFrom != "" {
+		add("e.DOB >= @DOBFrom", "DOBFrom", f.DOBFrom)
+	}
+	if f.DOBTo != "" {
+		add("e.DOB <= @DOBTo", "DOBTo", f.DOBTo)
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var orderBy []string
+	sortedByID := false
+	for _, o := range q.OrderBy {
+		column, ok := orderColumns[o.Field]
+		if !ok {
+			return "", "", nil, fmt.Errorf("cannot order employees by %q", o.Field)
+		}
+		direction := "ASC"
+		if o.Desc {
+			direction = "DESC"
+		}
+		orderBy = append(orderBy, column+" "+direction)
+		sortedByID = sortedByID || o.Field == OrderByID
+	}
+	// always end on the primary key so pages are stable
+	if !sortedByID {
+		orderBy = append(orderBy, "e.ID ASC")
+	}
+
+	return where, "ORDER BY " + strings.Join(orderBy, ", "), args, nil
+}
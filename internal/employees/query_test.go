@@ -0,0 +1,124 @@
+package employees
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildEmployeeQuery(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     EmployeeQuery
+		wantWhere string
+		wantOrder string
+		wantArgs  []interface{}
+		wantErr   bool
+	}{
+		{
+			name:      "no filter",
+			query:     EmployeeQuery{},
+			wantWhere: "",
+			wantOrder: "ORDER BY e.ID ASC",
+		},
+		{
+			name: "all filters",
+			query: EmployeeQuery{
+				Filter: EmployeeFilter{
+					Name:        "jo",
+					Department:  "Engineering",
+					Position:    "Engineer",
+					EmailDomain: "@example.com",
+					DOBFrom:     "1990-01-01",
+					DOBTo:       "1999-12-31",
+				},
+			},
+			wantWhere: `WHERE (e.First_Name LIKE @Name ESCAPE '\' OR e.Last_Name LIKE @Name ESCAPE '\' OR e.First_Name + ' ' + e.Last_Name LIKE @Name ESCAPE '\') AND d.Name = @Department AND e.Position = @Position AND e.Email LIKE @EmailDomain ESCAPE '\' AND e.DOB >= @DOBFrom AND e.DOB <= @DOBTo`,
+			wantOrder: "ORDER BY e.ID ASC",
+			wantArgs: []interface{}{
+				sql.Named("Name", "%jo%"),
+				sql.Named("Department", "Engineering"),
+				sql.Named("Position", "Engineer"),
+				sql.Named("EmailDomain", "%@example.com"),
+				sql.Named("DOBFrom", "1990-01-01"),
+				sql.Named("DOBTo", "1999-12-31"),
+			},
+		},
+		{
+			name:      "wildcards are escaped",
+			query:     EmployeeQuery{Filter: EmployeeFilter{Name: "100%_"}},
+			wantWhere: `WHERE (e.First_Name LIKE @Name ESCAPE '\' OR e.Last_Name LIKE @Name ESCAPE '\' OR e.First_Name + ' ' + e.Last_Name LIKE @Name ESCAPE '\')`,
+			wantOrder: "ORDER BY e.ID ASC",
+			wantArgs:  []interface{}{sql.Named("Name", `%100\%\_%`)},
+		},
+		{
+			name: "sorted",
+			query: EmployeeQuery{
+				OrderBy: []EmployeeOrder{{Field: OrderByLastName}, {Field: OrderByDOB, Desc: true}},
+			},
+			wantOrder: "ORDER BY e.Last_Name ASC, e.DOB DESC, e.ID ASC",
+		},
+		{
+			name:      "sorted by id",
+			query:     EmployeeQuery{OrderBy: []EmployeeOrder{{Field: OrderByID, Desc: true}}},
+			wantOrder: "ORDER BY e.ID DESC",
+		},
+		{
+			name:    "unknown order field",
+			query:   EmployeeQuery{OrderBy: []EmployeeOrder{{Field: "Password"}}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			where, orderBy, args, err := buildEmployeeQuery(mssql, tt.query)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantWhere, where)
+			assert.Equal(t, tt.wantOrder, orderBy)
+			assert.Equal(t, tt.wantArgs, args)
+		})
+	}
+}
+
+func TestBuildEmployeeQueryDialects(t *testing.T) {
+	q := EmployeeQuery{Filter: EmployeeFilter{Name: "[a]%", EmailDomain: "example.com"}}
+	tests := []struct {
+		name      string
+		dialect   dialect
+		wantWhere string
+		wantArgs  []interface{}
+	}{
+		{
+			name:      "postgres",
+			dialect:   postgres,
+			wantWhere: `WHERE (e.First_Name ILIKE @Name ESCAPE '\' OR e.Last_Name ILIKE @Name ESCAPE '\' OR e.First_Name || ' ' || e.Last_Name ILIKE @Name ESCAPE '\') AND e.Email ILIKE @EmailDomain ESCAPE '\'`,
+			wantArgs:  []interface{}{sql.Named("Name", `%[a]\%%`), sql.Named("EmailDomain", "%@example.com")},
+		},
+		{
+			name:      "sqlite",
+			dialect:   sqlite,
+			wantWhere: `WHERE (e.First_Name LIKE @Name ESCAPE '\' OR e.Last_Name LIKE @Name ESCAPE '\' OR e.First_Name || ' ' || e.Last_Name LIKE @Name ESCAPE '\') AND e.Email LIKE @EmailDomain ESCAPE '\'`,
+			wantArgs:  []interface{}{sql.Named("Name", `%[a]\%%`), sql.Named("EmailDomain", "%@example.com")},
+		},
+		{
+			name:      "mssql escapes brackets",
+			dialect:   mssql,
+			wantWhere: `WHERE (e.First_Name LIKE @Name ESCAPE '\' OR e.Last_Name LIKE @Name ESCAPE '\' OR e.First_Name + ' ' + e.Last_Name LIKE @Name ESCAPE '\') AND e.Email LIKE @EmailDomain ESCAPE '\'`,
+			wantArgs:  []interface{}{sql.Named("Name", `%\[a]\%%`), sql.Named("EmailDomain", "%@example.com")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			where, _, args, err := buildEmployeeQuery(tt.dialect, q)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantWhere, where)
+			assert.Equal(t, tt.wantArgs, args)
+		})
+	}
+}
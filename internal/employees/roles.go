@@ -0,0 +1,31 @@
+package employees
+
+// Role decides what an employee is allowed to do
+type Role string
+
+const (
+	RoleAdmin    Role = "admin"
+	RoleHR       Role = "hr"
+	RoleManager  Role = "manager"
+	RoleEmployee Role = "employee"
+)
+
+// roleRanks orders the roles, every role is granted what the roles below it can do
+var roleRanks = map[Role]int{
+	RoleEmployee: 1,
+	RoleManager:  2,
+	RoleHR:       3,
+	RoleAdmin:    4,
+}
+
+// Valid reports whether r is one of the known roles
+func (r Role) Valid() bool {
+	_, ok := roleRanks[r]
+	return ok
+}
+
+// Satisfies reports whether r grants at least the permissions of required
+func (r Role) Satisfies(required Role) bool {
+	rank, ok := roleRanks[r]
+	return ok && rank >= roleRanks[required]
+}
@@ -0,0 +1,26 @@
+package employees
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoleSatisfies(t *testing.T) {
+	tests := []struct {
+		role     Role
+		required Role
+		want     bool
+	}{
+		{RoleAdmin, RoleHR, true},
+		{RoleHR, RoleHR, true},
+		{RoleManager, RoleHR, false},
+		{RoleEmployee, RoleEmployee, true},
+		{RoleEmployee, RoleManager, false},
+		{Role(""), RoleEmployee, false},
+		{Role("root"), RoleEmployee, false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, tt.role.Satisfies(tt.required), "%q satisfies %q", tt.role, tt.required)
+	}
+}
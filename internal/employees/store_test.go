@@ -0,0 +1,81 @@
+package employees_test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pascaloseko/ems/internal/employees"
+	"github.com/pascaloseko/ems/internal/employees/storetest"
+	"github.com/pascaloseko/ems/internal/pkg/db/database"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func testHasher(t *testing.T) employees.PasswordHasher {
+	hasher, err := employees.NewBcryptHasher(bcrypt.MinCost)
+	require.NoError(t, err)
+	return hasher
+}
+
+func TestSQLiteStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) employees.Store {
+		db := migrate(t, "sqlite:"+filepath.Join(t.TempDir(), "ems.db"))
+		return employees.NewSQLiteStore(db, testHasher(t))
+	})
+}
+
+func TestMemoryStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) employees.Store {
+		return employees.NewMemoryStore(testHasher(t))
+	})
+}
+
+// TestPostgresStore runs against the database in EMS_TEST_POSTGRES_URL, whose
+// employees and departments are deleted
+func TestPostgresStore(t *testing.T) {
+	db := openTestDB(t, "EMS_TEST_POSTGRES_URL")
+	storetest.Run(t, func(t *testing.T) employees.Store {
+		truncate(t, db)
+		return employees.NewPostgresStore(db, testHasher(t))
+	})
+}
+
+// TestMSSQLStore runs against the database in EMS_TEST_MSSQL_URL, whose
+// employees and departments are deleted
+func TestMSSQLStore(t *testing.T) {
+	db := openTestDB(t, "EMS_TEST_MSSQL_URL")
+	storetest.Run(t, func(t *testing.T) employees.Store {
+		truncate(t, db)
+		return employees.NewEmployeeStore(db, testHasher(t))
+	})
+}
+
+func openTestDB(t *testing.T, env string) *sql.DB {
+	url := os.Getenv(env)
+	if url == "" {
+		t.Skipf("%s is not set", env)
+	}
+	return migrate(t, url)
+}
+
+// migrate opens the database at url and applies the pending migrations
+func migrate(t *testing.T, url string) *sql.DB {
+	db, dialect, err := database.Open(url)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	migrator, err := database.NewMigrator(db, dialect)
+	require.NoError(t, err)
+	_, err = migrator.Up(context.Background())
+	require.NoError(t, err)
+	return db
+}
+
+func truncate(t *testing.T, db *sql.DB) {
+	for _, table := range []string{"Employee_Entities", "Department_Entities"} {
+		_, err := db.Exec("DELETE FROM " + table)
+		require.NoError(t, err)
+	}
+}
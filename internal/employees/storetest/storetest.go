@@ -0,0 +1,565 @@
+// Package storetest is a conformance suite for implementations of
+// employees.Store, so that every backend behaves like the SQL Server one.
+package storetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/pascaloseko/ems/internal/employees"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Run runs the suite, newStore must return an empty store on every call
+func Run(t *testing.T, newStore func(t *testing.T) employees.Store) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, store employees.Store)
+	}{
+		{"SaveAndGet", testSaveAndGet},
+		{"Missing", testMissing},
+		{"Update", testUpdate},
+		{"UpdateIsPartial", testUpdateIsPartial},
+		{"UpdatePassword", testUpdatePassword},
+		{"UpdateDepartment", testUpdateDepartment},
+		{"Delete", testDelete},
+		{"Uniqueness", testUniqueness},
+		{"GetEmployeesByIDs", testGetEmployeesByIDs},
+		{"Authenticate", testAuthenticate},
+		{"Departments", testDepartments},
+		{"RenameDepartment", testRenameDepartment},
+		{"DeleteDepartment", testDeleteDepartment},
+		{"FindEmployees", testFindEmployees},
+		{"FindEmployeesPaging", testFindEmployeesPaging},
+		{"SaveIsAtomic", testSaveIsAtomic},
+		{"ConcurrentSaves", testConcurrentSaves},
+		{"WithTx", testWithTx},
+		{"WithTxRollback", testWithTxRollback},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newStore(t))
+		})
+	}
+}
+
+var ada = employees.Employee{
+	FirstName:      "Ada",
+	LastName:       "Lovelace",
+	Username:       "ada",
+	Password:       "not-a-real-hash",
+	Email:          "ada@example.com",
+	DOB:            "1815-12-10",
+	DepartmentName: "Engineering",
+	Position:       "Engineer",
+	Role:           employees.RoleHR,
+}
+
+var grace = employees.Employee{
+	FirstName:      "Grace",
+	LastName:       "Hopper",
+	Username:       "grace",
+	Password:       "not-a-real-hash",
+	Email:          "grace@navy.example.org",
+	DOB:            "1906-12-09",
+	DepartmentName: "Research",
+	Position:       "Admiral",
+}
+
+var alan = employees.Employee{
+	FirstName:      "Alan",
+	LastName:       "Turing",
+	Username:       "alan",
+	Password:       "not-a-real-hash",
+	Email:          "alan@example.com",
+	DOB:            "1912-06-23",
+	DepartmentName: "Research",
+	Position:       "Engineer",
+}
+
+// save stores emp and returns it as the store reads it back
+func save(t *testing.T, store employees.Store, emp employees.Employee) employees.Employee {
+	t.Helper()
+	id, err := store.Save(context.Background(), emp)
+	require.NoError(t, err)
+	require.NotZero(t, id)
+	saved, err := store.GetEmployeeByID(context.Background(), id)
+	require.NoError(t, err)
+	return saved
+}
+
+func isEmployeeNotFound(err error) bool {
+	var notFound *employees.EmployeeNotFoundError
+	return errors.As(err, &notFound)
+}
+
+func isWrongCredentials(err error) bool {
+	var wrong *employees.WrongUsernameOrPasswordError
+	return errors.As(err, &wrong)
+}
+
+func isDepartmentNotFound(err error) bool {
+	var notFound *employees.DepartmentNotFoundError
+	return errors.As(err, &notFound)
+}
+
+func testSaveAndGet(t *testing.T, store employees.Store) {
+	ctx := context.Background()
+	saved := save(t, store, ada)
+
+	departmentID, err := store.GetDepartmentIdByName(ctx, "Engineering")
+	require.NoError(t, err)
+	require.NotZero(t, departmentID, "Save creates missing departments")
+	assert.Equal(t, employees.Employee{
+		ID:           saved.ID,
+		FirstName:    "Ada",
+		LastName:     "Lovelace",
+		Username:     "ada",
+		Email:        "ada@example.com",
+		DOB:          "1815-12-10",
+		DepartmentID: departmentID,
+		Position:     "Engineer",
+		Role:         employees.RoleHR,
+	}, saved)
+
+	byUsername, err := store.GetEmployeeByUsername(ctx, "ada")
+	require.NoError(t, err)
+	assert.Equal(t, saved, byUsername)
+	byEmail, err := store.GetEmployeeByEmail(ctx, "ada@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, saved, byEmail)
+	id, err := store.GetEmployeeIdByUsername(ctx, "ada")
+	require.NoError(t, err)
+	assert.Equal(t, saved.ID, id)
+	department, err := store.GetDepartmentByID(ctx, departmentID)
+	require.NoError(t, err)
+	assert.Equal(t, "Engineering", department.Name)
+
+	// the department is reused and the role defaults to employee
+	colleague := ada
+	colleague.Username, colleague.Email, colleague.Role = "ada2", "ada2@example.com", ""
+	other := save(t, store, colleague)
+	assert.NotEqual(t, saved.ID, other.ID)
+	assert.Equal(t, departmentID, other.DepartmentID)
+	assert.Equal(t, employees.RoleEmployee, other.Role)
+}
+
+func testMissing(t *testing.T, store employees.Store) {
+	ctx := context.Background()
+
+	_, err := store.GetEmployeeByID(ctx, 4242)
+	assert.True(t, isEmployeeNotFound(err), "GetEmployeeByID: %v", err)
+	_, err = store.GetEmployeeByUsername(ctx, "nobody")
+	assert.True(t, isEmployeeNotFound(err), "GetEmployeeByUsername: %v", err)
+	_, err = store.GetEmployeeByEmail(ctx, "nobody@example.com")
+	assert.True(t, isEmployeeNotFound(err), "GetEmployeeByEmail: %v", err)
+	_, err = store.GetDepartmentByID(ctx, 4242)
+	assert.True(t, isDepartmentNotFound(err), "GetDepartmentByID: %v", err)
+
+	// the lookups by name report a missing row with a zero value
+	id, err := store.GetEmployeeIdByUsername(ctx, "nobody")
+	require.NoError(t, err)
+	assert.Zero(t, id)
+	id, err = store.GetDepartmentIdByName(ctx, "Nowhere")
+	require.NoError(t, err)
+	assert.Zero(t, id)
+}
+
+func testUpdate(t *testing.T, store employees.Store) {
+	ctx := context.Background()
+	saved := save(t, store, ada)
+
+	position, department, role := "Principal Engineer", "Analytical Engines", employees.RoleAdmin
+	updated, err := store.Update(ctx, saved.ID, employees.EmployeeUpdate{
+		Position:       &position,
+		DepartmentName: &department,
+		Role:           &role,
+	})
+	require.NoError(t, err)
+	departmentID, err := store.GetDepartmentIdByName(ctx, department)
+	require.NoError(t, err)
+	require.NotZero(t, departmentID)
+	want := saved
+	want.Position, want.DepartmentID, want.Role = position, departmentID, role
+	assert.Equal(t, want, updated)
+
+	// an empty update returns the employee unchanged
+	unchanged, err := store.Update(ctx, saved.ID, employees.EmployeeUpdate{})
+	require.NoError(t, err)
+	assert.Equal(t, want, unchanged)
+
+	password := "new-hash"
+	_, err = store.Update(ctx, saved.ID, employees.EmployeeUpdate{Password: &password})
+	require.NoError(t, err)
+	_, err = store.Update(ctx, 4242, employees.EmployeeUpdate{Position: &position})
+	assert.True(t, isEmployeeNotFound(err), "Update: %v", err)
+}
+
+func testUpdateIsPartial(t *testing.T, store employees.Store) {
+	ctx := context.Background()
+	saved := save(t, store, ada)
+	other := save(t, store, grace)
+
+	firstName, email := "Augusta", "augusta@example.com"
+	updated, err := store.Update(ctx, saved.ID, employees.EmployeeUpdate{FirstName: &firstName, Email: &email})
+	require.NoError(t, err)
+	want := saved
+	want.FirstName, want.Email = firstName, email
+	assert.Equal(t, want, updated)
+	reread, err := store.GetEmployeeByID(ctx, saved.ID)
+	require.NoError(t, err)
+	assert.Equal(t, want, reread)
+
+	// other employees are left alone
+	untouched, err := store.GetEmployeeByID(ctx, other.ID)
+	require.NoError(t, err)
+	assert.Equal(t, other, untouched)
+}
+
+func testUpdatePassword(t *testing.T, store employees.Store) {
+	ctx := context.Background()
+	hash, err := store.HashPassword(ctx, "correct horse battery")
+	require.NoError(t, err)
+	emp := ada
+	emp.Password = hash
+	saved := save(t, store, emp)
+
+	newHash, err := store.HashPassword(ctx, "staple battery horse")
+	require.NoError(t, err)
+	require.NotEqual(t, hash, newHash)
+	updated, err := store.Update(ctx, saved.ID, employees.EmployeeUpdate{Password: &newHash})
+	require.NoError(t, err)
+	assert.Empty(t, updated.Password, "the hash is not read back")
+
+	assert.NoError(t, store.Authenticate(ctx, employees.Employee{Username: "ada", Password: "staple battery horse"}))
+	err = store.Authenticate(ctx, employees.Employee{Username: "ada", Password: "correct horse battery"})
+	assert.True(t, isWrongCredentials(err), "Authenticate: %v", err)
+}
+
+func testUpdateDepartment(t *testing.T, store employees.Store) {
+	ctx := context.Background()
+	saved := save(t, store, ada)
+	other := save(t, store, grace)
+
+	// moving to an existing department reuses it rather than creating another
+	updated, err := store.Update(ctx, saved.ID, employees.EmployeeUpdate{DepartmentName: &grace.DepartmentName})
+	require.NoError(t, err)
+	assert.Equal(t, other.DepartmentID, updated.DepartmentID)
+	departments, err := store.GetDepartments(ctx)
+	require.NoError(t, err)
+	assert.Len(t, departments, 2)
+
+	// the department left behind stays, even with nobody in it
+	_, err = store.GetDepartmentByID(ctx, saved.DepartmentID)
+	assert.NoError(t, err)
+}
+
+func testDelete(t *testing.T, store employees.Store) {
+	ctx := context.Background()
+	saved := save(t, store, ada)
+
+	require.NoError(t, store.Delete(ctx, saved.ID))
+	_, err := store.GetEmployeeByID(ctx, saved.ID)
+	assert.True(t, isEmployeeNotFound(err), "GetEmployeeByID: %v", err)
+	err = store.Delete(ctx, saved.ID)
+	assert.True(t, isEmployeeNotFound(err), "Delete: %v", err)
+}
+
+func testUniqueness(t *testing.T, store employees.Store) {
+	ctx := context.Background()
+	saved := save(t, store, ada)
+	other := save(t, store, grace)
+
+	sameUsername := alan
+	sameUsername.Username = ada.Username
+	_, err := store.Save(ctx, sameUsername)
+	assert.ErrorIs(t, err, employees.ErrDuplicateUsername)
+	sameEmail := alan
+	sameEmail.Email = ada.Email
+	_, err = store.Save(ctx, sameEmail)
+	assert.ErrorIs(t, err, employees.ErrDuplicateEmail)
+
+	_, err = store.Update(ctx, saved.ID, employees.EmployeeUpdate{Username: &grace.Username})
+	assert.ErrorIs(t, err, employees.ErrDuplicateUsername)
+	_, err = store.Update(ctx, saved.ID, employees.EmployeeUpdate{Email: &grace.Email})
+	assert.ErrorIs(t, err, employees.ErrDuplicateEmail)
+	_, err = store.Update(ctx, saved.ID, employees.EmployeeUpdate{Username: &ada.Username, Email: &ada.Email})
+	assert.NoError(t, err, "own username and email")
+
+	// usernames and emails of deleted employees can be taken again
+	require.NoError(t, store.Delete(ctx, other.ID))
+	save(t, store, grace)
+}
+
+func testGetEmployeesByIDs(t *testing.T, store employees.Store) {
+	ctx := context.Background()
+	a := save(t, store, ada)
+	g := save(t, store, grace)
+
+	found, err := store.GetEmployeesByIDs(ctx, []int64{g.ID, 4242, a.ID})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []employees.Employee{a, g}, found)
+
+	found, err = store.GetEmployeesByIDs(ctx, nil)
+	require.NoError(t, err)
+	assert.Empty(t, found)
+}
+
+func testAuthenticate(t *testing.T, store employees.Store) {
+	ctx := context.Background()
+	hash, err := store.HashPassword(ctx, "correct horse battery")
+	require.NoError(t, err)
+	emp := ada
+	emp.Password = hash
+	save(t, store, emp)
+
+	assert.NoError(t, store.Authenticate(ctx, employees.Employee{Username: "ada", Password: "correct horse battery"}))
+	err = store.Authenticate(ctx, employees.Employee{Username: "ada", Password: "wrong horse battery"})
+	assert.True(t, isWrongCredentials(err), "Authenticate: %v", err)
+	err = store.Authenticate(ctx, employees.Employee{Username: "nobody", Password: "correct horse battery"})
+	assert.True(t, isWrongCredentials(err), "Authenticate: %v", err)
+
+	// a hash that cannot be checked is an error of its own, not a wrong password
+	save(t, store, grace)
+	err = store.Authenticate(ctx, employees.Employee{Username: "grace", Password: "correct horse battery"})
+	assert.ErrorIs(t, err, employees.ErrUnknownHashFormat)
+}
+
+func testDepartments(t *testing.T, store employees.Store) {
+	ctx := context.Background()
+	research, err := store.SaveDepartment(ctx, employees.Department{Name: "Research"})
+	require.NoError(t, err)
+	engineering, err := store.SaveDepartment(ctx, employees.Department{Name: "Engineering"})
+	require.NoError(t, err)
+	require.NotEqual(t, research, engineering)
+	_, err = store.SaveDepartment(ctx, employees.Department{Name: "Research"})
+	var exists *employees.DepartmentExistsError
+	assert.True(t, errors.As(err, &exists), "SaveDepartment: %v", err)
+
+	departments, err := store.GetDepartments(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []employees.Department{{ID: engineering, Name: "Engineering"}, {ID: research, Name: "Research"}}, departments, "sorted by name")
+
+	department, err := store.GetDepartmentByID(ctx, research)
+	require.NoError(t, err)
+	assert.Equal(t, employees.Department{ID: research, Name: "Research"}, department)
+
+	found, err := store.GetDepartmentsByIDs(ctx, []int64{research, 4242})
+	require.NoError(t, err)
+	assert.Equal(t, []employees.Department{{ID: research, Name: "Research"}}, found)
+	found, err = store.GetDepartmentsByIDs(ctx, nil)
+	require.NoError(t, err)
+	assert.Empty(t, found)
+}
+
+func testRenameDepartment(t *testing.T, store employees.Store) {
+	ctx := context.Background()
+	research, err := store.SaveDepartment(ctx, employees.Department{Name: "Research"})
+	require.NoError(t, err)
+	_, err = store.SaveDepartment(ctx, employees.Department{Name: "Engineering"})
+	require.NoError(t, err)
+
+	renamed, err := store.RenameDepartment(ctx, research, "R&D")
+	require.NoError(t, err)
+	assert.Equal(t, employees.Department{ID: research, Name: "R&D"}, renamed)
+	department, err := store.GetDepartmentByID(ctx, research)
+	require.NoError(t, err)
+	assert.Equal(t, renamed, department)
+
+	// renaming to its own name is allowed, taking another department's is not
+	_, err = store.RenameDepartment(ctx, research, "R&D")
+	require.NoError(t, err)
+	_, err = store.RenameDepartment(ctx, research, "Engineering")
+	var exists *employees.DepartmentExistsError
+	assert.True(t, errors.As(err, &exists), "RenameDepartment: %v", err)
+	_, err = store.RenameDepartment(ctx, 4242, "Nowhere")
+	assert.True(t, isDepartmentNotFound(err), "RenameDepartment: %v", err)
+}
+
+func testDeleteDepartment(t *testing.T, store employees.Store) {
+	ctx := context.Background()
+	saved := save(t, store, ada)
+
+	err := store.DeleteDepartment(ctx, saved.DepartmentID)
+	var inUse *employees.DepartmentInUseError
+	require.True(t, errors.As(err, &inUse), "DeleteDepartment: %v", err)
+	assert.Equal(t, 1, inUse.Employees)
+
+	require.NoError(t, store.Delete(ctx, saved.ID))
+	require.NoError(t, store.DeleteDepartment(ctx, saved.DepartmentID))
+	_, err = store.GetDepartmentByID(ctx, saved.DepartmentID)
+	assert.True(t, isDepartmentNotFound(err), "GetDepartmentByID: %v", err)
+	err = store.DeleteDepartment(ctx, saved.DepartmentID)
+	assert.True(t, isDepartmentNotFound(err), "DeleteDepartment: %v", err)
+}
+
+func testFindEmployees(t *testing.T, store employees.Store) {
+	a := save(t, store, ada)
+	g := save(t, store, grace)
+	l := save(t, store, alan)
+	wildcard := alan
+	wildcard.FirstName, wildcard.Username, wildcard.Email = "100%_[x]", "wildcard", "wildcard@example.com"
+	w := save(t, store, wildcard)
+
+	tests := []struct {
+		name   string
+		filter employees.EmployeeFilter
+		want   []employees.Employee
+	}{
+		{"everyone", employees.EmployeeFilter{}, []employees.Employee{a, g, l, w}},
+		{"first name", employees.EmployeeFilter{Name: "gra"}, []employees.Employee{g}},
+		{"last name ignoring case", employees.EmployeeFilter{Name: "LOVE"}, []employees.Employee{a}},
+		{"full name", employees.EmployeeFilter{Name: "alan tur"}, []employees.Employee{l}},
+		{"wildcards match literally", employees.EmployeeFilter{Name: "%_["}, []employees.Employee{w}},
+		{"department", employees.EmployeeFilter{Department: "Research"}, []employees.Employee{g, l, w}},
+		{"position", employees.EmployeeFilter{Position: "Engineer"}, []employees.Employee{a, l, w}},
+		{"email domain", employees.EmployeeFilter{EmailDomain: "@example.com"}, []employees.Employee{a, l, w}},
+		{"born from", employees.EmployeeFilter{DOBFrom: "1906-12-09"}, []employees.Employee{g, l, w}},
+		{"born between", employees.EmployeeFilter{DOBFrom: "1900-01-01", DOBTo: "1910-01-01"}, []employees.Employee{g}},
+		{"combined", employees.EmployeeFilter{Department: "Research", Position: "Engineer", Name: "turing"}, []employees.Employee{l, w}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found, total, err := store.FindEmployees(context.Background(), employees.EmployeeQuery{Filter: tt.filter, Limit: 10})
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, found)
+			assert.Equal(t, len(tt.want), total)
+		})
+	}
+}
+
+func testFindEmployeesPaging(t *testing.T, store employees.Store) {
+	ctx := context.Background()
+	a := save(t, store, ada)
+	g := save(t, store, grace)
+	l := save(t, store, alan)
+
+	byLastName := []employees.EmployeeOrder{{Field: employees.OrderByLastName, Desc: true}}
+	found, total, err := store.FindEmployees(ctx, employees.EmployeeQuery{OrderBy: byLastName, Limit: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []employees.Employee{l, a}, found)
+	assert.Equal(t, 3, total)
+
+	found, total, err = store.FindEmployees(ctx, employees.EmployeeQuery{OrderBy: byLastName, Offset: 2, Limit: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []employees.Employee{g}, found)
+	assert.Equal(t, 3, total)
+
+	// a zero limit only counts
+	found, total, err = store.FindEmployees(ctx, employees.EmployeeQuery{})
+	require.NoError(t, err)
+	assert.Empty(t, found)
+	assert.Equal(t, 3, total)
+
+	_, _, err = store.FindEmployees(ctx, employees.EmployeeQuery{OrderBy: []employees.EmployeeOrder{{Field: "Password"}}, Limit: 1})
+	assert.Error(t, err)
+}
+
+func testSaveIsAtomic(t *testing.T, store employees.Store) {
+	ctx := context.Background()
+	save(t, store, ada)
+
+	// the department of an employee that cannot be saved is not created either
+	sameEmail := alan
+	sameEmail.Email, sameEmail.DepartmentName = ada.Email, "Difference Engines"
+	_, err := store.Save(ctx, sameEmail)
+	require.ErrorIs(t, err, employees.ErrDuplicateEmail)
+	id, err := store.GetDepartmentIdByName(ctx, "Difference Engines")
+	require.NoError(t, err)
+	assert.Zero(t, id)
+}
+
+func testConcurrentSaves(t *testing.T, store employees.Store) {
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			emp := alan
+			emp.Username, emp.Email = fmt.Sprintf("user%d", i), fmt.Sprintf("user%d@example.com", i)
+			_, err := store.Save(ctx, emp)
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+
+	// the department was created once however the saves interleaved
+	departments, err := store.GetDepartments(ctx)
+	require.NoError(t, err)
+	assert.Len(t, departments, 1)
+	_, total, err := store.FindEmployees(ctx, employees.EmployeeQuery{Filter: employees.EmployeeFilter{Department: alan.DepartmentName}})
+	require.NoError(t, err)
+	assert.Equal(t, 20, total)
+}
+
+func testWithTx(t *testing.T, store employees.Store) {
+	ctx := context.Background()
+	saved := save(t, store, ada)
+
+	// a transfer: one employee leaves a department, another joins it
+	var other int64
+	err := store.WithTx(ctx, func(tx employees.Store) error {
+		var err error
+		other, err = tx.Save(ctx, grace)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Update(ctx, saved.ID, employees.EmployeeUpdate{DepartmentName: &grace.DepartmentName}); err != nil {
+			return err
+		}
+		// nested calls join the transaction
+		return tx.WithTx(ctx, func(tx employees.Store) error {
+			_, err := tx.Update(ctx, other, employees.EmployeeUpdate{DepartmentName: &ada.DepartmentName})
+			return err
+		})
+	})
+	require.NoError(t, err)
+
+	research, err := store.GetDepartmentIdByName(ctx, grace.DepartmentName)
+	require.NoError(t, err)
+	engineering, err := store.GetDepartmentIdByName(ctx, ada.DepartmentName)
+	require.NoError(t, err)
+	got, err := store.GetEmployeeByID(ctx, saved.ID)
+	require.NoError(t, err)
+	assert.Equal(t, research, got.DepartmentID)
+	got, err = store.GetEmployeeByID(ctx, other)
+	require.NoError(t, err)
+	assert.Equal(t, engineering, got.DepartmentID)
+}
+
+func testWithTxRollback(t *testing.T, store employees.Store) {
+	ctx := context.Background()
+	saved := save(t, store, ada)
+
+	// a bulk import that fails half way imports nothing
+	failed := errors.New("row 3 is invalid")
+	err := store.WithTx(ctx, func(tx employees.Store) error {
+		for _, emp := range []employees.Employee{grace, alan} {
+			if _, err := tx.Save(ctx, emp); err != nil {
+				return err
+			}
+		}
+		position := "Countess"
+		if _, err := tx.Update(ctx, saved.ID, employees.EmployeeUpdate{Position: &position}); err != nil {
+			return err
+		}
+		return failed
+	})
+	require.ErrorIs(t, err, failed)
+
+	id, err := store.GetEmployeeIdByUsername(ctx, grace.Username)
+	require.NoError(t, err)
+	assert.Zero(t, id)
+	departments, err := store.GetDepartments(ctx)
+	require.NoError(t, err)
+	require.Len(t, departments, 1)
+	assert.Equal(t, ada.DepartmentName, departments[0].Name)
+	got, err := store.GetEmployeeByID(ctx, saved.ID)
+	require.NoError(t, err)
+	assert.Equal(t, ada.Position, got.Position)
+}
@@ -4,19 +4,36 @@ import (
 	"encoding/json"
 	"errors"
 	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/pascaloseko/ems/graph"
 	"github.com/pascaloseko/ems/graph/model"
+	"github.com/pascaloseko/ems/internal/apikeys"
+	"github.com/pascaloseko/ems/internal/auth"
+	"github.com/pascaloseko/ems/internal/employees"
+	"github.com/pascaloseko/ems/internal/lockout"
+	"github.com/pascaloseko/ems/internal/oidc"
+	"github.com/pascaloseko/ems/internal/passwords"
+	"github.com/pascaloseko/ems/internal/pkg/jwt"
+	"github.com/pascaloseko/ems/internal/twofactor"
 )
 
 type Handlers struct {
 	resolver *graph.Resolver
+	tokens   *jwt.Issuer
+	oidc     *oidc.Provider
 }
 
-func NewHandlers(resolver *graph.Resolver) *Handlers {
+// NewHandlers returns the REST handlers, provider may be nil when single
+// sign-on is not configured
+func NewHandlers(resolver *graph.Resolver, tokens *jwt.Issuer, provider *oidc.Provider) *Handlers {
 	return &Handlers{
 		resolver: resolver,
+		tokens:   tokens,
+		oidc:     provider,
 	}
 }
 
@@ -27,7 +44,7 @@ func (h *Handlers) LoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var credentials model.Employee
+	var credentials model.Login
 	if err := json.NewDecoder(r.Body).Decode(&credentials); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
@@ -38,32 +55,130 @@ func (h *Handlers) LoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	newEmployee := model.NewEmployee{
-		FirstName: credentials.FirstName,
-		LastName:  credentials.LastName,
-		Email:     credentials.Email,
-		Username:  credentials.Username,
-		Password:  credentials.Password,
-		Dob:       credentials.Dob,
-		DepartmentID: credentials.DepartmentID,
-		Position: credentials.Position,
+	token, err := h.resolver.Mutation().Login(r.Context(), credentials)
+	if err != nil {
+		loginFailed(w, err)
+		return
+	}
+
+	// Return the JWT token pair
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(token)
+}
+
+// MFAHandler completes the login of an employee with two-factor authentication,
+// exchanging the mfaToken returned by LoginHandler and a code for a token pair
+func (h *Handlers) MFAHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var input struct {
+		MFAToken string `json:"mfaToken"`
+		Code     string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
 	}
 
-	token, err := h.resolver.Mutation().CreateEmployee(r.Context(), newEmployee)
+	if input.MFAToken == "" || input.Code == "" {
+		http.Error(w, "mfaToken or code cannot be empty", http.StatusBadRequest)
+		return
+	}
+
+	token, err := h.resolver.Mutation().VerifyMfa(r.Context(), input.MFAToken, input.Code)
 	if err != nil {
+		loginFailed(w, err)
+		return
+	}
+
+	// Return the JWT token pair
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(token)
+}
+
+// loginFailed answers a failed login with 401 for wrong credentials, 429 while
+// logins are locked out and 500 otherwise
+func loginFailed(w http.ResponseWriter, err error) {
+	var wrongCredentials *employees.WrongUsernameOrPasswordError
+	var invalidCode *twofactor.InvalidCodeError
+	var locked *lockout.AccountLockedError
+	var throttled *lockout.TooManyAttemptsError
+	switch {
+	case errors.As(err, &wrongCredentials):
+		http.Error(w, wrongCredentials.Error(), http.StatusUnauthorized)
+	case errors.As(err, &invalidCode):
+		http.Error(w, invalidCode.Error(), http.StatusUnauthorized)
+	case errors.Is(err, graph.ErrAccessDenied):
+		http.Error(w, "Invalid or expired mfaToken", http.StatusUnauthorized)
+	case errors.As(err, &locked):
+		tooManyRequests(w, locked.Error(), locked.RetryAfter)
+	case errors.As(err, &throttled):
+		tooManyRequests(w, throttled.Error(), throttled.RetryAfter)
+	default:
 		log.Println("ERROR", err)
-		http.Error(w, "Failed to create employee", http.StatusInternalServerError)
+		http.Error(w, "Failed to login", http.StatusInternalServerError)
+	}
+}
+
+// tooManyRequests answers 429, telling the client when it may retry
+func tooManyRequests(w http.ResponseWriter, message string, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	http.Error(w, message, http.StatusTooManyRequests)
+}
+
+// RegisterHandler creates a new employee and logs them in. Unless open
+// registration is enabled, only HR can call it.
+func (h *Handlers) RegisterHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var newEmployee model.NewEmployee
+	if err := json.NewDecoder(r.Body).Decode(&newEmployee); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	if token == nil {
-		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+	if newEmployee.Username == "" || newEmployee.Password == "" {
+		http.Error(w, "Password or Username cannot be empty", http.StatusBadRequest)
 		return
 	}
 
-	// Return the JWT token
+	token, err := h.resolver.Mutation().RegisterEmployee(r.Context(), newEmployee)
+	if err != nil {
+		var weakPassword *passwords.PolicyViolationError
+		if errors.As(err, &weakPassword) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error":      weakPassword.Error(),
+				"violations": weakPassword.Violations,
+			})
+			return
+		}
+		if errors.Is(err, graph.ErrAccessDenied) {
+			http.Error(w, "access denied", http.StatusForbidden)
+			return
+		}
+		for _, taken := range []error{employees.ErrDuplicateUsername, employees.ErrDuplicateEmail} {
+			if errors.Is(err, taken) {
+				http.Error(w, taken.Error(), http.StatusConflict)
+				return
+			}
+		}
+		log.Println("ERROR", err)
+		http.Error(w, "Failed to create employee", http.StatusInternalServerError)
+		return
+	}
+
+	// Return the JWT token pair
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"token": *token})
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(token)
 }
 
 // GetEmployees handles employees
@@ -72,7 +187,28 @@ func (h *Handlers) GetAllEmployeesHandler(w http.ResponseWriter, r *http.Request
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	employees, err := h.resolver.Query().Employees(r.Context())
+	// the @hasRole directive does not apply outside of GraphQL
+	if service := auth.ServiceForContext(r.Context()); service != nil && !service.HasScope(apikeys.ScopeEmployeesRead) {
+		http.Error(w, "access denied", http.StatusForbidden)
+		return
+	}
+
+	// pagination is driven by the same first/after arguments as the GraphQL query
+	var first *int
+	var after *string
+	if v := r.URL.Query().Get("first"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "Invalid first parameter", http.StatusBadRequest)
+			return
+		}
+		first = &n
+	}
+	if v := r.URL.Query().Get("after"); v != "" {
+		after = &v
+	}
+
+	employees, err := h.resolver.Query().Employees(r.Context(), nil, nil, first, after)
 	if err != nil {
 		if errors.Is(err, graph.ErrAccessDenied) {
 			http.Error(w, "access denied", http.StatusUnauthorized)
@@ -82,11 +218,16 @@ func (h *Handlers) GetAllEmployeesHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	if employees == nil {
+	if employees.TotalCount == 0 {
 		http.Error(w, "No employees found", http.StatusNotFound)
 		return
 	}
 
+	// GraphQL directives do not run here, so mask sensitive fields by hand
+	for _, edge := range employees.Edges {
+		graph.RedactEmployee(r.Context(), edge.Node)
+	}
+
 	// Return the employees
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(employees)
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/pascaloseko/ems/internal/employees"
+	"github.com/pascaloseko/ems/internal/oidc"
+)
+
+const (
+	// oidcCookie holds the state, nonce and PKCE verifier of a login in progress
+	oidcCookie = "ems_oidc"
+	// oidcCookieMaxAge is how long the user has to log in at the provider
+	oidcCookieMaxAge = 10 * 60
+)
+
+// OIDCLoginHandler starts a single sign-on login by sending the user to the
+// identity provider
+func (h *Handlers) OIDCLoginHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	req, err := oidc.NewAuthRequest()
+	if err != nil {
+		log.Println("ERROR", err)
+		http.Error(w, "Failed to start login", http.StatusInternalServerError)
+		return
+	}
+	authURL, err := h.oidc.AuthCodeURL(r.Context(), req)
+	if err != nil {
+		log.Println("ERROR", err)
+		http.Error(w, "Identity provider unavailable", http.StatusBadGateway)
+		return
+	}
+	value, err := json.Marshal(req)
+	if err != nil {
+		log.Println("ERROR", err)
+		http.Error(w, "Failed to start login", http.StatusInternalServerError)
+		return
+	}
+	setOIDCCookie(w, r, base64.RawURLEncoding.EncodeToString(value), oidcCookieMaxAge)
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// OIDCCallbackHandler completes a single sign-on login once the identity
+// provider redirects back, and returns a token pair like LoginHandler
+func (h *Handlers) OIDCCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	if reason := query.Get("error"); reason != "" {
+		http.Error(w, "Login failed at the identity provider: "+reason, http.StatusUnauthorized)
+		return
+	}
+	req, ok := oidcRequest(r)
+	if !ok {
+		http.Error(w, "Login expired or was not started here", http.StatusBadRequest)
+		return
+	}
+	// every login can only be completed once
+	setOIDCCookie(w, r, "", -1)
+	if subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(req.State)) != 1 {
+		http.Error(w, "Invalid state", http.StatusBadRequest)
+		return
+	}
+
+	identity, err := h.oidc.Callback(r.Context(), req, query.Get("code"))
+	if err != nil {
+		log.Println("ERROR", err)
+		if errors.Is(err, oidc.ErrInvalidIDToken) {
+			http.Error(w, "Invalid ID token", http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, "Identity provider unavailable", http.StatusBadGateway)
+		return
+	}
+
+	token, err := h.resolver.SingleSignOn(r.Context(), identity.Email)
+	if err != nil {
+		var notFound *employees.EmployeeNotFoundError
+		if errors.As(err, &notFound) {
+			http.Error(w, "No employee has this email", http.StatusForbidden)
+			return
+		}
+		log.Println("ERROR", err)
+		http.Error(w, "Failed to login", http.StatusInternalServerError)
+		return
+	}
+
+	// Return the JWT token pair
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(token)
+}
+
+func setOIDCCookie(w http.ResponseWriter, r *http.Request, value string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcCookie,
+		Value:    value,
+		Path:     "/auth/oidc",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+		// Lax lets the cookie through the top level redirect back from the provider
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// oidcRequest reads the login in progress from its cookie
+func oidcRequest(r *http.Request) (oidc.AuthRequest, bool) {
+	cookie, err := r.Cookie(oidcCookie)
+	if err != nil {
+		return oidc.AuthRequest{}, false
+	}
+	value, err := base64.RawURLEncoding.DecodeString(cookie.Value)
+	if err != nil {
+		return oidc.AuthRequest{}, false
+	}
+	var req oidc.AuthRequest
+	if err := json.Unmarshal(value, &req); err != nil || req.State == "" {
+		return oidc.AuthRequest{}, false
+	}
+	return req, true
+}
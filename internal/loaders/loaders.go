@@ -0,0 +1,65 @@
+package loaders
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pascaloseko/ems/internal/employees"
+	"github.com/pascaloseko/ems/internal/pkg/dataloader"
+)
+
+var loadersCtxKey = &contextKey{"loaders"}
+
+type contextKey struct {
+	name string
+}
+
+// Loaders batches the relation lookups made while resolving a single request
+type Loaders struct {
+	DepartmentByID *dataloader.Loader[int64, employees.Department]
+	EmployeeByID   *dataloader.Loader[int64, employees.Employee]
+}
+
+func NewLoaders(emp employees.Store) *Loaders {
+	return &Loaders{
+		DepartmentByID: dataloader.New(func(ctx context.Context, ids []int64) (map[int64]employees.Department, error) {
+			departments, err := emp.GetDepartmentsByIDs(ctx, ids)
+			if err != nil {
+				return nil, err
+			}
+			byID := make(map[int64]employees.Department, len(departments))
+			for _, department := range departments {
+				byID[department.ID] = department
+			}
+			return byID, nil
+		}),
+		EmployeeByID: dataloader.New(func(ctx context.Context, ids []int64) (map[int64]employees.Employee, error) {
+			found, err := emp.GetEmployeesByIDs(ctx, ids)
+			if err != nil {
+				return nil, err
+			}
+			byID := make(map[int64]employees.Employee, len(found))
+			for _, employee := range found {
+				byID[employee.ID] = employee
+			}
+			return byID, nil
+		}),
+	}
+}
+
+// Middleware gives every request its own set of loaders so cached values never
+// leak between requests
+func Middleware(emp employees.Store) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), loadersCtxKey, NewLoaders(emp))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// For finds the loaders from the context. REQUIRES Middleware to have run.
+func For(ctx context.Context) *Loaders {
+	raw, _ := ctx.Value(loadersCtxKey).(*Loaders)
+	return raw
+}
@@ -0,0 +1,23 @@
+package lockout
+
+import "time"
+
+// AccountLockedError is returned when logging into an account that is locked
+// after too many failed logins
+type AccountLockedError struct {
+	RetryAfter time.Duration
+}
+
+func (m *AccountLockedError) Error() string {
+	return "account locked after too many failed logins, try again later"
+}
+
+// TooManyAttemptsError is returned when a client made too many failed logins,
+// whichever accounts it tried
+type TooManyAttemptsError struct {
+	RetryAfter time.Duration
+}
+
+func (m *TooManyAttemptsError) Error() string {
+	return "too many failed logins, try again later"
+}
@@ -0,0 +1,237 @@
+// Package lockout slows down password guessing by locking accounts after a
+// number of failed logins and throttling clients that fail too often.
+package lockout
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	defaultThreshold       = 5
+	defaultLockDuration    = time.Minute
+	defaultMaxLockDuration = time.Hour
+	defaultClientLimit     = 50
+	defaultClientWindow    = 15 * time.Minute
+	defaultResetAfter      = 24 * time.Hour
+	// maxClients is how many client addresses are tracked before expired ones are forgotten
+	maxClients = 10000
+)
+
+// Config tunes a Guard, zero values are replaced with defaults
+type Config struct {
+	// Threshold is how many failed logins in a row lock an account (default 5)
+	Threshold int
+	// LockDuration is how long the first lock lasts, every further failure
+	// doubles it up to MaxLockDuration (default 1m and 1h)
+	LockDuration    time.Duration
+	MaxLockDuration time.Duration
+	// ClientLimit is how many failed logins a client IP may make within
+	// ClientWindow, across all accounts (default 50 per 15m)
+	ClientLimit  int
+	ClientWindow time.Duration
+	// ResetAfter is how long an account has to go without failed logins for
+	// them to be forgotten (default 24h, never less than MaxLockDuration)
+	ResetAfter time.Duration
+}
+
+// Guard decides whether a login may be attempted. Failures are counted per
+// username in the Store, so that every replica sees them, and per client IP in
+// memory.
+type Guard struct {
+	store Store
+	cfg   Config
+	now   func() time.Time
+
+	mu      sync.Mutex
+	clients map[string]*clientFailures
+}
+
+// clientFailures counts the failed logins of a client IP since the window started
+type clientFailures struct {
+	count int
+	since time.Time
+}
+
+func NewGuard(store Store, cfg Config) *Guard {
+	if cfg.Threshold <= 0 {
+		cfg.Threshold = defaultThreshold
+	}
+	if cfg.LockDuration <= 0 {
+		cfg.LockDuration = defaultLockDuration
+	}
+	if cfg.MaxLockDuration <= 0 {
+		cfg.MaxLockDuration = defaultMaxLockDuration
+	}
+	if cfg.MaxLockDuration < cfg.LockDuration {
+		cfg.MaxLockDuration = cfg.LockDuration
+	}
+	if cfg.ClientLimit <= 0 {
+		cfg.ClientLimit = defaultClientLimit
+	}
+	if cfg.ClientWindow <= 0 {
+		cfg.ClientWindow = defaultClientWindow
+	}
+	if cfg.ResetAfter <= 0 {
+		cfg.ResetAfter = defaultResetAfter
+	}
+	// an account must stay locked for as long as the lock lasts
+	if cfg.ResetAfter < cfg.MaxLockDuration {
+		cfg.ResetAfter = cfg.MaxLockDuration
+	}
+	return &Guard{
+		store:   store,
+		cfg:     cfg,
+		now:     time.Now,
+		clients: map[string]*clientFailures{},
+	}
+}
+
+// Attempt starts a login attempt into username from ip. It returns a
+// *TooManyAttemptsError when the client may not log in yet and an
+// *AccountLockedError when username is locked. Otherwise the attempt counts as
+// a failed login until Succeeded or Release is called, so that logins made in
+// parallel cannot try more passwords than the threshold allows.
+func (g *Guard) Attempt(ctx context.Context, username, ip string) error {
+	now := g.now()
+	if retryAfter := g.clientRetryAfter(ip, now); retryAfter > 0 {
+		return &TooManyAttemptsError{RetryAfter: retryAfter}
+	}
+	lockedUntil, err := g.store.RecordAttempt(ctx, username, now, now.Add(-g.cfg.ResetAfter), g.cfg.Threshold, now.Add(g.cfg.LockDuration))
+	if err != nil {
+		return err
+	}
+	if lockedUntil != nil {
+		return &AccountLockedError{RetryAfter: lockedUntil.Sub(now)}
+	}
+	return nil
+}
+
+// Failed records that the attempt into username from ip was a failed login,
+// locking the account for longer with every failure past the threshold
+func (g *Guard) Failed(ctx context.Context, username, ip string) error {
+	now := g.now()
+	g.countClientFailure(ip, now)
+	account, err := g.store.GetAccount(ctx, username)
+	if err != nil {
+		return err
+	}
+	if account.Failures < g.cfg.Threshold {
+		return nil
+	}
+	return g.store.LockAccount(ctx, username, now.Add(g.lockDuration(account.Failures)))
+}
+
+// Release stops counting the attempt into username as a failed login, for
+// attempts that proved the password without completing the login or that
+// failed for another reason than wrong credentials
+func (g *Guard) Release(ctx context.Context, username string) error {
+	return g.store.ReleaseAttempt(ctx, username)
+}
+
+// Succeeded forgets the failed logins of username
+func (g *Guard) Succeeded(ctx context.Context, username string) error {
+	return g.store.ResetAccount(ctx, username)
+}
+
+// Unlock lifts the lock of username and forgets its failed logins
+func (g *Guard) Unlock(ctx context.Context, username string) error {
+	return g.store.ResetAccount(ctx, username)
+}
+
+// FailedAttempts returns how many failed logins in a row username has
+func (g *Guard) FailedAttempts(ctx context.Context, username string) (int, error) {
+	account, err := g.store.GetAccount(ctx, username)
+	if err != nil {
+		return 0, err
+	}
+	if account.Failures > 0 && account.LastFailedAt.Before(g.now().Add(-g.cfg.ResetAfter)) {
+		return 0, nil
+	}
+	return account.Failures, nil
+}
+
+// Sweep deletes the failed logins that are forgotten anyway
+func (g *Guard) Sweep(ctx context.Context) (int64, error) {
+	now := g.now()
+	g.mu.Lock()
+	g.pruneClients(now)
+	g.mu.Unlock()
+	return g.store.DeleteStaleAccounts(ctx, now.Add(-g.cfg.ResetAfter))
+}
+
+// StartSweeper calls Sweep every interval until ctx is done
+func (g *Guard) StartSweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				deleted, err := g.Sweep(ctx)
+				if err != nil {
+					log.Printf("lockout: sweeping failed logins: %v", err)
+					continue
+				}
+				if deleted > 0 {
+					log.Printf("lockout: forgot the failed logins of %d usernames", deleted)
+				}
+			}
+		}
+	}()
+}
+
+// lockDuration doubles the lock for every failure past the threshold
+func (g *Guard) lockDuration(failures int) time.Duration {
+	d := g.cfg.LockDuration
+	for i := g.cfg.Threshold; i < failures; i++ {
+		d *= 2
+		if d >= g.cfg.MaxLockDuration {
+			return g.cfg.MaxLockDuration
+		}
+	}
+	return d
+}
+
+func (g *Guard) clientRetryAfter(ip string, now time.Time) time.Duration {
+	if ip == "" {
+		return 0
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	client, ok := g.clients[ip]
+	if !ok || client.count < g.cfg.ClientLimit {
+		return 0
+	}
+	return client.since.Add(g.cfg.ClientWindow).Sub(now)
+}
+
+func (g *Guard) countClientFailure(ip string, now time.Time) {
+	if ip == "" {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	client, ok := g.clients[ip]
+	if !ok || !now.Before(client.since.Add(g.cfg.ClientWindow)) {
+		if !ok && len(g.clients) >= maxClients {
+			g.pruneClients(now)
+		}
+		g.clients[ip] = &clientFailures{count: 1, since: now}
+		return
+	}
+	client.count++
+}
+
+// pruneClients forgets the clients whose window is over, g.mu must be held
+func (g *Guard) pruneClients(now time.Time) {
+	for ip, client := range g.clients {
+		if !now.Before(client.since.Add(g.cfg.ClientWindow)) {
+			delete(g.clients, ip)
+		}
+	}
+}
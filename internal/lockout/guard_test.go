@@ -0,0 +1,122 @@
+package lockout_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pascaloseko/ems/internal/lockout"
+	"github.com/pascaloseko/ems/internal/mockdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFailedLocksWithBackoff(t *testing.T) {
+	testCases := []struct {
+		failures int
+		wantLock time.Duration
+	}{
+		{failures: 2},
+		{failures: 3, wantLock: time.Minute},
+		{failures: 4, wantLock: 2 * time.Minute},
+		{failures: 6, wantLock: 8 * time.Minute},
+		{failures: 20, wantLock: 10 * time.Minute},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.wantLock.String(), func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockLockoutStore(ctrl)
+			guard := lockout.NewGuard(store, lockout.Config{Threshold: 3, LockDuration: time.Minute, MaxLockDuration: 10 * time.Minute})
+
+			// the attempt was counted when it started
+			store.EXPECT().RecordAttempt(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			store.EXPECT().GetAccount(gomock.Any(), "pascal").Times(1).Return(lockout.Account{Username: "pascal", Failures: tc.failures}, nil)
+			if tc.wantLock == 0 {
+				store.EXPECT().LockAccount(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			} else {
+				store.EXPECT().LockAccount(gomock.Any(), "pascal", gomock.Any()).Times(1).DoAndReturn(
+					func(_ context.Context, _ string, until time.Time) error {
+						assert.WithinDuration(t, time.Now().Add(tc.wantLock), until, time.Second)
+						return nil
+					})
+			}
+			require.NoError(t, guard.Failed(context.Background(), "pascal", "10.0.0.1"))
+		})
+	}
+}
+
+func TestAttempt(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockLockoutStore(ctrl)
+	guard := lockout.NewGuard(store, lockout.Config{Threshold: 3, LockDuration: time.Minute})
+	ctx := context.Background()
+
+	// attempts are counted before the password is checked, the one reaching the
+	// threshold holds the account until it is settled
+	store.EXPECT().RecordAttempt(gomock.Any(), "pascal", gomock.Any(), gomock.Any(), 3, gomock.Any()).Times(1).DoAndReturn(
+		func(_ context.Context, _ string, at, since time.Time, _ int, holdUntil time.Time) (*time.Time, error) {
+			assert.Equal(t, 24*time.Hour, at.Sub(since))
+			assert.Equal(t, time.Minute, holdUntil.Sub(at))
+			return nil, nil
+		})
+	require.NoError(t, guard.Attempt(ctx, "pascal", "10.0.0.1"))
+
+	until := time.Now().Add(time.Minute)
+	store.EXPECT().RecordAttempt(gomock.Any(), "pascal", gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(&until, nil)
+	err := guard.Attempt(ctx, "pascal", "10.0.0.1")
+	var locked *lockout.AccountLockedError
+	require.ErrorAs(t, err, &locked)
+	assert.InDelta(t, time.Minute, locked.RetryAfter, float64(time.Second))
+
+	// an attempt that was no failed login is taken back
+	store.EXPECT().ReleaseAttempt(gomock.Any(), "pascal").Times(1).Return(nil)
+	require.NoError(t, guard.Release(ctx, "pascal"))
+
+	store.EXPECT().ResetAccount(gomock.Any(), "pascal").Times(1).Return(nil)
+	require.NoError(t, guard.Unlock(ctx, "pascal"))
+}
+
+func TestClientLimit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockLockoutStore(ctrl)
+	guard := lockout.NewGuard(store, lockout.Config{Threshold: 100, ClientLimit: 2, ClientWindow: time.Minute})
+	ctx := context.Background()
+
+	// spraying many usernames from one address is throttled too
+	store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(2).Return(lockout.Account{Failures: 1}, nil)
+	require.NoError(t, guard.Failed(ctx, "alice", "10.0.0.1"))
+	require.NoError(t, guard.Failed(ctx, "bob", "10.0.0.1"))
+
+	err := guard.Attempt(ctx, "carol", "10.0.0.1")
+	var throttled *lockout.TooManyAttemptsError
+	require.ErrorAs(t, err, &throttled)
+	assert.InDelta(t, time.Minute, throttled.RetryAfter, float64(time.Second))
+
+	// other clients are not affected
+	store.EXPECT().RecordAttempt(gomock.Any(), "carol", gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(nil, nil)
+	require.NoError(t, guard.Attempt(ctx, "carol", "10.0.0.2"))
+}
+
+func TestFailedAttempts(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockLockoutStore(ctrl)
+	guard := lockout.NewGuard(store, lockout.Config{})
+	ctx := context.Background()
+
+	store.EXPECT().GetAccount(gomock.Any(), "pascal").Times(1).Return(lockout.Account{Username: "pascal", Failures: 3, LastFailedAt: time.Now()}, nil)
+	attempts, err := guard.FailedAttempts(ctx, "pascal")
+	require.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+
+	// failures are forgotten after a day without any
+	store.EXPECT().GetAccount(gomock.Any(), "pascal").Times(1).Return(lockout.Account{Username: "pascal", Failures: 3, LastFailedAt: time.Now().Add(-25 * time.Hour)}, nil)
+	attempts, err = guard.FailedAttempts(ctx, "pascal")
+	require.NoError(t, err)
+	assert.Zero(t, attempts)
+}
@@ -0,0 +1,154 @@
+package lockout
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Account is the failed login record of a username. Usernames that no employee
+// has are tracked too, so that locking does not reveal which accounts exist.
+type Account struct {
+	Username     string
+	Failures     int
+	LastFailedAt time.Time
+	LockedUntil  *time.Time
+}
+
+type Store interface {
+	// GetAccount returns an Account without failures for usernames that never failed
+	GetAccount(ctx context.Context, username string) (Account, error)
+	// RecordAttempt counts a login attempt at at as a failure, forgetting
+	// failures made before since. The attempt reaching threshold locks the
+	// username until holdUntil, in the same statement, so that concurrent
+	// attempts cannot get past it. Attempts on a username locked at at are not
+	// counted, the time the lock ends is returned instead.
+	RecordAttempt(ctx context.Context, username string, at, since time.Time, threshold int, holdUntil time.Time) (*time.Time, error)
+	// ReleaseAttempt takes back an attempt counted by RecordAttempt, along with
+	// the lock it may have set
+	ReleaseAttempt(ctx context.Context, username string) error
+	LockAccount(ctx context.Context, username string, until time.Time) error
+	ResetAccount(ctx context.Context, username string) error
+	DeleteStaleAccounts(ctx context.Context, before time.Time) (int64, error)
+}
+
+type LoginFailureStore struct {
+	store *sql.DB
+}
+
+func NewLoginFailureStore(db *sql.DB) Store {
+	return &LoginFailureStore{
+		store: db,
+	}
+}
+
+// GetAccount implements Store.
+func (s *LoginFailureStore) GetAccount(ctx context.Context, username string) (Account, error) {
+	account := Account{Username: username}
+	row := s.store.QueryRowContext(ctx, "SELECT Failures, Last_Failed_At, Locked_Until FROM Login_Failures WHERE Username = @Username", sql.Named("Username", username))
+	err := row.Scan(&account.Failures, &account.LastFailedAt, &account.LockedUntil)
+	if errors.Is(err, sql.ErrNoRows) {
+		return account, nil
+	}
+	return account, err
+}
+
+// RecordAttempt implements Store.
+func (s *LoginFailureStore) RecordAttempt(ctx context.Context, username string, at, since time.Time, threshold int, holdUntil time.Time) (*time.Time, error) {
+	counted, err := s.countAttempt(ctx, username, at, since, threshold, holdUntil)
+	if err != nil || counted {
+		return nil, err
+	}
+	// no row was updated, the username either has none yet or is locked
+	var lockedUntil *time.Time
+	if threshold <= 1 {
+		lockedUntil = &holdUntil
+	}
+	_, insertErr := s.store.ExecContext(
+		ctx,
+		"INSERT INTO Login_Failures (Username, Failures, Last_Failed_At, Locked_Until) VALUES (@Username, 1, @At, @Locked_Until)",
+		sql.Named("Username", username),
+		sql.Named("At", at),
+		sql.Named("Locked_Until", lockedUntil))
+	if insertErr == nil {
+		return nil, nil
+	}
+	// the row exists after all, a concurrent attempt inserted it first
+	counted, err = s.countAttempt(ctx, username, at, since, threshold, holdUntil)
+	if err != nil || counted {
+		return nil, err
+	}
+	account, err := s.GetAccount(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	if account.LockedUntil == nil || !at.Before(*account.LockedUntil) {
+		return nil, insertErr
+	}
+	return account.LockedUntil, nil
+}
+
+// countAttempt counts an attempt into the row of username unless it is locked,
+// reporting whether it did
+func (s *LoginFailureStore) countAttempt(ctx context.Context, username string, at, since time.Time, threshold int, holdUntil time.Time) (bool, error) {
+	tsql := `
+	UPDATE Login_Failures
+	SET Failures = CASE WHEN Last_Failed_At < @Since THEN 1 ELSE Failures + 1 END,
+		Locked_Until = CASE WHEN (CASE WHEN Last_Failed_At < @Since THEN 1 ELSE Failures + 1 END) >= @Threshold THEN @Hold_Until ELSE Locked_Until END,
+		Last_Failed_At = @At
+	WHERE Username = @Username AND (Locked_Until IS NULL OR Locked_Until <= @At)
+	`
+	result, err := s.store.ExecContext(
+		ctx,
+		tsql,
+		sql.Named("Since", since),
+		sql.Named("Threshold", threshold),
+		sql.Named("Hold_Until", holdUntil),
+		sql.Named("At", at),
+		sql.Named("Username", username))
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// ReleaseAttempt implements Store.
+func (s *LoginFailureStore) ReleaseAttempt(ctx context.Context, username string) error {
+	tsql := `
+	UPDATE Login_Failures
+	SET Failures = Failures - 1, Locked_Until = NULL
+	WHERE Username = @Username AND Failures > 0
+	`
+	_, err := s.store.ExecContext(ctx, tsql, sql.Named("Username", username))
+	return err
+}
+
+// LockAccount implements Store.
+func (s *LoginFailureStore) LockAccount(ctx context.Context, username string, until time.Time) error {
+	_, err := s.store.ExecContext(ctx, "UPDATE Login_Failures SET Locked_Until = @Locked_Until WHERE Username = @Username", sql.Named("Locked_Until", until), sql.Named("Username", username))
+	return err
+}
+
+// ResetAccount implements Store.
+func (s *LoginFailureStore) ResetAccount(ctx context.Context, username string) error {
+	_, err := s.store.ExecContext(ctx, "DELETE FROM Login_Failures WHERE Username = @Username", sql.Named("Username", username))
+	return err
+}
+
+// DeleteStaleAccounts implements Store.
+func (s *LoginFailureStore) DeleteStaleAccounts(ctx context.Context, before time.Time) (int64, error) {
+	tsql := `
+	DELETE FROM Login_Failures
+	WHERE Last_Failed_At < @Before AND (Locked_Until IS NULL OR Locked_Until < @Before)
+	`
+	result, err := s.store.ExecContext(ctx, tsql, sql.Named("Before", before))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,93 @@
+package lockout_test
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pascaloseko/ems/internal/lockout"
+	"github.com/pascaloseko/ems/internal/pkg/db/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSQLiteStore(t *testing.T) lockout.Store {
+	db, dialect, err := database.Open("sqlite:" + filepath.Join(t.TempDir(), "ems.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	migrator, err := database.NewMigrator(db, dialect)
+	require.NoError(t, err)
+	_, err = migrator.Up(context.Background())
+	require.NoError(t, err)
+	return lockout.NewLoginFailureStore(db)
+}
+
+func TestRecordAttemptConcurrently(t *testing.T) {
+	store := newSQLiteStore(t)
+	ctx := context.Background()
+	now := time.Now()
+	since, holdUntil := now.Add(-24*time.Hour), now.Add(time.Minute)
+
+	// parallel logins cannot try more passwords than the threshold, even the
+	// first ones racing to insert the row
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		admitted int
+	)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lockedUntil, err := store.RecordAttempt(ctx, "pascal", now, since, 3, holdUntil)
+			assert.NoError(t, err)
+			if err == nil && lockedUntil == nil {
+				mu.Lock()
+				admitted++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 3, admitted)
+
+	account, err := store.GetAccount(ctx, "pascal")
+	require.NoError(t, err)
+	assert.Equal(t, 3, account.Failures)
+	require.NotNil(t, account.LockedUntil)
+	assert.WithinDuration(t, holdUntil, *account.LockedUntil, time.Second)
+}
+
+func TestReleaseAttempt(t *testing.T) {
+	store := newSQLiteStore(t)
+	ctx := context.Background()
+	now := time.Now()
+	since, holdUntil := now.Add(-24*time.Hour), now.Add(time.Minute)
+
+	for i := 0; i < 3; i++ {
+		lockedUntil, err := store.RecordAttempt(ctx, "pascal", now, since, 3, holdUntil)
+		require.NoError(t, err)
+		require.Nil(t, lockedUntil)
+	}
+	lockedUntil, err := store.RecordAttempt(ctx, "pascal", now, since, 3, holdUntil)
+	require.NoError(t, err)
+	require.NotNil(t, lockedUntil)
+
+	// the attempt that reached the threshold proved the password, its hold goes too
+	require.NoError(t, store.ReleaseAttempt(ctx, "pascal"))
+	account, err := store.GetAccount(ctx, "pascal")
+	require.NoError(t, err)
+	assert.Equal(t, 2, account.Failures)
+	assert.Nil(t, account.LockedUntil)
+
+	// failures older than since are forgotten
+	later := now.Add(25 * time.Hour)
+	lockedUntil, err = store.RecordAttempt(ctx, "pascal", later, later.Add(-24*time.Hour), 3, later.Add(time.Minute))
+	require.NoError(t, err)
+	assert.Nil(t, lockedUntil)
+	account, err = store.GetAccount(ctx, "pascal")
+	require.NoError(t, err)
+	assert.Equal(t, 1, account.Failures)
+}
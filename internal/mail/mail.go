@@ -0,0 +1,127 @@
+// Package mail sends the emails of the service, such as password reset links.
+package mail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/smtp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message is a plain text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers messages
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// SMTPConfig describes the relay an SMTPMailer delivers through
+type SMTPConfig struct {
+	Host string
+	Port string
+	// Username and Password authenticate with PLAIN auth when Username is set,
+	// which net/smtp only allows over TLS or to localhost
+	Username string
+	Password string
+	From     string
+}
+
+// SMTPMailer delivers messages through an SMTP relay
+type SMTPMailer struct {
+	cfg SMTPConfig
+	now func() time.Time
+}
+
+func NewSMTPMailer(cfg SMTPConfig) *SMTPMailer {
+	if cfg.Port == "" {
+		cfg.Port = "587"
+	}
+	return &SMTPMailer{cfg: cfg, now: time.Now}
+}
+
+// Send implements Mailer.
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	raw, err := format(m.cfg.From, msg, m.now())
+	if err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	if m.cfg.Username != "" {
+		auth = smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, m.cfg.Host)
+	}
+
+	// smtp.SendMail cannot be cancelled, so give up waiting when ctx is done
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(net.JoinHostPort(m.cfg.Host, m.cfg.Port), auth, m.cfg.From, []string{msg.To}, raw)
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("mail: sending to %s: %w", msg.To, err)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// LogMailer writes messages to w instead of delivering them, for local
+// development and tests
+type LogMailer struct {
+	from string
+	now  func() time.Time
+
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewLogMailer(w io.Writer, from string) *LogMailer {
+	return &LogMailer{w: w, from: from, now: time.Now}
+}
+
+// Send implements Mailer.
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	raw, err := format(m.from, msg, m.now())
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, err = fmt.Fprintf(m.w, "%s\r\n\r\n", raw)
+	return err
+}
+
+// format renders msg as an RFC 5322 message
+func format(from string, msg Message, now time.Time) ([]byte, error) {
+	for _, header := range []string{from, msg.To, msg.Subject} {
+		// anything else could smuggle in extra headers or recipients
+		if strings.ContainsAny(header, "\r\n") {
+			return nil, errors.New("mail: headers cannot contain line breaks")
+		}
+	}
+	if msg.To == "" {
+		return nil, errors.New("mail: message has no recipient")
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n"))
+	return []byte(b.String()), nil
+}
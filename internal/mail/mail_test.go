@@ -0,0 +1,101 @@
+package mail
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogMailer(t *testing.T) {
+	var out bytes.Buffer
+	mailer := NewLogMailer(&out, "ems@example.com")
+	err := mailer.Send(context.Background(), Message{To: "jane@example.com", Subject: "Hello", Body: "line one\nline two"})
+	require.NoError(t, err)
+
+	assert.Contains(t, out.String(), "From: ems@example.com\r\n")
+	assert.Contains(t, out.String(), "To: jane@example.com\r\n")
+	assert.Contains(t, out.String(), "Subject: Hello\r\n")
+	assert.Contains(t, out.String(), "\r\n\r\nline one\r\nline two")
+}
+
+func TestHeaderInjection(t *testing.T) {
+	mailer := NewLogMailer(&bytes.Buffer{}, "ems@example.com")
+	for _, msg := range []Message{
+		{To: "jane@example.com\r\nBcc: mallory@example.com", Subject: "Hello"},
+		{To: "jane@example.com", Subject: "Hello\nBcc: mallory@example.com"},
+		{To: "", Subject: "Hello"},
+	} {
+		assert.Error(t, mailer.Send(context.Background(), msg))
+	}
+}
+
+// fakeSMTP accepts a single message and returns the envelope recipient and data
+func fakeSMTP(t *testing.T) (addr string, received chan [2]string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+	received = make(chan [2]string, 1)
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		text := textproto.NewConn(conn)
+		text.PrintfLine("220 localhost ready")
+		var rcpt string
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				return
+			}
+			switch cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); cmd {
+			case "EHLO", "HELO":
+				text.PrintfLine("250 localhost")
+			case "RCPT":
+				rcpt = line
+				text.PrintfLine("250 OK")
+			case "DATA":
+				text.PrintfLine("354 go ahead")
+				data, err := text.ReadDotBytes()
+				if err != nil {
+					return
+				}
+				received <- [2]string{rcpt, string(data)}
+				text.PrintfLine("250 OK")
+			case "QUIT":
+				text.PrintfLine("221 bye")
+				return
+			default:
+				text.PrintfLine("250 OK")
+			}
+		}
+	}()
+	return ln.Addr().String(), received
+}
+
+func TestSMTPMailer(t *testing.T) {
+	addr, received := fakeSMTP(t)
+	host, port, err := net.SplitHostPort(addr)
+	require.NoError(t, err)
+
+	mailer := NewSMTPMailer(SMTPConfig{Host: host, Port: port, From: "ems@example.com"})
+	err = mailer.Send(context.Background(), Message{To: "jane@example.com", Subject: "Hello", Body: "hi\n.\nthere"})
+	require.NoError(t, err)
+
+	got := <-received
+	assert.Equal(t, "RCPT TO:<jane@example.com>", got[0])
+	msg, err := textproto.NewReader(bufio.NewReader(strings.NewReader(got[1]))).ReadMIMEHeader()
+	require.NoError(t, err)
+	assert.Equal(t, "Hello", msg.Get("Subject"))
+	// a lone dot in the body does not end the message early
+	assert.Contains(t, got[1], "hi\n.\nthere")
+}
@@ -36,10 +36,10 @@ func (m *MockStore) EXPECT() *MockStoreMockRecorder {
 }
 
 // Authenticate mocks base method.
-func (m *MockStore) Authenticate(arg0 context.Context, arg1 employees.Employee) bool {
+func (m *MockStore) Authenticate(arg0 context.Context, arg1 employees.Employee) error {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Authenticate", arg0, arg1)
-	ret0, _ := ret[0].(bool)
+	ret0, _ := ret[0].(error)
 	return ret0
 }
 
@@ -49,19 +49,63 @@ func (mr *MockStoreMockRecorder) Authenticate(arg0, arg1 interface{}) *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Authenticate", reflect.TypeOf((*MockStore)(nil).Authenticate), arg0, arg1)
 }
 
-// GetAllEmployees mocks base method.
-func (m *MockStore) GetAllEmployees(arg0 context.Context) ([]employees.Employee, error) {
+// Delete mocks base method.
+func (m *MockStore) Delete(arg0 context.Context, arg1 int64) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAllEmployees", arg0)
+	ret := m.ctrl.Call(m, "Delete", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockStoreMockRecorder) Delete(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockStore)(nil).Delete), arg0, arg1)
+}
+
+// DeleteDepartment mocks base method.
+func (m *MockStore) DeleteDepartment(arg0 context.Context, arg1 int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteDepartment", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteDepartment indicates an expected call of DeleteDepartment.
+func (mr *MockStoreMockRecorder) DeleteDepartment(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteDepartment", reflect.TypeOf((*MockStore)(nil).DeleteDepartment), arg0, arg1)
+}
+
+// FindEmployees mocks base method.
+func (m *MockStore) FindEmployees(arg0 context.Context, arg1 employees.EmployeeQuery) ([]employees.Employee, int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindEmployees", arg0, arg1)
 	ret0, _ := ret[0].([]employees.Employee)
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// FindEmployees indicates an expected call of FindEmployees.
+func (mr *MockStoreMockRecorder) FindEmployees(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindEmployees", reflect.TypeOf((*MockStore)(nil).FindEmployees), arg0, arg1)
+}
+
+// GetDepartmentByID mocks base method.
+func (m *MockStore) GetDepartmentByID(arg0 context.Context, arg1 int64) (employees.Department, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDepartmentByID", arg0, arg1)
+	ret0, _ := ret[0].(employees.Department)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetAllEmployees indicates an expected call of GetAllEmployees.
-func (mr *MockStoreMockRecorder) GetAllEmployees(arg0 interface{}) *gomock.Call {
+// GetDepartmentByID indicates an expected call of GetDepartmentByID.
+func (mr *MockStoreMockRecorder) GetDepartmentByID(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllEmployees", reflect.TypeOf((*MockStore)(nil).GetAllEmployees), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDepartmentByID", reflect.TypeOf((*MockStore)(nil).GetDepartmentByID), arg0, arg1)
 }
 
 // GetDepartmentIdByName mocks base method.
@@ -79,19 +123,79 @@ func (mr *MockStoreMockRecorder) GetDepartmentIdByName(arg0, arg1 interface{}) *
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDepartmentIdByName", reflect.TypeOf((*MockStore)(nil).GetDepartmentIdByName), arg0, arg1)
 }
 
-// GetDepartmentNameById mocks base method.
-func (m *MockStore) GetDepartmentNameById(arg0 context.Context, arg1 int64) (string, error) {
+// GetDepartments mocks base method.
+func (m *MockStore) GetDepartments(arg0 context.Context) ([]employees.Department, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetDepartmentNameById", arg0, arg1)
-	ret0, _ := ret[0].(string)
+	ret := m.ctrl.Call(m, "GetDepartments", arg0)
+	ret0, _ := ret[0].([]employees.Department)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDepartments indicates an expected call of GetDepartments.
+func (mr *MockStoreMockRecorder) GetDepartments(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDepartments", reflect.TypeOf((*MockStore)(nil).GetDepartments), arg0)
+}
+
+// GetDepartmentsByIDs mocks base method.
+func (m *MockStore) GetDepartmentsByIDs(arg0 context.Context, arg1 []int64) ([]employees.Department, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDepartmentsByIDs", arg0, arg1)
+	ret0, _ := ret[0].([]employees.Department)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDepartmentsByIDs indicates an expected call of GetDepartmentsByIDs.
+func (mr *MockStoreMockRecorder) GetDepartmentsByIDs(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDepartmentsByIDs", reflect.TypeOf((*MockStore)(nil).GetDepartmentsByIDs), arg0, arg1)
+}
+
+// GetEmployeeByEmail mocks base method.
+func (m *MockStore) GetEmployeeByEmail(arg0 context.Context, arg1 string) (employees.Employee, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetEmployeeByEmail", arg0, arg1)
+	ret0, _ := ret[0].(employees.Employee)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetEmployeeByEmail indicates an expected call of GetEmployeeByEmail.
+func (mr *MockStoreMockRecorder) GetEmployeeByEmail(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEmployeeByEmail", reflect.TypeOf((*MockStore)(nil).GetEmployeeByEmail), arg0, arg1)
+}
+
+// GetEmployeeByID mocks base method.
+func (m *MockStore) GetEmployeeByID(arg0 context.Context, arg1 int64) (employees.Employee, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetEmployeeByID", arg0, arg1)
+	ret0, _ := ret[0].(employees.Employee)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetDepartmentNameById indicates an expected call of GetDepartmentNameById.
-func (mr *MockStoreMockRecorder) GetDepartmentNameById(arg0, arg1 interface{}) *gomock.Call {
+// GetEmployeeByID indicates an expected call of GetEmployeeByID.
+func (mr *MockStoreMockRecorder) GetEmployeeByID(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDepartmentNameById", reflect.TypeOf((*MockStore)(nil).GetDepartmentNameById), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEmployeeByID", reflect.TypeOf((*MockStore)(nil).GetEmployeeByID), arg0, arg1)
+}
+
+// GetEmployeeByUsername mocks base method.
+func (m *MockStore) GetEmployeeByUsername(arg0 context.Context, arg1 string) (employees.Employee, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetEmployeeByUsername", arg0, arg1)
+	ret0, _ := ret[0].(employees.Employee)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetEmployeeByUsername indicates an expected call of GetEmployeeByUsername.
+func (mr *MockStoreMockRecorder) GetEmployeeByUsername(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEmployeeByUsername", reflect.TypeOf((*MockStore)(nil).GetEmployeeByUsername), arg0, arg1)
 }
 
 // GetEmployeeIdByUsername mocks base method.
@@ -109,18 +213,49 @@ func (mr *MockStoreMockRecorder) GetEmployeeIdByUsername(arg0, arg1 interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEmployeeIdByUsername", reflect.TypeOf((*MockStore)(nil).GetEmployeeIdByUsername), arg0, arg1)
 }
 
+// GetEmployeesByIDs mocks base method.
+func (m *MockStore) GetEmployeesByIDs(arg0 context.Context, arg1 []int64) ([]employees.Employee, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetEmployeesByIDs", arg0, arg1)
+	ret0, _ := ret[0].([]employees.Employee)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetEmployeesByIDs indicates an expected call of GetEmployeesByIDs.
+func (mr *MockStoreMockRecorder) GetEmployeesByIDs(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEmployeesByIDs", reflect.TypeOf((*MockStore)(nil).GetEmployeesByIDs), arg0, arg1)
+}
+
 // HashPassword mocks base method.
-func (m *MockStore) HashPassword(arg0 string) string {
+func (m *MockStore) HashPassword(arg0 context.Context, arg1 string) (string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "HashPassword", arg0)
+	ret := m.ctrl.Call(m, "HashPassword", arg0, arg1)
 	ret0, _ := ret[0].(string)
-	return ret0
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // HashPassword indicates an expected call of HashPassword.
-func (mr *MockStoreMockRecorder) HashPassword(arg0 interface{}) *gomock.Call {
+func (mr *MockStoreMockRecorder) HashPassword(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HashPassword", reflect.TypeOf((*MockStore)(nil).HashPassword), arg0, arg1)
+}
+
+// RenameDepartment mocks base method.
+func (m *MockStore) RenameDepartment(arg0 context.Context, arg1 int64, arg2 string) (employees.Department, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RenameDepartment", arg0, arg1, arg2)
+	ret0, _ := ret[0].(employees.Department)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RenameDepartment indicates an expected call of RenameDepartment.
+func (mr *MockStoreMockRecorder) RenameDepartment(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HashPassword", reflect.TypeOf((*MockStore)(nil).HashPassword), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RenameDepartment", reflect.TypeOf((*MockStore)(nil).RenameDepartment), arg0, arg1, arg2)
 }
 
 // Save mocks base method.
@@ -152,3 +287,32 @@ func (mr *MockStoreMockRecorder) SaveDepartment(arg0, arg1 interface{}) *gomock.
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveDepartment", reflect.TypeOf((*MockStore)(nil).SaveDepartment), arg0, arg1)
 }
+
+// Update mocks base method.
+func (m *MockStore) Update(arg0 context.Context, arg1 int64, arg2 employees.EmployeeUpdate) (employees.Employee, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Update", arg0, arg1, arg2)
+	ret0, _ := ret[0].(employees.Employee)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Update indicates an expected call of Update.
+func (mr *MockStoreMockRecorder) Update(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockStore)(nil).Update), arg0, arg1, arg2)
+}
+
+// WithTx mocks base method.
+func (m *MockStore) WithTx(arg0 context.Context, arg1 func(employees.Store) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WithTx", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WithTx indicates an expected call of WithTx.
+func (mr *MockStoreMockRecorder) WithTx(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WithTx", reflect.TypeOf((*MockStore)(nil).WithTx), arg0, arg1)
+}
@@ -0,0 +1,249 @@
+// Package oidc logs employees in through an OpenID Connect identity provider
+// with the authorization code flow.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// clockSkew is the leeway when checking the times of ID tokens
+	clockSkew = time.Minute
+	// maxResponseSize caps what is read from the provider
+	maxResponseSize = 1 << 20
+)
+
+var defaultScopes = []string{"openid", "email", "profile"}
+
+var (
+	// ErrInvalidIDToken is returned for every ID token that fails to verify
+	ErrInvalidIDToken = errors.New("invalid ID token")
+	// ErrNotConfigured is returned by NewProvider when no issuer is configured
+	ErrNotConfigured = errors.New("oidc: no issuer configured")
+)
+
+// Config describes the identity provider and how EMS is registered with it
+type Config struct {
+	// IssuerURL is where the discovery document is found, under
+	// /.well-known/openid-configuration
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the /auth/oidc/callback URL of EMS registered with the provider
+	RedirectURL string
+	// Scopes requested, openid is always included. Without email the provider
+	// cannot say which employee logged in.
+	Scopes []string
+}
+
+// AuthRequest is the state of one login between the redirect to the provider
+// and its callback
+type AuthRequest struct {
+	State string
+	Nonce string
+	// Verifier is the PKCE code verifier, only its hash is sent to the provider
+	Verifier string
+}
+
+// Identity is the user an ID token was issued to, Email is always verified
+// by the provider
+type Identity struct {
+	Subject string
+	Email   string
+}
+
+// Provider is the relying party side of an OpenID Connect provider. The
+// discovery document is fetched on first use and the signing keys whenever a
+// token is signed with an unknown one.
+type Provider struct {
+	cfg    Config
+	client *http.Client
+	now    func() time.Time
+
+	mu        sync.Mutex
+	discovery *discovery
+
+	keysMu sync.Mutex
+	keys   *keySet
+}
+
+type discovery struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	JWKSURI               string   `json:"jwks_uri"`
+	SigningAlgorithms     []string `json:"id_token_signing_alg_values_supported"`
+}
+
+// NewProvider returns a Provider for cfg, using client for every request to
+// the provider, or a client with a 10s timeout when client is nil
+func NewProvider(cfg Config, client *http.Client) (*Provider, error) {
+	if cfg.IssuerURL == "" {
+		return nil, ErrNotConfigured
+	}
+	if cfg.ClientID == "" || cfg.RedirectURL == "" {
+		return nil, errors.New("oidc: a client ID and redirect URL are required")
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = defaultScopes
+	}
+	if !contains(cfg.Scopes, "openid") {
+		cfg.Scopes = append([]string{"openid"}, cfg.Scopes...)
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Provider{
+		cfg:    cfg,
+		client: client,
+		now:    time.Now,
+	}, nil
+}
+
+// NewAuthRequest starts a login with a random state, nonce and PKCE verifier
+func NewAuthRequest() (AuthRequest, error) {
+	var req AuthRequest
+	for _, value := range []*string{&req.State, &req.Nonce, &req.Verifier} {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return AuthRequest{}, err
+		}
+		*value = base64.RawURLEncoding.EncodeToString(b)
+	}
+	return req, nil
+}
+
+// AuthCodeURL is where the user is sent to log in at the provider
+func (p *Provider) AuthCodeURL(ctx context.Context, req AuthRequest) (string, error) {
+	d, err := p.getDiscovery(ctx)
+	if err != nil {
+		return "", err
+	}
+	challenge := sha256.Sum256([]byte(req.Verifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.cfg.ClientID},
+		"redirect_uri":          {p.cfg.RedirectURL},
+		"scope":                 {strings.Join(p.cfg.Scopes, " ")},
+		"state":                 {req.State},
+		"nonce":                 {req.Nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	separator := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return d.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Callback exchanges the authorization code the provider redirected back with
+// for an ID token and returns the identity it proves
+func (p *Provider) Callback(ctx context.Context, req AuthRequest, code string) (Identity, error) {
+	d, err := p.getDiscovery(ctx)
+	if err != nil {
+		return Identity{}, err
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"code_verifier": {req.Verifier},
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Identity{}, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+	request.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+
+	var token struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	status, err := p.do(request, &token)
+	if err != nil {
+		return Identity{}, err
+	}
+	if status != http.StatusOK || token.Error != "" {
+		return Identity{}, fmt.Errorf("oidc: exchanging code failed with %d: %s %s", status, token.Error, token.ErrorDescription)
+	}
+	if token.IDToken == "" {
+		return Identity{}, fmt.Errorf("%w: token response has no id_token", ErrInvalidIDToken)
+	}
+	return p.Verify(ctx, token.IDToken, req.Nonce)
+}
+
+// getDiscovery fetches the discovery document once, failures are retried on
+// the next call
+func (p *Provider) getDiscovery(ctx context.Context) (*discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	wellKnown := strings.TrimSuffix(p.cfg.IssuerURL, "/") + "/.well-known/openid-configuration"
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, wellKnown, nil)
+	if err != nil {
+		return nil, err
+	}
+	var d discovery
+	status, err := p.do(request, &d)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("oidc: fetching %s failed with %d", wellKnown, status)
+	}
+	// the issuer must be exactly the one configured, so that another provider
+	// cannot serve tokens in its name
+	if d.Issuer != p.cfg.IssuerURL {
+		return nil, fmt.Errorf("oidc: discovery document is for issuer %q, expected %q", d.Issuer, p.cfg.IssuerURL)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return nil, errors.New("oidc: discovery document is missing endpoints")
+	}
+	p.discovery = &d
+	return p.discovery, nil
+}
+
+// do sends request and decodes the JSON response into v, whatever its status
+func (p *Provider) do(request *http.Request, v interface{}) (int, error) {
+	response, err := p.client.Do(request)
+	if err != nil {
+		return 0, fmt.Errorf("oidc: %w", err)
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(response.Body, maxResponseSize))
+	if err != nil {
+		return 0, fmt.Errorf("oidc: %w", err)
+	}
+	if err := json.Unmarshal(body, v); err != nil && response.StatusCode == http.StatusOK {
+		return 0, fmt.Errorf("oidc: decoding response of %s: %w", request.URL, err)
+	}
+	return response.StatusCode, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
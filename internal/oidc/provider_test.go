@@ -0,0 +1,154 @@
+package oidc_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pascaloseko/ems/internal/oidc"
+	"github.com/pascaloseko/ems/internal/oidc/oidctest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const redirectURL = "http://ems.test/auth/oidc/callback"
+
+func newTestProvider(t *testing.T, idp *oidctest.Server) *oidc.Provider {
+	provider, err := oidc.NewProvider(oidc.Config{
+		IssuerURL:    idp.URL,
+		ClientID:     idp.ClientID,
+		ClientSecret: idp.ClientSecret,
+		RedirectURL:  redirectURL,
+	}, nil)
+	require.NoError(t, err)
+	return provider
+}
+
+// authorize follows the redirect to the provider and returns the code and state
+// it redirects back with
+func authorize(t *testing.T, provider *oidc.Provider, req oidc.AuthRequest) (string, string) {
+	authURL, err := provider.AuthCodeURL(context.Background(), req)
+	require.NoError(t, err)
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	response, err := client.Get(authURL)
+	require.NoError(t, err)
+	defer response.Body.Close()
+	require.Equal(t, http.StatusFound, response.StatusCode)
+	location, err := url.Parse(response.Header.Get("Location"))
+	require.NoError(t, err)
+	require.Equal(t, redirectURL, location.Scheme+"://"+location.Host+location.Path)
+	return location.Query().Get("code"), location.Query().Get("state")
+}
+
+func TestLogin(t *testing.T) {
+	idp := oidctest.NewServer("ems", "client-secret")
+	defer idp.Close()
+	idp.SetUser(map[string]interface{}{"sub": "00u1", "email": "pascal@example.com", "email_verified": true})
+	provider := newTestProvider(t, idp)
+	ctx := context.Background()
+
+	req, err := oidc.NewAuthRequest()
+	require.NoError(t, err)
+	code, state := authorize(t, provider, req)
+	require.Equal(t, req.State, state)
+
+	identity, err := provider.Callback(ctx, req, code)
+	require.NoError(t, err)
+	assert.Equal(t, oidc.Identity{Subject: "00u1", Email: "pascal@example.com"}, identity)
+
+	// codes are single use
+	_, err = provider.Callback(ctx, req, code)
+	assert.Error(t, err)
+
+	// the code only works together with the verifier of the same login
+	code, _ = authorize(t, provider, req)
+	other, err := oidc.NewAuthRequest()
+	require.NoError(t, err)
+	_, err = provider.Callback(ctx, oidc.AuthRequest{State: req.State, Nonce: req.Nonce, Verifier: other.Verifier}, code)
+	assert.Error(t, err)
+}
+
+func TestVerify(t *testing.T) {
+	idp := oidctest.NewServer("ems", "client-secret")
+	defer idp.Close()
+	otherIdP := oidctest.NewServer("ems", "client-secret")
+	defer otherIdP.Close()
+
+	now := time.Now()
+	claims := func(overrides jwt.MapClaims) jwt.MapClaims {
+		c := jwt.MapClaims{
+			"iss":                idp.URL,
+			"aud":                "ems",
+			"sub":                "00u1",
+			"iat":                now.Unix(),
+			"exp":                now.Add(time.Hour).Unix(),
+			"nonce":              "n-1",
+			"preferred_username": "pascal",
+			"email":              "pascal@example.com",
+			"email_verified":     true,
+		}
+		for name, value := range overrides {
+			if value == nil {
+				delete(c, name)
+				continue
+			}
+			c[name] = value
+		}
+		return c
+	}
+
+	testCases := []struct {
+		name      string
+		token     string
+		wantEmail string
+	}{
+		{name: "Valid", token: idp.Sign(claims(nil)), wantEmail: "pascal@example.com"},
+		{name: "Audiences", token: idp.Sign(claims(jwt.MapClaims{"aud": []string{"ems", "other"}, "azp": "ems"})), wantEmail: "pascal@example.com"},
+		{name: "AudiencesWithoutAzp", token: idp.Sign(claims(jwt.MapClaims{"aud": []string{"ems", "other"}}))},
+		{name: "OtherAudience", token: idp.Sign(claims(jwt.MapClaims{"aud": "other"}))},
+		{name: "OtherIssuer", token: idp.Sign(claims(jwt.MapClaims{"iss": otherIdP.URL}))},
+		{name: "SignedByOtherProvider", token: otherIdP.Sign(claims(nil))},
+		{name: "Expired", token: idp.Sign(claims(jwt.MapClaims{"exp": now.Add(-time.Hour).Unix()}))},
+		{name: "NoExpiry", token: idp.Sign(claims(jwt.MapClaims{"exp": nil}))},
+		{name: "IssuedInFuture", token: idp.Sign(claims(jwt.MapClaims{"iat": now.Add(time.Hour).Unix()}))},
+		{name: "WrongNonce", token: idp.Sign(claims(jwt.MapClaims{"nonce": "n-2"}))},
+		{name: "NoEmail", token: idp.Sign(claims(jwt.MapClaims{"email": nil}))},
+		{name: "UnverifiedEmail", token: idp.Sign(claims(jwt.MapClaims{"email_verified": false}))},
+		{name: "EmailVerifiedMissing", token: idp.Sign(claims(jwt.MapClaims{"email_verified": nil}))},
+		{name: "EmailVerifiedString", token: idp.Sign(claims(jwt.MapClaims{"email_verified": "true"}))},
+		{name: "Unsigned", token: func() string {
+			token, err := jwt.NewWithClaims(jwt.SigningMethodNone, claims(nil)).SignedString(jwt.UnsafeAllowNoneSignatureType)
+			require.NoError(t, err)
+			return token
+		}()},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			provider := newTestProvider(t, idp)
+			identity, err := provider.Verify(context.Background(), tc.token, "n-1")
+			if tc.wantEmail == "" {
+				assert.ErrorIs(t, err, oidc.ErrInvalidIDToken)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantEmail, identity.Email)
+		})
+	}
+}
+
+func TestDiscoveryIssuerMismatch(t *testing.T) {
+	idp := oidctest.NewServer("ems", "client-secret")
+	defer idp.Close()
+
+	provider, err := oidc.NewProvider(oidc.Config{IssuerURL: idp.URL + "/", ClientID: "ems", RedirectURL: redirectURL}, nil)
+	require.NoError(t, err)
+	req, err := oidc.NewAuthRequest()
+	require.NoError(t, err)
+	_, err = provider.AuthCodeURL(context.Background(), req)
+	assert.ErrorContains(t, err, "expected")
+}
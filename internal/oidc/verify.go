@@ -0,0 +1,280 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// keyRefreshInterval limits how often the signing keys are fetched again for
+// tokens signed with an unknown key
+const keyRefreshInterval = time.Minute
+
+// supportedAlgorithms are the ID token signing algorithms that are accepted
+// when the provider advertises them, RS256 is mandatory for every provider
+var supportedAlgorithms = []string{"RS256", "ES256"}
+
+type keySet struct {
+	keys      map[string]interface{}
+	fetchedAt time.Time
+}
+
+type jsonWebKey struct {
+	KeyType string `json:"kty"`
+	Use     string `json:"use"`
+	KeyID   string `json:"kid"`
+	N       string `json:"n"`
+	E       string `json:"e"`
+	Curve   string `json:"crv"`
+	X       string `json:"x"`
+	Y       string `json:"y"`
+}
+
+// Verify checks the signature and claims of an ID token issued for this
+// client with nonce and returns the identity it proves
+func (p *Provider) Verify(ctx context.Context, rawIDToken, nonce string) (Identity, error) {
+	d, err := p.getDiscovery(ctx)
+	if err != nil {
+		return Identity{}, err
+	}
+	parser := jwt.Parser{
+		ValidMethods: signingAlgorithms(d),
+		// claims are validated below, jwt-go only knows a single audience
+		SkipClaimsValidation: true,
+	}
+	claims := jwt.MapClaims{}
+	_, err = parser.ParseWithClaims(rawIDToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.key(ctx, d, kid)
+	})
+	if err != nil {
+		return Identity{}, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+	identity, err := p.validate(d, claims, nonce)
+	if err != nil {
+		return Identity{}, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+	return identity, nil
+}
+
+func (p *Provider) validate(d *discovery, claims jwt.MapClaims, nonce string) (Identity, error) {
+	now := p.now()
+	if iss, _ := claims["iss"].(string); iss != d.Issuer {
+		return Identity{}, fmt.Errorf("unexpected issuer %q", iss)
+	}
+	audiences, err := audience(claims["aud"])
+	if err != nil {
+		return Identity{}, err
+	}
+	if !contains(audiences, p.cfg.ClientID) {
+		return Identity{}, errors.New("token was not issued for this client")
+	}
+	if azp, ok := claims["azp"].(string); (ok || len(audiences) > 1) && azp != p.cfg.ClientID {
+		return Identity{}, fmt.Errorf("token was authorized for %q", azp)
+	}
+	exp, ok := unixTime(claims["exp"])
+	if !ok {
+		return Identity{}, errors.New("token has no expiry")
+	}
+	if now.After(exp.Add(clockSkew)) {
+		return Identity{}, errors.New("token is expired")
+	}
+	iat, ok := unixTime(claims["iat"])
+	if !ok {
+		return Identity{}, errors.New("token has no issue time")
+	}
+	if now.Add(clockSkew).Before(iat) {
+		return Identity{}, errors.New("token used before issued")
+	}
+	if got, _ := claims["nonce"].(string); got == "" || got != nonce {
+		return Identity{}, errors.New("nonce does not match")
+	}
+
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return Identity{}, errors.New("token has no subject")
+	}
+	// usernames at the provider can be changed and reused, accounts are matched
+	// by email, which the provider must vouch for since anyone could enter one
+	email, _ := claims["email"].(string)
+	if email == "" {
+		return Identity{}, errors.New("token has no email claim")
+	}
+	if verified, _ := claims["email_verified"].(bool); !verified {
+		return Identity{}, errors.New("email is not verified")
+	}
+	return Identity{Subject: subject, Email: email}, nil
+}
+
+// key returns the signing key kid of the provider, fetching the key set again
+// when the key is unknown
+func (p *Provider) key(ctx context.Context, d *discovery, kid string) (interface{}, error) {
+	p.keysMu.Lock()
+	defer p.keysMu.Unlock()
+
+	if p.keys != nil {
+		if key, ok := p.keys.lookup(kid); ok {
+			return key, nil
+		}
+		if p.now().Sub(p.keys.fetchedAt) < keyRefreshInterval {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+	}
+	keys, err := p.fetchKeys(ctx, d.JWKSURI)
+	if err != nil {
+		return nil, err
+	}
+	p.keys = keys
+	if key, ok := p.keys.lookup(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// lookup finds key kid, tokens without a kid can only use a single key
+func (s *keySet) lookup(kid string) (interface{}, bool) {
+	if kid != "" {
+		key, ok := s.keys[kid]
+		return key, ok
+	}
+	var only interface{}
+	for _, key := range s.keys {
+		if only != nil {
+			return nil, false
+		}
+		only = key
+	}
+	return only, only != nil
+}
+
+func (p *Provider) fetchKeys(ctx context.Context, uri string) (*keySet, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	status, err := p.do(request, &set)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("oidc: fetching %s failed with %d", uri, status)
+	}
+
+	keys := &keySet{keys: map[string]interface{}{}, fetchedAt: p.now()}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		// keys of other types or curves are skipped rather than failing the set
+		if key, err := k.publicKey(); err == nil {
+			keys.keys[k.KeyID] = key
+		}
+	}
+	return keys, nil
+}
+
+func (k jsonWebKey) publicKey() (interface{}, error) {
+	switch k.KeyType {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("RSA exponent is too large")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if k.Curve != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Curve)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !elliptic.P256().IsOnCurve(x, y) {
+			return nil, errors.New("EC point is not on the curve")
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.KeyType)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("empty key parameter")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// signingAlgorithms are the algorithms both the provider and EMS support
+func signingAlgorithms(d *discovery) []string {
+	if len(d.SigningAlgorithms) == 0 {
+		return []string{"RS256"}
+	}
+	var algorithms []string
+	for _, alg := range supportedAlgorithms {
+		if contains(d.SigningAlgorithms, alg) {
+			algorithms = append(algorithms, alg)
+		}
+	}
+	return algorithms
+}
+
+// audience reads the aud claim, which is a string or an array of strings
+func audience(aud interface{}) ([]string, error) {
+	switch aud := aud.(type) {
+	case string:
+		return []string{aud}, nil
+	case []interface{}:
+		audiences := make([]string, 0, len(aud))
+		for _, a := range aud {
+			s, ok := a.(string)
+			if !ok {
+				return nil, errors.New("malformed audience")
+			}
+			audiences = append(audiences, s)
+		}
+		return audiences, nil
+	default:
+		return nil, errors.New("token has no audience")
+	}
+}
+
+func unixTime(v interface{}) (time.Time, bool) {
+	switch v := v.(type) {
+	case float64:
+		return time.Unix(int64(v), 0), true
+	case json.Number:
+		n, err := v.Int64()
+		return time.Unix(n, 0), err == nil
+	default:
+		return time.Time{}, false
+	}
+}
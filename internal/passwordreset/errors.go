@@ -0,0 +1,7 @@
+package passwordreset
+
+type InvalidResetTokenError struct{}
+
+func (m *InvalidResetTokenError) Error() string {
+	return "invalid or expired password reset token"
+}
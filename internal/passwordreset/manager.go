@@ -0,0 +1,128 @@
+// Package passwordreset issues the single use tokens employees use to choose a
+// new password when they forgot theirs.
+package passwordreset
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/pascaloseko/ems/internal/employees"
+	"github.com/pascaloseko/ems/internal/mail"
+	"github.com/pascaloseko/ems/internal/pkg/secret"
+)
+
+const defaultResetTokenTTL = time.Hour
+
+type Manager struct {
+	store  Store
+	mailer mail.Mailer
+	ttl    time.Duration
+	// resetURL is the page the emailed link points at, the token is appended as
+	// the token query parameter. Without it the bare token is emailed.
+	resetURL string
+	now      func() time.Time
+}
+
+func NewManager(store Store, mailer mail.Mailer, ttl time.Duration, resetURL string) *Manager {
+	if ttl <= 0 {
+		ttl = defaultResetTokenTTL
+	}
+	return &Manager{
+		store:    store,
+		mailer:   mailer,
+		ttl:      ttl,
+		resetURL: resetURL,
+		now:      time.Now,
+	}
+}
+
+// Request emails employee a new reset token. Tokens requested earlier stop working.
+func (m *Manager) Request(ctx context.Context, employee employees.Employee) error {
+	id, err := secret.ID()
+	if err != nil {
+		return err
+	}
+	token, err := secret.Token()
+	if err != nil {
+		return err
+	}
+
+	now := m.now()
+	if err := m.store.InvalidateResetTokens(ctx, employee.ID, now); err != nil {
+		return err
+	}
+	err = m.store.SaveResetToken(ctx, Token{
+		ID:         id,
+		EmployeeID: employee.ID,
+		TokenHash:  secret.Hash(token),
+		CreatedAt:  now,
+		ExpiresAt:  now.Add(m.ttl),
+	})
+	if err != nil {
+		return err
+	}
+	return m.mailer.Send(ctx, m.message(employee, token))
+}
+
+// Check returns the employee token resets the password of without using it up,
+// so that the new password can be validated before the token is spent
+func (m *Manager) Check(ctx context.Context, token string) (int64, error) {
+	current, err := m.valid(ctx, token)
+	if err != nil {
+		return 0, err
+	}
+	return current.EmployeeID, nil
+}
+
+// Redeem uses up token and returns the employee whose password may be reset
+func (m *Manager) Redeem(ctx context.Context, token string) (int64, error) {
+	current, err := m.valid(ctx, token)
+	if err != nil {
+		return 0, err
+	}
+	used, err := m.store.MarkResetTokenUsed(ctx, current.ID, m.now())
+	if err != nil {
+		return 0, err
+	}
+	if !used {
+		return 0, &InvalidResetTokenError{}
+	}
+	return current.EmployeeID, nil
+}
+
+// valid looks token up and fails unless it is unused and unexpired
+func (m *Manager) valid(ctx context.Context, token string) (Token, error) {
+	current, err := m.store.GetResetTokenByHash(ctx, secret.Hash(token))
+	if err != nil {
+		return Token{}, err
+	}
+	if current.UsedAt != nil || !m.now().Before(current.ExpiresAt) {
+		return Token{}, &InvalidResetTokenError{}
+	}
+	return current, nil
+}
+
+func (m *Manager) message(employee employees.Employee, token string) mail.Message {
+	instructions := "Use this token with the resetPassword mutation"
+	value := token
+	if m.resetURL != "" {
+		instructions = "Follow this link"
+		value = m.resetURL + "?token=" + url.QueryEscape(token)
+	}
+	body := fmt.Sprintf(`Hi %s,
+
+Somebody asked to reset the password of the EMS account %s. %s within %s to choose a new password:
+
+%s
+
+If this was not you, you can ignore this email. Your password has not been changed.
+`, employee.FirstName, employee.Username, instructions, m.ttl, value)
+
+	return mail.Message{
+		To:      employee.Email,
+		Subject: "Reset your EMS password",
+		Body:    body,
+	}
+}
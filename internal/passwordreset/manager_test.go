@@ -0,0 +1,112 @@
+package passwordreset_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pascaloseko/ems/internal/employees"
+	"github.com/pascaloseko/ems/internal/mail"
+	"github.com/pascaloseko/ems/internal/mockdb"
+	"github.com/pascaloseko/ems/internal/passwordreset"
+	"github.com/pascaloseko/ems/internal/pkg/secret"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequest(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockResetStore(ctrl)
+
+	var saved passwordreset.Token
+	gomock.InOrder(
+		// earlier tokens stop working
+		store.EXPECT().InvalidateResetTokens(gomock.Any(), int64(7), gomock.Any()).Times(1).Return(nil),
+		store.EXPECT().SaveResetToken(gomock.Any(), gomock.Any()).Times(1).DoAndReturn(
+			func(_ context.Context, token passwordreset.Token) error {
+				saved = token
+				return nil
+			}),
+	)
+
+	var outbox bytes.Buffer
+	manager := passwordreset.NewManager(store, mail.NewLogMailer(&outbox, "ems@example.com"), 30*time.Minute, "https://ems.example.com/reset")
+	err := manager.Request(context.Background(), employees.Employee{ID: 7, FirstName: "Jane", Username: "jane", Email: "jane@example.com"})
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(7), saved.EmployeeID)
+	assert.WithinDuration(t, time.Now().Add(30*time.Minute), saved.ExpiresAt, time.Minute)
+	assert.Contains(t, outbox.String(), "To: jane@example.com")
+	assert.Contains(t, outbox.String(), "https://ems.example.com/reset?token=")
+	assert.Contains(t, outbox.String(), "within 30m0s")
+	assert.NotContains(t, outbox.String(), saved.TokenHash)
+}
+
+func TestRedeem(t *testing.T) {
+	past := time.Now().Add(-time.Second)
+	valid := passwordreset.Token{ID: "reset-1", EmployeeID: 7, TokenHash: secret.Hash("token"), ExpiresAt: time.Now().Add(time.Hour)}
+	used := valid
+	used.UsedAt = &past
+	expired := valid
+	expired.ExpiresAt = past
+
+	testCases := []struct {
+		name       string
+		token      passwordreset.Token
+		buildStubs func(store *mockdb.MockResetStore)
+		wantErr    bool
+	}{
+		{
+			name:  "OK",
+			token: valid,
+			buildStubs: func(store *mockdb.MockResetStore) {
+				store.EXPECT().MarkResetTokenUsed(gomock.Any(), "reset-1", gomock.Any()).Times(1).Return(true, nil)
+			},
+		},
+		{
+			name:  "Used",
+			token: used,
+			buildStubs: func(store *mockdb.MockResetStore) {
+				store.EXPECT().MarkResetTokenUsed(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			wantErr: true,
+		},
+		{
+			name:  "Expired",
+			token: expired,
+			buildStubs: func(store *mockdb.MockResetStore) {
+				store.EXPECT().MarkResetTokenUsed(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			wantErr: true,
+		},
+		{
+			name:  "UsedConcurrently",
+			token: valid,
+			buildStubs: func(store *mockdb.MockResetStore) {
+				store.EXPECT().MarkResetTokenUsed(gomock.Any(), "reset-1", gomock.Any()).Times(1).Return(false, nil)
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockResetStore(ctrl)
+			store.EXPECT().GetResetTokenByHash(gomock.Any(), secret.Hash("token")).Times(1).Return(tc.token, nil)
+			tc.buildStubs(store)
+
+			employeeID, err := passwordreset.NewManager(store, nil, time.Hour, "").Redeem(context.Background(), "token")
+			if tc.wantErr {
+				assert.IsType(t, &passwordreset.InvalidResetTokenError{}, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, int64(7), employeeID)
+		})
+	}
+}
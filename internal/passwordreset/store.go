@@ -0,0 +1,94 @@
+package passwordreset
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Token is a single use password reset token, only its hash is stored
+type Token struct {
+	ID         string
+	EmployeeID int64
+	TokenHash  string
+	CreatedAt  time.Time
+	ExpiresAt  time.Time
+	UsedAt     *time.Time
+}
+
+type Store interface {
+	SaveResetToken(ctx context.Context, token Token) error
+	GetResetTokenByHash(ctx context.Context, hash string) (Token, error)
+	MarkResetTokenUsed(ctx context.Context, id string, at time.Time) (bool, error)
+	InvalidateResetTokens(ctx context.Context, employeeID int64, at time.Time) error
+}
+
+type ResetStore struct {
+	store *sql.DB
+}
+
+func NewResetStore(db *sql.DB) Store {
+	return &ResetStore{
+		store: db,
+	}
+}
+
+// SaveResetToken implements Store.
+func (s *ResetStore) SaveResetToken(ctx context.Context, t Token) error {
+	tsql := `
+	INSERT INTO Password_Reset_Tokens (ID, Employee_Id, Token_Hash, Created_At, Expires_At)
+	VALUES (@ID, @Employee_Id, @Token_Hash, @Created_At, @Expires_At)
+	`
+	_, err := s.store.ExecContext(
+		ctx,
+		tsql,
+		sql.Named("ID", t.ID),
+		sql.Named("Employee_Id", t.EmployeeID),
+		sql.Named("Token_Hash", t.TokenHash),
+		sql.Named("Created_At", t.CreatedAt),
+		sql.Named("Expires_At", t.ExpiresAt))
+	return err
+}
+
+// GetResetTokenByHash implements Store.
+func (s *ResetStore) GetResetTokenByHash(ctx context.Context, hash string) (Token, error) {
+	tsql := `
+	SELECT ID, Employee_Id, Token_Hash, Created_At, Expires_At, Used_At
+	FROM Password_Reset_Tokens WHERE Token_Hash = @Token_Hash
+	`
+	row := s.store.QueryRowContext(ctx, tsql, sql.Named("Token_Hash", hash))
+	var t Token
+	var usedAt sql.NullTime
+	err := row.Scan(&t.ID, &t.EmployeeID, &t.TokenHash, &t.CreatedAt, &t.ExpiresAt, &usedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Token{}, &InvalidResetTokenError{}
+		}
+		return Token{}, err
+	}
+	if usedAt.Valid {
+		t.UsedAt = &usedAt.Time
+	}
+	return t, nil
+}
+
+// MarkResetTokenUsed implements Store. It reports false when the token had
+// already been used, so that a token can only be redeemed once.
+func (s *ResetStore) MarkResetTokenUsed(ctx context.Context, id string, at time.Time) (bool, error) {
+	result, err := s.store.ExecContext(ctx, "UPDATE Password_Reset_Tokens SET Used_At = @At WHERE ID = @ID AND Used_At IS NULL", sql.Named("At", at), sql.Named("ID", id))
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+// InvalidateResetTokens implements Store.
+func (s *ResetStore) InvalidateResetTokens(ctx context.Context, employeeID int64, at time.Time) error {
+	_, err := s.store.ExecContext(ctx, "UPDATE Password_Reset_Tokens SET Used_At = @At WHERE Employee_Id = @Employee_Id AND Used_At IS NULL", sql.Named("At", at), sql.Named("Employee_Id", employeeID))
+	return err
+}
@@ -0,0 +1,139 @@
+// Package passwords decides which passwords employees may choose.
+package passwords
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxLength is the longest password in bytes, bcrypt ignores anything after it
+const MaxLength = 72
+
+// Rules a password can violate
+const (
+	RuleMinLength        = "min_length"
+	RuleMaxLength        = "max_length"
+	RuleCharacterClasses = "character_classes"
+	RuleCommon           = "common_password"
+	RulePersonalInfo     = "personal_info"
+)
+
+//go:embed common.txt
+var commonPasswords string
+
+// Violation is one rule a password breaks
+type Violation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// PolicyViolationError lists every rule a password breaks
+type PolicyViolationError struct {
+	Violations []Violation
+}
+
+func (m *PolicyViolationError) Error() string {
+	messages := make([]string, 0, len(m.Violations))
+	for _, v := range m.Violations {
+		messages = append(messages, v.Message)
+	}
+	return "password " + strings.Join(messages, ", ")
+}
+
+// Policy is the set of rules new passwords are checked against
+type Policy struct {
+	// MinLength is the least number of characters
+	MinLength int
+	// MinClasses is how many of lowercase letters, uppercase letters, digits and
+	// symbols a password needs to mix
+	MinClasses int
+
+	denylist map[string]struct{}
+}
+
+// NewPolicy returns a policy that also rejects a built in list of common passwords
+func NewPolicy(minLength, minClasses int) *Policy {
+	p := &Policy{MinLength: minLength, MinClasses: minClasses, denylist: map[string]struct{}{}}
+	// the embedded list always parses
+	_ = p.AddDenylist(strings.NewReader(commonPasswords))
+	return p
+}
+
+// AddDenylist adds the passwords in r, one per line, to the passwords rejected as too common
+func (p *Policy) AddDenylist(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if password := strings.TrimSpace(scanner.Text()); password != "" {
+			p.denylist[strings.ToLower(password)] = struct{}{}
+		}
+	}
+	return scanner.Err()
+}
+
+// Check returns a *PolicyViolationError when password breaks any rule. personal
+// are the username, email and similar, which the password must not contain.
+func (p *Policy) Check(password string, personal ...string) error {
+	var violations []Violation
+	if utf8.RuneCountInString(password) < p.MinLength {
+		violations = append(violations, Violation{RuleMinLength, fmt.Sprintf("must be at least %d characters long", p.MinLength)})
+	}
+	if len(password) > MaxLength {
+		violations = append(violations, Violation{RuleMaxLength, fmt.Sprintf("must be at most %d bytes long", MaxLength)})
+	}
+	if classes(password) < p.MinClasses {
+		violations = append(violations, Violation{RuleCharacterClasses, fmt.Sprintf("must mix at least %d of lowercase letters, uppercase letters, digits and symbols", p.MinClasses)})
+	}
+	lower := strings.ToLower(password)
+	if _, ok := p.denylist[lower]; ok {
+		violations = append(violations, Violation{RuleCommon, "is too common"})
+	}
+	if containsPersonal(lower, personal) {
+		violations = append(violations, Violation{RulePersonalInfo, "must not contain your username or email"})
+	}
+
+	if len(violations) > 0 {
+		return &PolicyViolationError{Violations: violations}
+	}
+	return nil
+}
+
+// classes counts the character classes used in password
+func classes(password string) int {
+	var lower, upper, digit, symbol int
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			symbol = 1
+		}
+	}
+	return lower + upper + digit + symbol
+}
+
+func containsPersonal(password string, personal []string) bool {
+	for _, value := range personal {
+		value = strings.ToLower(strings.TrimSpace(value))
+		candidates := []string{value}
+		// the mailbox name on its own counts too
+		if at := strings.IndexByte(value, '@'); at > 0 {
+			candidates = append(candidates, value[:at])
+		}
+		for _, candidate := range candidates {
+			// very short values would match too many passwords by accident
+			if len(candidate) >= 3 && strings.Contains(password, candidate) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package passwords
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func rules(err error) []string {
+	violation, ok := err.(*PolicyViolationError)
+	if !ok {
+		return nil
+	}
+	var rules []string
+	for _, v := range violation.Violations {
+		rules = append(rules, v.Rule)
+	}
+	return rules
+}
+
+func TestCheck(t *testing.T) {
+	policy := NewPolicy(10, 3)
+	for name, tc := range map[string]struct {
+		password string
+		want     []string
+	}{
+		"good":             {"correct-Horse-battery", nil},
+		"empty":            {"", []string{RuleMinLength, RuleCharacterClasses}},
+		"short":            {"Ab1!", []string{RuleMinLength}},
+		"one class":        {"abcdefghijklmnop", []string{RuleCharacterClasses}},
+		"common":           {"Password123", []string{RuleCommon}},
+		"common any case":  {"PASSWORD123!", nil},
+		"username":         {"xx-Pascal-2024", []string{RulePersonalInfo}},
+		"email mailbox":    {"Oseko.Pascal#9", []string{RulePersonalInfo}},
+		"too long":         {strings.Repeat("aB1", 25), []string{RuleMaxLength}},
+		"multibyte length": {"Ünïcödé-ß1", nil},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := policy.Check(tc.password, "pascal", "oseko.pascal@example.com")
+			assert.Equal(t, tc.want, rules(err))
+			if tc.want == nil {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestAddDenylist(t *testing.T) {
+	policy := NewPolicy(0, 0)
+	require.NoError(t, policy.Check("Acme-Corp-2024"))
+	require.NoError(t, policy.AddDenylist(strings.NewReader("\nacme-corp-2024\n\n")))
+	assert.Equal(t, []string{RuleCommon}, rules(policy.Check("Acme-Corp-2024")))
+}
+
+func TestViolationMessage(t *testing.T) {
+	err := NewPolicy(10, 0).Check("short")
+	require.Error(t, err)
+	assert.Equal(t, "password must be at least 10 characters long", err.Error())
+}
@@ -0,0 +1,152 @@
+package dataloader
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	defaultWait     = 2 * time.Millisecond
+	defaultMaxBatch = 500
+)
+
+// BatchFunc fetches the values for keys in a single round trip. Keys that do not
+// exist are simply left out of the returned map.
+type BatchFunc[K comparable, V any] func(ctx context.Context, keys []K) (map[K]V, error)
+
+// Loader collects the keys requested within a short window into one call of its
+// BatchFunc and caches every result for the lifetime of the loader, so a loader
+// should be created per request.
+type Loader[K comparable, V any] struct {
+	fetch    BatchFunc[K, V]
+	wait     time.Duration
+	maxBatch int
+
+	mu    sync.Mutex
+	cache map[K]*result[V]
+	batch *batch[K, V]
+}
+
+type result[V any] struct {
+	done  chan struct{}
+	value V
+	found bool
+	err   error
+}
+
+type batch[K comparable, V any] struct {
+	keys    []K
+	results []*result[V]
+	sent    bool
+}
+
+type Option func(*options)
+
+type options struct {
+	wait     time.Duration
+	maxBatch int
+}
+
+// WithWait sets how long a loader waits for more keys before dispatching a batch
+func WithWait(wait time.Duration) Option {
+	return func(o *options) { o.wait = wait }
+}
+
+// WithMaxBatch caps the number of keys passed to a single BatchFunc call
+func WithMaxBatch(maxBatch int) Option {
+	return func(o *options) { o.maxBatch = maxBatch }
+}
+
+func New[K comparable, V any](fetch BatchFunc[K, V], opts ...Option) *Loader[K, V] {
+	o := options{wait: defaultWait, maxBatch: defaultMaxBatch}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Loader[K, V]{
+		fetch:    fetch,
+		wait:     o.wait,
+		maxBatch: o.maxBatch,
+		cache:    map[K]*result[V]{},
+	}
+}
+
+// Load returns the value for key, reporting whether it exists
+func (l *Loader[K, V]) Load(ctx context.Context, key K) (V, bool, error) {
+	l.mu.Lock()
+	res, ok := l.cache[key]
+	if !ok {
+		res = &result[V]{done: make(chan struct{})}
+		l.cache[key] = res
+		l.enqueue(ctx, key, res)
+	}
+	l.mu.Unlock()
+
+	select {
+	case <-res.done:
+		return res.value, res.found, res.err
+	case <-ctx.Done():
+		var zero V
+		return zero, false, ctx.Err()
+	}
+}
+
+// enqueue adds key to the pending batch. l.mu must be held.
+func (l *Loader[K, V]) enqueue(ctx context.Context, key K, res *result[V]) {
+	if l.batch == nil {
+		b := &batch[K, V]{}
+		l.batch = b
+		time.AfterFunc(l.wait, func() {
+			l.mu.Lock()
+			l.detach(b)
+			l.mu.Unlock()
+			l.dispatch(ctx, b)
+		})
+	}
+	b := l.batch
+	b.keys = append(b.keys, key)
+	b.results = append(b.results, res)
+	if len(b.keys) >= l.maxBatch {
+		l.detach(b)
+		go l.dispatch(ctx, b)
+	}
+}
+
+// detach stops b from accepting new keys. l.mu must be held.
+func (l *Loader[K, V]) detach(b *batch[K, V]) {
+	if l.batch == b {
+		l.batch = nil
+	}
+}
+
+func (l *Loader[K, V]) dispatch(ctx context.Context, b *batch[K, V]) {
+	l.mu.Lock()
+	if b.sent {
+		l.mu.Unlock()
+		return
+	}
+	b.sent = true
+	l.mu.Unlock()
+
+	values, err := l.fetch(ctx, b.keys)
+	for i, key := range b.keys {
+		res := b.results[i]
+		if err != nil {
+			res.err = err
+		} else {
+			res.value, res.found = values[key]
+		}
+		close(res.done)
+	}
+
+	// failed lookups are not cached so a later Load can try again
+	if err != nil {
+		l.mu.Lock()
+		for i, key := range b.keys {
+			if l.cache[key] == b.results[i] {
+				delete(l.cache, key)
+			}
+		}
+		l.mu.Unlock()
+	}
+}
@@ -0,0 +1,97 @@
+package dataloader
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoaderBatchesAndCaches(t *testing.T) {
+	var calls int32
+	var seen []int
+	loader := New(func(ctx context.Context, keys []int) (map[int]string, error) {
+		atomic.AddInt32(&calls, 1)
+		seen = append(seen, keys...)
+		values := map[int]string{}
+		for _, key := range keys {
+			if key%2 == 0 {
+				values[key] = "even"
+			}
+		}
+		return values, nil
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(key int) {
+			defer wg.Done()
+			value, found, err := loader.Load(context.Background(), key%10)
+			assert.NoError(t, err)
+			assert.Equal(t, key%2 == 0, found)
+			if found {
+				assert.Equal(t, "even", value)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	assert.Len(t, seen, 10)
+
+	// cached keys do not trigger another fetch
+	_, _, err := loader.Load(context.Background(), 4)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestLoaderMaxBatch(t *testing.T) {
+	var calls int32
+	loader := New(func(ctx context.Context, keys []int) (map[int]int, error) {
+		atomic.AddInt32(&calls, 1)
+		assert.LessOrEqual(t, len(keys), 5)
+		values := map[int]int{}
+		for _, key := range keys {
+			values[key] = key
+		}
+		return values, nil
+	}, WithMaxBatch(5))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(key int) {
+			defer wg.Done()
+			value, found, err := loader.Load(context.Background(), key)
+			assert.NoError(t, err)
+			assert.True(t, found)
+			assert.Equal(t, key, value)
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, int32(4), atomic.LoadInt32(&calls))
+}
+
+func TestLoaderErrorsAreNotCached(t *testing.T) {
+	fail := true
+	loader := New(func(ctx context.Context, keys []int) (map[int]int, error) {
+		if fail {
+			return nil, errors.New("boom")
+		}
+		return map[int]int{1: 1}, nil
+	})
+
+	_, _, err := loader.Load(context.Background(), 1)
+	require.Error(t, err)
+
+	fail = false
+	value, found, err := loader.Load(context.Background(), 1)
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, 1, value)
+}
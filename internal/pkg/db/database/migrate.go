@@ -0,0 +1,332 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//go:embed migrations
+var migrationFiles embed.FS
+
+// migrationFile matches migrations/<dialect>/<version>_<name>.<up|down>.sql
+var migrationFile = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// migrationLockKey identifies the advisory lock of PostgreSQL migrations
+const migrationLockKey = 0x656d735f6d6967
+
+// Migration is a versioned change of the schema. Every version has an up and
+// a down SQL file per dialect, under migrations/<dialect>.
+type Migration struct {
+	Version int64
+	Name    string
+	up      string
+	down    string
+}
+
+// MigrationState is a migration together with when it was applied, AppliedAt
+// is zero while it is pending. Unknown is set for versions applied by a newer
+// build that this one has no files for.
+type MigrationState struct {
+	Migration
+	AppliedAt time.Time
+	Unknown   bool
+}
+
+// Migrator applies and rolls back migrations, recording the applied versions
+// in the schema_migrations table. Replicas migrating at the same time take
+// turns: PostgreSQL and SQL Server hold a lock for the whole run, and SQLite
+// transactions take its write lock when they begin.
+type Migrator struct {
+	db         *sql.DB
+	dialect    Dialect
+	migrations []Migration
+}
+
+// NewMigrator returns a Migrator for the migrations of dialect
+func NewMigrator(db *sql.DB, dialect Dialect) (*Migrator, error) {
+	migrations, err := loadMigrations(dialect)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{
+		db:         db,
+		dialect:    dialect,
+		migrations: migrations,
+	}, nil
+}
+
+func loadMigrations(dialect Dialect) ([]Migration, error) {
+	dir := path.Join("migrations", string(dialect))
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, fmt.Errorf("database: no migrations for %s: %w", dialect, err)
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		match := migrationFile.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("database: unexpected migration file %s/%s", dir, entry.Name())
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		sql, err := fs.ReadFile(migrationFiles, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("database: migration %d is named both %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.up = string(sql)
+		} else {
+			m.down = string(sql)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == "" || m.down == "" {
+			return nil, fmt.Errorf("database: migration %d_%s needs both an up and a down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Up applies every pending migration in order and returns them
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		states, err := m.status(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, state := range states {
+			if state.Unknown {
+				log.Printf("database: migration %d was applied by a newer version of EMS", state.Version)
+			}
+			if !state.AppliedAt.IsZero() {
+				continue
+			}
+			done, err := m.run(ctx, conn, state.Migration, true)
+			if err != nil {
+				return err
+			}
+			if done {
+				applied = append(applied, state.Migration)
+			}
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down rolls back the last steps applied migrations, newest first, and
+// returns them
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var rolledBack []Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		states, err := m.status(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(states) - 1; i >= 0 && len(rolledBack) < steps; i-- {
+			state := states[i]
+			if state.AppliedAt.IsZero() {
+				continue
+			}
+			if state.Unknown {
+				return fmt.Errorf("database: cannot roll back migration %d, it was applied by a newer version of EMS", state.Version)
+			}
+			done, err := m.run(ctx, conn, state.Migration, false)
+			if err != nil {
+				return err
+			}
+			if done {
+				rolledBack = append(rolledBack, state.Migration)
+			}
+		}
+		return nil
+	})
+	return rolledBack, err
+}
+
+// Status lists every migration, applied or not, by version
+func (m *Migrator) Status(ctx context.Context) ([]MigrationState, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err := m.createTable(ctx, conn); err != nil {
+		return nil, err
+	}
+	return m.status(ctx, conn)
+}
+
+func (m *Migrator) status(ctx context.Context, conn *sql.Conn) ([]MigrationState, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := map[int64]time.Time{}
+	for rows.Next() {
+		var version int64
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	states := make([]MigrationState, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		states = append(states, MigrationState{Migration: migration, AppliedAt: applied[migration.Version]})
+		delete(applied, migration.Version)
+	}
+	for version, at := range applied {
+		states = append(states, MigrationState{Migration: Migration{Version: version}, AppliedAt: at, Unknown: true})
+	}
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].Version < states[j].Version
+	})
+	return states, nil
+}
+
+// run applies or rolls back migration in a transaction together with its
+// schema_migrations row. It does nothing and returns false when another
+// replica got there first.
+func (m *Migrator) run(ctx context.Context, conn *sql.Conn, migration Migration, up bool) (bool, error) {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var count int
+	err = tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM schema_migrations WHERE version = @Version", sql.Named("Version", migration.Version)).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	if (count > 0) == up {
+		return false, nil
+	}
+
+	script, direction := migration.up, "up"
+	if !up {
+		script, direction = migration.down, "down"
+	}
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return false, fmt.Errorf("database: migration %d_%s %s: %w", migration.Version, migration.Name, direction, err)
+	}
+	if up {
+		_, err = tx.ExecContext(
+			ctx,
+			"INSERT INTO schema_migrations (version, name, applied_at) VALUES (@Version, @Name, @Applied_At)",
+			sql.Named("Version", migration.Version),
+			sql.Named("Name", migration.Name),
+			sql.Named("Applied_At", time.Now().UTC()))
+	} else {
+		_, err = tx.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = @Version", sql.Named("Version", migration.Version))
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	log.Printf("database: migrated %s %04d_%s", direction, migration.Version, migration.Name)
+	return true, nil
+}
+
+// locked runs fn on a connection holding the migration lock
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	switch m.dialect {
+	case Postgres:
+		if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock(@Key)", sql.Named("Key", int64(migrationLockKey))); err != nil {
+			return err
+		}
+		defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock(@Key)", sql.Named("Key", int64(migrationLockKey)))
+	case MSSQL:
+		tsql := `
+		DECLARE @Result int;
+		EXEC @Result = sp_getapplock @Resource = 'ems_schema_migrations', @LockMode = 'Exclusive', @LockOwner = 'Session', @LockTimeout = -1;
+		IF @Result < 0 THROW 50000, 'failed to lock schema_migrations', 1;
+		`
+		if _, err := conn.ExecContext(ctx, tsql); err != nil {
+			return err
+		}
+		defer conn.ExecContext(context.Background(), "EXEC sp_releaseapplock @Resource = 'ems_schema_migrations', @LockOwner = 'Session'")
+	}
+
+	if err := m.createTable(ctx, conn); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+func (m *Migrator) createTable(ctx context.Context, conn *sql.Conn) error {
+	var ddl string
+	switch m.dialect {
+	case MSSQL:
+		ddl = `
+		IF OBJECT_ID(N'schema_migrations', N'U') IS NULL
+		CREATE TABLE schema_migrations (
+			version bigint PRIMARY KEY,
+			name nvarchar(255) NOT NULL,
+			applied_at datetimeoffset NOT NULL
+		)
+		`
+	case Postgres:
+		ddl = `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version bigint PRIMARY KEY,
+			name varchar(255) NOT NULL,
+			applied_at timestamptz NOT NULL
+		)
+		`
+	case SQLite:
+		ddl = `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version integer PRIMARY KEY,
+			name text NOT NULL,
+			applied_at datetime NOT NULL
+		)
+		`
+	default:
+		return errors.New("database: unknown dialect " + string(m.dialect))
+	}
+	_, err := conn.ExecContext(ctx, ddl)
+	return err
+}
@@ -0,0 +1,210 @@
+package database_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/pascaloseko/ems/internal/pkg/db/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openSQLite(t *testing.T) (*sql.DB, *database.Migrator) {
+	db, dialect, err := database.Open("sqlite:" + filepath.Join(t.TempDir(), "ems.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	migrator, err := database.NewMigrator(db, dialect)
+	require.NoError(t, err)
+	return db, migrator
+}
+
+// downTo rolls back the migrations after version
+func downTo(t *testing.T, migrator *database.Migrator, version int64) {
+	ctx := context.Background()
+	states, err := migrator.Status(ctx)
+	require.NoError(t, err)
+	steps := 0
+	for _, state := range states {
+		if state.Version > version && !state.AppliedAt.IsZero() {
+			steps++
+		}
+	}
+	_, err = migrator.Down(ctx, steps)
+	require.NoError(t, err)
+}
+
+func tableExists(t *testing.T, db *sql.DB, table string) bool {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = @Name", sql.Named("Name", table)).Scan(&count)
+	require.NoError(t, err)
+	return count > 0
+}
+
+func TestMigrateUpDown(t *testing.T) {
+	ctx := context.Background()
+	db, migrator := openSQLite(t)
+
+	states, err := migrator.Status(ctx)
+	require.NoError(t, err)
+	require.NotEmpty(t, states)
+	for _, state := range states {
+		assert.True(t, state.AppliedAt.IsZero(), "migration %d is pending", state.Version)
+	}
+
+	applied, err := migrator.Up(ctx)
+	require.NoError(t, err)
+	assert.Len(t, applied, len(states))
+	assert.True(t, tableExists(t, db, "employee_entities"))
+
+	states, err = migrator.Status(ctx)
+	require.NoError(t, err)
+	for _, state := range states {
+		assert.False(t, state.AppliedAt.IsZero(), "migration %d is applied", state.Version)
+	}
+
+	// nothing is left to apply
+	applied, err = migrator.Up(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, applied)
+
+	rolledBack, err := migrator.Down(ctx, len(states))
+	require.NoError(t, err)
+	require.Len(t, rolledBack, len(states))
+	assert.Equal(t, states[len(states)-1].Version, rolledBack[0].Version, "newest first")
+	assert.False(t, tableExists(t, db, "employee_entities"))
+
+	// nothing is left to roll back
+	rolledBack, err = migrator.Down(ctx, 1)
+	require.NoError(t, err)
+	assert.Empty(t, rolledBack)
+
+	applied, err = migrator.Up(ctx)
+	require.NoError(t, err)
+	assert.Len(t, applied, len(states))
+}
+
+func TestMigrateUnknownVersion(t *testing.T) {
+	ctx := context.Background()
+	db, migrator := openSQLite(t)
+	_, err := migrator.Up(ctx)
+	require.NoError(t, err)
+
+	_, err = db.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (9999, 'from_the_future', CURRENT_TIMESTAMP)")
+	require.NoError(t, err)
+
+	// a newer build's migrations do not stop this one from starting
+	_, err = migrator.Up(ctx)
+	assert.NoError(t, err)
+	states, err := migrator.Status(ctx)
+	require.NoError(t, err)
+	assert.True(t, states[len(states)-1].Unknown)
+
+	// but cannot be rolled back by it
+	_, err = migrator.Down(ctx, 1)
+	assert.Error(t, err)
+}
+
+func TestMigrateConcurrently(t *testing.T) {
+	ctx := context.Background()
+	url := "sqlite:" + filepath.Join(t.TempDir(), "ems.db")
+
+	var wg sync.WaitGroup
+	applied := make([]int, 5)
+	for i := range applied {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			db, dialect, err := database.Open(url)
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer db.Close()
+			migrator, err := database.NewMigrator(db, dialect)
+			if !assert.NoError(t, err) {
+				return
+			}
+			migrations, err := migrator.Up(ctx)
+			assert.NoError(t, err)
+			applied[i] = len(migrations)
+		}(i)
+	}
+	wg.Wait()
+
+	// every migration was applied by exactly one of the replicas
+	total := 0
+	for _, n := range applied {
+		total += n
+	}
+	db, dialect, err := database.Open(url)
+	require.NoError(t, err)
+	defer db.Close()
+	migrator, err := database.NewMigrator(db, dialect)
+	require.NoError(t, err)
+	states, err := migrator.Status(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, len(states), total)
+}
+
+func TestMigrateEmployeeConstraints(t *testing.T) {
+	ctx := context.Background()
+	db, migrator := openSQLite(t)
+	_, err := migrator.Up(ctx)
+	require.NoError(t, err)
+
+	insert := func(username, email string, departmentID int64) error {
+		_, err := db.Exec(
+			"INSERT INTO employee_entities (first_name, last_name, username, password, email, dob, department_id, position) VALUES ('', '', @Username, '', @Email, '', @Department_Id, '')",
+			sql.Named("Username", username),
+			sql.Named("Email", email),
+			sql.Named("Department_Id", departmentID))
+		return err
+	}
+	_, err = db.Exec("INSERT INTO department_entities (id, name) VALUES (1, 'Engineering')")
+	require.NoError(t, err)
+	require.NoError(t, insert("ada", "ada@example.com", 1))
+
+	assert.Error(t, insert("ada", "grace@example.com", 1), "duplicate username")
+	assert.Error(t, insert("grace", "ada@example.com", 1), "duplicate email")
+	assert.Error(t, insert("grace", "grace@example.com", 42), "unknown department")
+
+	// rolling back keeps the employees but lifts the constraints
+	downTo(t, migrator, 1)
+	var count int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM employee_entities").Scan(&count))
+	assert.Equal(t, 1, count)
+	assert.NoError(t, insert("ada", "ada@example.com", 42))
+}
+
+func TestMigrateMergesDuplicateDepartments(t *testing.T) {
+	ctx := context.Background()
+	db, migrator := openSQLite(t)
+	_, err := migrator.Up(ctx)
+	require.NoError(t, err)
+	// back to before department names were unique
+	downTo(t, migrator, 2)
+
+	_, err = db.Exec("INSERT INTO department_entities (id, name) VALUES (1, 'Engineering'), (2, 'Research'), (3, 'Engineering')")
+	require.NoError(t, err)
+	_, err = db.Exec(`
+	INSERT INTO employee_entities (first_name, last_name, username, password, email, dob, department_id, position)
+	VALUES ('', '', 'ada', '', 'ada@example.com', '', 3, ''), ('', '', 'grace', '', 'grace@example.com', '', 2, '')`)
+	require.NoError(t, err)
+
+	_, err = migrator.Up(ctx)
+	require.NoError(t, err)
+
+	var departments int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM department_entities").Scan(&departments))
+	assert.Equal(t, 2, departments)
+	var ada, grace int64
+	require.NoError(t, db.QueryRow("SELECT department_id FROM employee_entities WHERE username = 'ada'").Scan(&ada))
+	require.NoError(t, db.QueryRow("SELECT department_id FROM employee_entities WHERE username = 'grace'").Scan(&grace))
+	assert.Equal(t, int64(1), ada, "moved to the oldest Engineering")
+	assert.Equal(t, int64(2), grace)
+
+	_, err = db.Exec("INSERT INTO department_entities (name) VALUES ('Research')")
+	assert.Error(t, err, "department names are unique")
+}
@@ -0,0 +1,152 @@
+-- The schema as gorm's AutoMigrate created it, tables that already exist are
+-- adopted as they are.
+
+IF OBJECT_ID(N'department_entities', N'U') IS NULL
+BEGIN
+    CREATE TABLE department_entities (
+        id bigint IDENTITY(1,1) PRIMARY KEY,
+        name nvarchar(255) NOT NULL
+    );
+END;
+
+IF OBJECT_ID(N'employee_entities', N'U') IS NULL
+BEGIN
+    CREATE TABLE employee_entities (
+        id bigint IDENTITY(1,1) PRIMARY KEY,
+        first_name nvarchar(255) NOT NULL,
+        last_name nvarchar(255) NOT NULL,
+        username nvarchar(255) NOT NULL,
+        password nvarchar(255) NOT NULL,
+        email nvarchar(255) NOT NULL,
+        dob nvarchar(64) NOT NULL,
+        department_id bigint NOT NULL,
+        position nvarchar(255) NOT NULL,
+        role nvarchar(32) NOT NULL DEFAULT 'employee'
+    );
+END;
+
+IF OBJECT_ID(N'refresh_tokens', N'U') IS NULL
+BEGIN
+    CREATE TABLE refresh_tokens (
+        id nvarchar(32) PRIMARY KEY,
+        family_id nvarchar(32) NOT NULL,
+        employee_id bigint NOT NULL,
+        token_hash nvarchar(64) NOT NULL,
+        created_at datetimeoffset NOT NULL,
+        expires_at datetimeoffset NOT NULL,
+        rotated_at datetimeoffset,
+        revoked_at datetimeoffset
+    );
+    CREATE INDEX idx_refresh_tokens_family_id ON refresh_tokens (family_id);
+    CREATE INDEX idx_refresh_tokens_employee_id ON refresh_tokens (employee_id);
+    CREATE UNIQUE INDEX idx_refresh_tokens_token_hash ON refresh_tokens (token_hash);
+END;
+
+IF OBJECT_ID(N'sessions', N'U') IS NULL
+BEGIN
+    CREATE TABLE sessions (
+        id nvarchar(32) PRIMARY KEY,
+        employee_id bigint NOT NULL,
+        created_at datetimeoffset NOT NULL,
+        last_seen_at datetimeoffset NOT NULL,
+        expires_at datetimeoffset NOT NULL,
+        user_agent nvarchar(512) NOT NULL,
+        ip_address nvarchar(64) NOT NULL,
+        revoked_at datetimeoffset
+    );
+    CREATE INDEX idx_sessions_employee_id ON sessions (employee_id);
+END;
+
+IF OBJECT_ID(N'password_reset_tokens', N'U') IS NULL
+BEGIN
+    CREATE TABLE password_reset_tokens (
+        id nvarchar(32) PRIMARY KEY,
+        employee_id bigint NOT NULL,
+        token_hash nvarchar(64) NOT NULL,
+        created_at datetimeoffset NOT NULL,
+        expires_at datetimeoffset NOT NULL,
+        used_at datetimeoffset
+    );
+    CREATE INDEX idx_password_reset_tokens_employee_id ON password_reset_tokens (employee_id);
+    CREATE UNIQUE INDEX idx_password_reset_tokens_token_hash ON password_reset_tokens (token_hash);
+END;
+
+IF OBJECT_ID(N'revoked_tokens', N'U') IS NULL
+BEGIN
+    CREATE TABLE revoked_tokens (
+        jti nvarchar(32) PRIMARY KEY,
+        expires_at datetimeoffset NOT NULL
+    );
+    CREATE INDEX idx_revoked_tokens_expires_at ON revoked_tokens (expires_at);
+END;
+
+IF OBJECT_ID(N'employee_token_revocations', N'U') IS NULL
+BEGIN
+    CREATE TABLE employee_token_revocations (
+        employee_id bigint PRIMARY KEY,
+        revoked_before datetimeoffset NOT NULL,
+        expires_at datetimeoffset NOT NULL
+    );
+    CREATE INDEX idx_employee_token_revocations_expires_at ON employee_token_revocations (expires_at);
+END;
+
+IF OBJECT_ID(N'login_failures', N'U') IS NULL
+BEGIN
+    CREATE TABLE login_failures (
+        username nvarchar(255) PRIMARY KEY,
+        failures int NOT NULL,
+        last_failed_at datetimeoffset NOT NULL,
+        locked_until datetimeoffset
+    );
+    CREATE INDEX idx_login_failures_last_failed_at ON login_failures (last_failed_at);
+END;
+
+IF OBJECT_ID(N'totp_enrollments', N'U') IS NULL
+BEGIN
+    CREATE TABLE totp_enrollments (
+        employee_id bigint PRIMARY KEY,
+        secret nvarchar(64) NOT NULL,
+        created_at datetimeoffset NOT NULL,
+        confirmed_at datetimeoffset,
+        last_used_step bigint NOT NULL
+    );
+END;
+
+IF OBJECT_ID(N'recovery_codes', N'U') IS NULL
+BEGIN
+    CREATE TABLE recovery_codes (
+        id nvarchar(32) PRIMARY KEY,
+        employee_id bigint NOT NULL,
+        code_hash nvarchar(64) NOT NULL,
+        used_at datetimeoffset
+    );
+    CREATE INDEX idx_recovery_codes_employee_id ON recovery_codes (employee_id);
+    CREATE INDEX idx_recovery_codes_code_hash ON recovery_codes (code_hash);
+END;
+
+IF OBJECT_ID(N'service_accounts', N'U') IS NULL
+BEGIN
+    CREATE TABLE service_accounts (
+        id bigint IDENTITY(1,1) PRIMARY KEY,
+        name nvarchar(255) NOT NULL,
+        created_at datetimeoffset NOT NULL
+    );
+    CREATE UNIQUE INDEX idx_service_accounts_name ON service_accounts (name);
+END;
+
+IF OBJECT_ID(N'api_keys', N'U') IS NULL
+BEGIN
+    CREATE TABLE api_keys (
+        id nvarchar(32) PRIMARY KEY,
+        service_account_id bigint NOT NULL,
+        name nvarchar(255) NOT NULL,
+        prefix nvarchar(16) NOT NULL,
+        key_hash nvarchar(64) NOT NULL,
+        scopes nvarchar(512) NOT NULL,
+        created_at datetimeoffset NOT NULL,
+        expires_at datetimeoffset,
+        last_used_at datetimeoffset
+    );
+    CREATE INDEX idx_api_keys_service_account_id ON api_keys (service_account_id);
+    CREATE UNIQUE INDEX idx_api_keys_key_hash ON api_keys (key_hash);
+END;
@@ -0,0 +1,13 @@
+-- Employees that share a username or email, or belong to a department that
+-- no longer exists, have to be fixed by hand before this migration applies.
+
+-- gorm created the string columns as nvarchar(max), which cannot be indexed
+ALTER TABLE employee_entities ALTER COLUMN username nvarchar(255) NOT NULL;
+ALTER TABLE employee_entities ALTER COLUMN email nvarchar(255) NOT NULL;
+
+CREATE UNIQUE INDEX idx_employee_entities_username ON employee_entities (username);
+CREATE UNIQUE INDEX idx_employee_entities_email ON employee_entities (email);
+
+ALTER TABLE employee_entities
+    ADD CONSTRAINT fk_employee_entities_department_id
+    FOREIGN KEY (department_id) REFERENCES department_entities (id);
@@ -0,0 +1,17 @@
+-- gorm created the string columns as nvarchar(max), which cannot be indexed
+ALTER TABLE department_entities ALTER COLUMN name nvarchar(255) NOT NULL;
+
+-- Concurrent saves could create a department twice. The employees of the
+-- copies move to the oldest one before the copies are deleted.
+UPDATE employee_entities SET department_id = (
+    SELECT MIN(oldest.id)
+    FROM department_entities department
+    JOIN department_entities oldest ON oldest.name = department.name
+    WHERE department.id = employee_entities.department_id
+)
+WHERE department_id NOT IN (SELECT MIN(id) FROM department_entities GROUP BY name);
+
+DELETE FROM department_entities
+WHERE id NOT IN (SELECT MIN(id) FROM department_entities GROUP BY name);
+
+CREATE UNIQUE INDEX idx_department_entities_name ON department_entities (name);
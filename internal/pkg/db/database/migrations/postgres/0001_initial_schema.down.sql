@@ -0,0 +1,12 @@
+DROP TABLE IF EXISTS api_keys;
+DROP TABLE IF EXISTS service_accounts;
+DROP TABLE IF EXISTS recovery_codes;
+DROP TABLE IF EXISTS totp_enrollments;
+DROP TABLE IF EXISTS login_failures;
+DROP TABLE IF EXISTS employee_token_revocations;
+DROP TABLE IF EXISTS revoked_tokens;
+DROP TABLE IF EXISTS password_reset_tokens;
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS refresh_tokens;
+DROP TABLE IF EXISTS employee_entities;
+DROP TABLE IF EXISTS department_entities;
@@ -0,0 +1,116 @@
+-- The schema as gorm's AutoMigrate created it, tables that already exist are
+-- adopted as they are.
+
+CREATE TABLE IF NOT EXISTS department_entities (
+    id bigserial PRIMARY KEY,
+    name varchar(255) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS employee_entities (
+    id bigserial PRIMARY KEY,
+    first_name varchar(255) NOT NULL,
+    last_name varchar(255) NOT NULL,
+    username varchar(255) NOT NULL,
+    password varchar(255) NOT NULL,
+    email varchar(255) NOT NULL,
+    dob varchar(64) NOT NULL,
+    department_id bigint NOT NULL,
+    position varchar(255) NOT NULL,
+    role varchar(32) NOT NULL DEFAULT 'employee'
+);
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id varchar(32) PRIMARY KEY,
+    family_id varchar(32) NOT NULL,
+    employee_id bigint NOT NULL,
+    token_hash varchar(64) NOT NULL,
+    created_at timestamptz NOT NULL,
+    expires_at timestamptz NOT NULL,
+    rotated_at timestamptz,
+    revoked_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family_id ON refresh_tokens (family_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_employee_id ON refresh_tokens (employee_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_refresh_tokens_token_hash ON refresh_tokens (token_hash);
+
+CREATE TABLE IF NOT EXISTS sessions (
+    id varchar(32) PRIMARY KEY,
+    employee_id bigint NOT NULL,
+    created_at timestamptz NOT NULL,
+    last_seen_at timestamptz NOT NULL,
+    expires_at timestamptz NOT NULL,
+    user_agent varchar(512) NOT NULL,
+    ip_address varchar(64) NOT NULL,
+    revoked_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_sessions_employee_id ON sessions (employee_id);
+
+CREATE TABLE IF NOT EXISTS password_reset_tokens (
+    id varchar(32) PRIMARY KEY,
+    employee_id bigint NOT NULL,
+    token_hash varchar(64) NOT NULL,
+    created_at timestamptz NOT NULL,
+    expires_at timestamptz NOT NULL,
+    used_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_employee_id ON password_reset_tokens (employee_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_password_reset_tokens_token_hash ON password_reset_tokens (token_hash);
+
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+    jti varchar(32) PRIMARY KEY,
+    expires_at timestamptz NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_revoked_tokens_expires_at ON revoked_tokens (expires_at);
+
+CREATE TABLE IF NOT EXISTS employee_token_revocations (
+    employee_id bigint PRIMARY KEY,
+    revoked_before timestamptz NOT NULL,
+    expires_at timestamptz NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_employee_token_revocations_expires_at ON employee_token_revocations (expires_at);
+
+CREATE TABLE IF NOT EXISTS login_failures (
+    username varchar(255) PRIMARY KEY,
+    failures integer NOT NULL,
+    last_failed_at timestamptz NOT NULL,
+    locked_until timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_login_failures_last_failed_at ON login_failures (last_failed_at);
+
+CREATE TABLE IF NOT EXISTS totp_enrollments (
+    employee_id bigint PRIMARY KEY,
+    secret varchar(64) NOT NULL,
+    created_at timestamptz NOT NULL,
+    confirmed_at timestamptz,
+    last_used_step bigint NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS recovery_codes (
+    id varchar(32) PRIMARY KEY,
+    employee_id bigint NOT NULL,
+    code_hash varchar(64) NOT NULL,
+    used_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_employee_id ON recovery_codes (employee_id);
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_code_hash ON recovery_codes (code_hash);
+
+CREATE TABLE IF NOT EXISTS service_accounts (
+    id bigserial PRIMARY KEY,
+    name varchar(255) NOT NULL,
+    created_at timestamptz NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_service_accounts_name ON service_accounts (name);
+
+CREATE TABLE IF NOT EXISTS api_keys (
+    id varchar(32) PRIMARY KEY,
+    service_account_id bigint NOT NULL,
+    name varchar(255) NOT NULL,
+    prefix varchar(16) NOT NULL,
+    key_hash varchar(64) NOT NULL,
+    scopes varchar(512) NOT NULL,
+    created_at timestamptz NOT NULL,
+    expires_at timestamptz,
+    last_used_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_api_keys_service_account_id ON api_keys (service_account_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_api_keys_key_hash ON api_keys (key_hash);
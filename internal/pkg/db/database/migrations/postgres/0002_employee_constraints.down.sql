@@ -0,0 +1,3 @@
+ALTER TABLE employee_entities DROP CONSTRAINT IF EXISTS fk_employee_entities_department_id;
+DROP INDEX IF EXISTS idx_employee_entities_email;
+DROP INDEX IF EXISTS idx_employee_entities_username;
@@ -0,0 +1,116 @@
+-- The schema as gorm's AutoMigrate created it, tables that already exist are
+-- adopted as they are.
+
+CREATE TABLE IF NOT EXISTS department_entities (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    name text NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS employee_entities (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    first_name text NOT NULL,
+    last_name text NOT NULL,
+    username text NOT NULL,
+    password text NOT NULL,
+    email text NOT NULL,
+    dob text NOT NULL,
+    department_id integer NOT NULL,
+    position text NOT NULL,
+    role text NOT NULL DEFAULT 'employee'
+);
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id text PRIMARY KEY,
+    family_id text NOT NULL,
+    employee_id integer NOT NULL,
+    token_hash text NOT NULL,
+    created_at datetime NOT NULL,
+    expires_at datetime NOT NULL,
+    rotated_at datetime,
+    revoked_at datetime
+);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family_id ON refresh_tokens (family_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_employee_id ON refresh_tokens (employee_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_refresh_tokens_token_hash ON refresh_tokens (token_hash);
+
+CREATE TABLE IF NOT EXISTS sessions (
+    id text PRIMARY KEY,
+    employee_id integer NOT NULL,
+    created_at datetime NOT NULL,
+    last_seen_at datetime NOT NULL,
+    expires_at datetime NOT NULL,
+    user_agent text NOT NULL,
+    ip_address text NOT NULL,
+    revoked_at datetime
+);
+CREATE INDEX IF NOT EXISTS idx_sessions_employee_id ON sessions (employee_id);
+
+CREATE TABLE IF NOT EXISTS password_reset_tokens (
+    id text PRIMARY KEY,
+    employee_id integer NOT NULL,
+    token_hash text NOT NULL,
+    created_at datetime NOT NULL,
+    expires_at datetime NOT NULL,
+    used_at datetime
+);
+CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_employee_id ON password_reset_tokens (employee_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_password_reset_tokens_token_hash ON password_reset_tokens (token_hash);
+
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+    jti text PRIMARY KEY,
+    expires_at datetime NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_revoked_tokens_expires_at ON revoked_tokens (expires_at);
+
+CREATE TABLE IF NOT EXISTS employee_token_revocations (
+    employee_id integer PRIMARY KEY,
+    revoked_before datetime NOT NULL,
+    expires_at datetime NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_employee_token_revocations_expires_at ON employee_token_revocations (expires_at);
+
+CREATE TABLE IF NOT EXISTS login_failures (
+    username text PRIMARY KEY,
+    failures integer NOT NULL,
+    last_failed_at datetime NOT NULL,
+    locked_until datetime
+);
+CREATE INDEX IF NOT EXISTS idx_login_failures_last_failed_at ON login_failures (last_failed_at);
+
+CREATE TABLE IF NOT EXISTS totp_enrollments (
+    employee_id integer PRIMARY KEY,
+    secret text NOT NULL,
+    created_at datetime NOT NULL,
+    confirmed_at datetime,
+    last_used_step integer NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS recovery_codes (
+    id text PRIMARY KEY,
+    employee_id integer NOT NULL,
+    code_hash text NOT NULL,
+    used_at datetime
+);
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_employee_id ON recovery_codes (employee_id);
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_code_hash ON recovery_codes (code_hash);
+
+CREATE TABLE IF NOT EXISTS service_accounts (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    name text NOT NULL,
+    created_at datetime NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_service_accounts_name ON service_accounts (name);
+
+CREATE TABLE IF NOT EXISTS api_keys (
+    id text PRIMARY KEY,
+    service_account_id integer NOT NULL,
+    name text NOT NULL,
+    prefix text NOT NULL,
+    key_hash text NOT NULL,
+    scopes text NOT NULL,
+    created_at datetime NOT NULL,
+    expires_at datetime,
+    last_used_at datetime
+);
+CREATE INDEX IF NOT EXISTS idx_api_keys_service_account_id ON api_keys (service_account_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_api_keys_key_hash ON api_keys (key_hash);
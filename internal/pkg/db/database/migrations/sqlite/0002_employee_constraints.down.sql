@@ -0,0 +1,21 @@
+DROP INDEX IF EXISTS idx_employee_entities_email;
+DROP INDEX IF EXISTS idx_employee_entities_username;
+
+-- the foreign key is dropped by copying employee_entities into a table
+-- without it
+CREATE TABLE employee_entities_new (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    first_name text NOT NULL,
+    last_name text NOT NULL,
+    username text NOT NULL,
+    password text NOT NULL,
+    email text NOT NULL,
+    dob text NOT NULL,
+    department_id integer NOT NULL,
+    position text NOT NULL,
+    role text NOT NULL DEFAULT 'employee'
+);
+INSERT INTO employee_entities_new (id, first_name, last_name, username, password, email, dob, department_id, position, role)
+SELECT id, first_name, last_name, username, password, email, dob, department_id, position, role FROM employee_entities;
+DROP TABLE employee_entities;
+ALTER TABLE employee_entities_new RENAME TO employee_entities;
@@ -0,0 +1,25 @@
+-- Employees that share a username or email, or belong to a department that
+-- no longer exists, have to be fixed by hand before this migration applies.
+
+-- SQLite cannot add a foreign key to an existing table, so employee_entities
+-- is copied into one that has it
+CREATE TABLE employee_entities_new (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    first_name text NOT NULL,
+    last_name text NOT NULL,
+    username text NOT NULL,
+    password text NOT NULL,
+    email text NOT NULL,
+    dob text NOT NULL,
+    department_id integer NOT NULL
+        CONSTRAINT fk_employee_entities_department_id REFERENCES department_entities (id),
+    position text NOT NULL,
+    role text NOT NULL DEFAULT 'employee'
+);
+INSERT INTO employee_entities_new (id, first_name, last_name, username, password, email, dob, department_id, position, role)
+SELECT id, first_name, last_name, username, password, email, dob, department_id, position, role FROM employee_entities;
+DROP TABLE employee_entities;
+ALTER TABLE employee_entities_new RENAME TO employee_entities;
+
+CREATE UNIQUE INDEX idx_employee_entities_username ON employee_entities (username);
+CREATE UNIQUE INDEX idx_employee_entities_email ON employee_entities (email);
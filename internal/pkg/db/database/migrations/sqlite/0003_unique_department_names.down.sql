@@ -0,0 +1,2 @@
+-- the departments merged by the up migration stay merged
+DROP INDEX IF EXISTS idx_department_entities_name;
@@ -0,0 +1,121 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// openPostgres opens a PostgreSQL database whose connections accept the
+// sql.Named arguments and @Name parameters the stores are written with
+func openPostgres(url string) *sql.DB {
+	return sql.OpenDB(namedConnector{url: url})
+}
+
+type namedConnector struct {
+	url string
+}
+
+func (c namedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	connector, err := stdlib.GetDefaultDriver().(driver.DriverContext).OpenConnector(c.url)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &namedConn{Conn: conn.(*stdlib.Conn)}, nil
+}
+
+func (c namedConnector) Driver() driver.Driver {
+	return stdlib.GetDefaultDriver()
+}
+
+// namedConn hands named arguments to pgx as pgx.NamedArgs, which rewrites the
+// @Name parameters of the query into positional ones. Queries with positional
+// arguments, or none, such as the migrations, are passed through.
+type namedConn struct {
+	*stdlib.Conn
+}
+
+func (c *namedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	args, err := namedArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	return c.Conn.ExecContext(ctx, query, args)
+}
+
+func (c *namedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	args, err := namedArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	return c.Conn.QueryContext(ctx, query, args)
+}
+
+// PrepareContext does not prepare on the server, the statement is sent with
+// its arguments each time so that they can be rewritten
+func (c *namedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	return &namedStmt{conn: c, query: query}, nil
+}
+
+func (c *namedConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+type namedStmt struct {
+	conn  *namedConn
+	query string
+}
+
+func (s *namedStmt) Close() error {
+	return nil
+}
+
+// NumInput is unknown, the arguments are checked by the server
+func (s *namedStmt) NumInput() int {
+	return -1
+}
+
+func (s *namedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.conn.ExecContext(ctx, s.query, args)
+}
+
+func (s *namedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.conn.QueryContext(ctx, s.query, args)
+}
+
+func (s *namedStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, errors.New("database: Exec without a context is not supported")
+}
+
+func (s *namedStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, errors.New("database: Query without a context is not supported")
+}
+
+// namedArgs collects named arguments into a single pgx.NamedArgs, arguments
+// cannot be both named and positional
+func namedArgs(args []driver.NamedValue) ([]driver.NamedValue, error) {
+	if len(args) == 0 || args[0].Name == "" {
+		for _, arg := range args {
+			if arg.Name != "" {
+				return nil, errors.New("database: cannot mix named and positional arguments")
+			}
+		}
+		return args, nil
+	}
+	named := make(pgx.NamedArgs, len(args))
+	for _, arg := range args {
+		if arg.Name == "" {
+			return nil, errors.New("database: cannot mix named and positional arguments")
+		}
+		named[arg.Name] = arg.Value
+	}
+	return []driver.NamedValue{{Ordinal: 1, Value: named}}, nil
+}
@@ -0,0 +1,7 @@
+//go:build cgo
+
+package database
+
+// sqliteBuilt tells whether the SQLite driver works in this binary, it is
+// written in C
+const sqliteBuilt = true
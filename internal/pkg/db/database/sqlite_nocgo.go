@@ -0,0 +1,7 @@
+//go:build !cgo
+
+package database
+
+// sqliteBuilt tells whether the SQLite driver works in this binary, without cgo
+// it is a stub failing every query
+const sqliteBuilt = false
@@ -1,54 +1,447 @@
 package jwt
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"log"
+	"os"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
 )
 
-// SecretKey is the secret key used to sign JWTs.
-// It should be kept private.
+// Supported signing algorithms
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+	ES256 = "ES256"
+)
+
+const (
+	defaultAccessTokenTTL = time.Minute * 15
+	// mfaTokenTTL is how long a user has to enter their second factor after their password
+	mfaTokenTTL = time.Minute * 5
+)
 
 var (
-	SecretKey = []byte("secret")
+	// ErrInvalidToken is returned for every token that fails to parse or validate
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrRotationUnsupported is returned when rotating a shared HS256 secret
+	ErrRotationUnsupported = errors.New("jwt: HS256 secrets cannot be rotated in process")
+	// ErrNoKeyDir is returned when rotating without a key directory to keep the new key in
+	ErrNoKeyDir = errors.New("jwt: rotating signing keys requires a key directory")
 )
 
-// GenerateToken generates a jwt token and assign a username to it's claims and return it
-func GenerateToken(username string) (string, error) {
-	token := jwt.New(jwt.SigningMethodHS256)
-	// Create a map to store our claims
-	claims := token.Claims.(jwt.MapClaims)
+// Config describes how tokens are signed and validated
+type Config struct {
+	// Algorithm is one of HS256, RS256 or ES256
+	Algorithm string
+	// Secret is the HMAC key used with HS256
+	Secret []byte
+	// PrivateKeyPath points at the PEM encoded RSA or EC private key used with RS256 and ES256
+	PrivateKeyPath string
+	// PreviousKeyPaths are keys that no longer sign but whose tokens are still accepted,
+	// so that a new PrivateKeyPath can be rolled out without logging everybody out
+	PreviousKeyPaths []string
+	// KeyDir is where Rotate stores the keys it generates. Every replica and
+	// restart reads the keys from it, so it must be shared by all of them.
+	KeyDir string
+	// Issuer and Audience are set as the iss and aud claims and enforced on parse when not empty
+	Issuer   string
+	Audience string
+	// AccessTTL defaults to 15 minutes, longer sessions are kept alive with refresh tokens
+	AccessTTL time.Duration
+	// ClockSkew is the leeway allowed when checking exp, nbf and iat
+	ClockSkew time.Duration
+}
+
+// Claims are the details carried by a token
+type Claims struct {
+	ID string
+	// EmployeeID is the subject of the token, Username is the username the
+	// employee had when it was issued
+	EmployeeID int64
+	Username   string
+	Role       string
+	// SessionID is the login the token was issued for, empty for tokens issued outside of one
+	SessionID string
+	// MFAPending marks tokens that only prove the password and must be exchanged
+	// together with a second factor for an access token
+	MFAPending bool
+	IssuedAt   time.Time
+	ExpiresAt  time.Time
+}
+
+// tokenClaims is the wire format of Claims
+type tokenClaims struct {
+	Username   string `json:"username"`
+	Role       string `json:"role,omitempty"`
+	SessionID  string `json:"sid,omitempty"`
+	MFAPending bool   `json:"mfa_pending,omitempty"`
+	// IssuedAtMicro is iat in microseconds, so that a token issued right after
+	// a revocation can be told apart from the ones it revoked
+	IssuedAtMicro int64 `json:"iat_us,omitempty"`
+	jwt.StandardClaims
+}
+
+// Issuer generates and parses the tokens of one signing configuration
+type Issuer struct {
+	cfg Config
+	now func() time.Time
+
+	// configured are the keys of Secret or PrivateKeyPath and PreviousKeyPaths
+	configured []*signingKey
+
+	mu sync.RWMutex
+	// keys[0] signs new tokens, the others only verify until they retire
+	keys []*signingKey
+	// generated caches the keys read from KeyDir by file name
+	generated map[string]*signingKey
+	// loadedAt is when KeyDir was last read
+	loadedAt time.Time
+	// activeSince is when keys[0] started signing, as far as this process knows
+	activeSince time.Time
+}
+
+// NewIssuer validates cfg and loads the signing keys it refers to
+func NewIssuer(cfg Config) (*Issuer, error) {
+	if cfg.AccessTTL == 0 {
+		cfg.AccessTTL = defaultAccessTokenTTL
+	}
+	if cfg.AccessTTL < 0 || cfg.ClockSkew < 0 {
+		return nil, errors.New("jwt: token lifetimes and clock skew cannot be negative")
+	}
+
+	issuer := &Issuer{cfg: cfg, now: time.Now}
+	switch cfg.Algorithm {
+	case HS256:
+		if len(cfg.Secret) == 0 {
+			return nil, errors.New("jwt: HS256 requires a secret")
+		}
+		if cfg.KeyDir != "" {
+			return nil, ErrRotationUnsupported
+		}
+		issuer.configured = []*signingKey{newHMACKey(cfg.Secret)}
+	case RS256, ES256:
+		key, err := loadKey(cfg.Algorithm, cfg.PrivateKeyPath)
+		if err != nil {
+			return nil, err
+		}
+		issuer.configured = []*signingKey{key}
+		for _, path := range cfg.PreviousKeyPaths {
+			previous, err := loadKey(cfg.Algorithm, path)
+			if err != nil {
+				return nil, err
+			}
+			issuer.configured = append(issuer.configured, previous)
+		}
+	default:
+		return nil, fmt.Errorf("jwt: unsupported algorithm %q", cfg.Algorithm)
+	}
+
+	issuer.keys = issuer.configured
+	issuer.activeSince = issuer.now()
+	if cfg.KeyDir != "" {
+		if err := os.MkdirAll(cfg.KeyDir, 0o700); err != nil {
+			return nil, fmt.Errorf("jwt: creating key directory: %w", err)
+		}
+		if err := issuer.loadKeyDir(); err != nil {
+			return nil, err
+		}
+	}
+	return issuer, nil
+}
+
+// Rotate replaces the signing key with a freshly generated one, stored in the key
+// directory, and returns its kid. The previous key keeps verifying until every
+// token it signed has expired.
+func (i *Issuer) Rotate() (string, error) {
+	if i.cfg.Algorithm == HS256 {
+		return "", ErrRotationUnsupported
+	}
+	if i.cfg.KeyDir == "" {
+		return "", ErrNoKeyDir
+	}
+	key, err := generateKey(i.cfg.Algorithm)
+	if err != nil {
+		return "", err
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if err := writeKeyFile(i.cfg.KeyDir, key, i.now()); err != nil {
+		return "", err
+	}
+	if err := i.loadKeyDir(); err != nil {
+		return "", err
+	}
+	return key.id, nil
+}
+
+// StartRotation rotates the signing key whenever it has been signing for
+// interval, until ctx is done. Every replica sharing the key directory can run
+// it, a key rotated by one of them is not rotated again by the others.
+func (i *Issuer) StartRotation(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(min(interval, keyDirReloadInterval))
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if !i.rotationDue(interval) {
+					continue
+				}
+				kid, err := i.Rotate()
+				if err != nil {
+					log.Printf("jwt: rotating signing key: %v", err)
+					continue
+				}
+				log.Printf("jwt: rotated signing key, new kid %s", kid)
+			}
+		}
+	}()
+}
+
+// rotationDue reports whether the signing key has been in use for interval
+func (i *Issuer) rotationDue(interval time.Duration) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.cfg.KeyDir != "" {
+		if err := i.loadKeyDir(); err != nil {
+			log.Printf("jwt: %v", err)
+			return false
+		}
+	}
+	return i.now().Sub(i.activeSince) >= interval
+}
+
+// JWKS returns the public keys tokens are currently verified with
+func (i *Issuer) JWKS() JSONWebKeySet {
+	i.reloadKeyDir(keyDirReloadInterval)
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	now := i.now()
+	set := JSONWebKeySet{Keys: []JSONWebKey{}}
+	for _, k := range i.keys {
+		if !k.retireAt.IsZero() && now.After(k.retireAt) {
+			continue
+		}
+		if jwk := k.jwk(); jwk != nil {
+			set.Keys = append(set.Keys, *jwk)
+		}
+	}
+	return set
+}
+
+// MaxTokenAge is how long a token signed right now may remain valid
+func (i *Issuer) MaxTokenAge() time.Duration {
+	return i.cfg.AccessTTL + i.cfg.ClockSkew
+}
+
+func (i *Issuer) activeKey() *signingKey {
+	i.reloadKeyDir(keyDirReloadInterval)
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.keys[0]
+}
+
+// verificationKey finds the key with id kid, tokens without a kid are checked
+// against the active key
+func (i *Issuer) verificationKey(kid string) (*signingKey, error) {
+	i.reloadKeyDir(keyDirReloadInterval)
+	key := i.lookupKey(kid)
+	if key == nil && i.cfg.KeyDir != "" {
+		// another replica may have rotated the key moments ago
+		i.reloadKeyDir(unknownKidReloadInterval)
+		key = i.lookupKey(kid)
+	}
+	if key == nil {
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+	if !key.retireAt.IsZero() && i.now().After(key.retireAt) {
+		return nil, fmt.Errorf("key %q has been retired", kid)
+	}
+	return key, nil
+}
+
+// lookupKey returns the key with id kid, or the active key when kid is empty
+func (i *Issuer) lookupKey(kid string) *signingKey {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	if kid == "" {
+		return i.keys[0]
+	}
+	for _, k := range i.keys {
+		if k.id == kid {
+			return k
+		}
+	}
+	return nil
+}
+
+// GenerateToken generates an access token for the employee employeeID, called
+// username, holding role
+func (i *Issuer) GenerateToken(employeeID int64, username, role string) (string, error) {
+	return i.GenerateSessionToken(employeeID, username, role, "")
+}
+
+// GenerateSessionToken generates an access token for the employee employeeID,
+// called username, holding role that belongs to the session sessionID
+func (i *Issuer) GenerateSessionToken(employeeID int64, username, role, sessionID string) (string, error) {
+	return i.generate(tokenClaims{Username: username, Role: role, SessionID: sessionID}, employeeID, i.cfg.AccessTTL)
+}
 
-	// check if username is empty
-	if username == "" {
+// GenerateMFAToken generates a short lived token stating that the employee
+// employeeID, called username, entered the right password but still has to
+// prove their second factor. It is not accepted as an access token.
+func (i *Issuer) GenerateMFAToken(employeeID int64, username string) (string, error) {
+	return i.generate(tokenClaims{Username: username, MFAPending: true}, employeeID, mfaTokenTTL)
+}
+
+// generate issues a token whose subject is employeeID rather than the username,
+// so that it cannot be used by whoever takes the username next
+func (i *Issuer) generate(claims tokenClaims, employeeID int64, ttl time.Duration) (string, error) {
+	if employeeID <= 0 {
+		return "", errors.New("'employeeID' must be positive")
+	}
+	if claims.Username == "" {
 		return "", errors.New("'username' cannot be empty")
 	}
-	// Set token claims
-	claims["username"] = username
-	claims["exp"] = time.Now().Add(time.Hour * 24).Unix()
-	tokenString, err := token.SignedString(SecretKey)
+	jti, err := newTokenID()
 	if err != nil {
-		log.Fatal("Error in Generating key")
 		return "", err
 	}
-	return tokenString, nil
+
+	now := i.now()
+	claims.IssuedAtMicro = now.UnixMicro()
+	claims.StandardClaims = jwt.StandardClaims{
+		Id:        jti,
+		Subject:   strconv.FormatInt(employeeID, 10),
+		Issuer:    i.cfg.Issuer,
+		Audience:  i.cfg.Audience,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(ttl).Unix(),
+	}
+	key := i.activeKey()
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.id
+	return token.SignedString(key.sign)
 }
 
-// ParseToken parses a jwt token and returns the username in it's claims
-func ParseToken(tokenStr string) (string, error) {
-	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-		return SecretKey, nil
+// ParseToken verifies the signature and claims of an access token and returns its claims.
+// Tokens signed with any algorithm other than the configured one are rejected.
+func (i *Issuer) ParseToken(tokenStr string) (*Claims, error) {
+	claims, err := i.parse(tokenStr)
+	if err != nil {
+		return nil, err
+	}
+	if claims.MFAPending {
+		return nil, fmt.Errorf("%w: second factor pending", ErrInvalidToken)
+	}
+	return claims, nil
+}
+
+// ParseMFAToken verifies a token generated by GenerateMFAToken
+func (i *Issuer) ParseMFAToken(tokenStr string) (*Claims, error) {
+	claims, err := i.parse(tokenStr)
+	if err != nil {
+		return nil, err
+	}
+	if !claims.MFAPending {
+		return nil, fmt.Errorf("%w: not a second factor token", ErrInvalidToken)
+	}
+	return claims, nil
+}
+
+func (i *Issuer) parse(tokenStr string) (*Claims, error) {
+	parser := jwt.Parser{
+		ValidMethods: []string{i.cfg.Algorithm},
+		// claims are validated below so that clock skew can be applied
+		SkipClaimsValidation: true,
+	}
+	var claims tokenClaims
+	_, err := parser.ParseWithClaims(tokenStr, &claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, err := i.verificationKey(kid)
+		if err != nil {
+			return nil, err
+		}
+		if token.Method.Alg() != key.method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %q", token.Method.Alg())
+		}
+		return key.verify, nil
 	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if err := i.validate(&claims); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	employeeID, _ := strconv.ParseInt(claims.Subject, 10, 64)
+	issuedAt := time.Unix(claims.IssuedAt, 0)
+	// tokens issued before iat_us was added only have iat
+	if claims.IssuedAtMicro != 0 {
+		issuedAt = time.UnixMicro(claims.IssuedAtMicro)
+	}
+	return &Claims{
+		ID:         claims.Id,
+		EmployeeID: employeeID,
+		Username:   claims.Username,
+		Role:       claims.Role,
+		SessionID:  claims.SessionID,
+		MFAPending: claims.MFAPending,
+		IssuedAt:   issuedAt,
+		ExpiresAt:  time.Unix(claims.ExpiresAt, 0),
+	}, nil
+}
 
-	if token == nil {
-		return "", errors.ErrUnsupported
+func (i *Issuer) validate(claims *tokenClaims) error {
+	now := i.now()
+	skew := i.cfg.ClockSkew
+
+	if id, err := strconv.ParseInt(claims.Subject, 10, 64); err != nil || id <= 0 {
+		return fmt.Errorf("unexpected subject %q", claims.Subject)
+	}
+	if claims.Username == "" {
+		return errors.New("token has no username")
+	}
+	if claims.ExpiresAt == 0 {
+		return errors.New("token has no expiry")
+	}
+	if now.After(time.Unix(claims.ExpiresAt, 0).Add(skew)) {
+		return errors.New("token is expired")
+	}
+	if claims.IssuedAt != 0 && now.Add(skew).Before(time.Unix(claims.IssuedAt, 0)) {
+		return errors.New("token used before issued")
+	}
+	if claims.IssuedAtMicro != 0 && time.UnixMicro(claims.IssuedAtMicro).Unix() != claims.IssuedAt {
+		return errors.New("iat_us does not match iat")
 	}
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		username := claims["username"].(string)
-		return username, nil
-	} else {
+	if claims.NotBefore != 0 && now.Add(skew).Before(time.Unix(claims.NotBefore, 0)) {
+		return errors.New("token is not valid yet")
+	}
+	if i.cfg.Issuer != "" && claims.Issuer != i.cfg.Issuer {
+		return fmt.Errorf("unexpected issuer %q", claims.Issuer)
+	}
+	if i.cfg.Audience != "" && claims.Audience != i.cfg.Audience {
+		return fmt.Errorf("unexpected audience %q", claims.Audience)
+	}
+	return nil
+}
+
+// newTokenID returns a random identifier for the jti claim
+func newTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
 		return "", err
 	}
+	return hex.EncodeToString(b), nil
 }
@@ -1,36 +1,364 @@
 package jwt
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/dgrijalva/jwt-go"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
+func newTestIssuer(t *testing.T, cfg Config) *Issuer {
+	if cfg.Algorithm == "" {
+		cfg.Algorithm = HS256
+		cfg.Secret = []byte("test-secret")
+	}
+	issuer, err := NewIssuer(cfg)
+	require.NoError(t, err)
+	return issuer
+}
+
+// writeKey stores key as a PEM file and returns its path
+func writeKey(t *testing.T, blockType string, der []byte) string {
+	path := filepath.Join(t.TempDir(), "key.pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600))
+	return path
+}
+
 func TestGenerateToken(t *testing.T) {
-	username := "testuser"
-	tokenString, err := GenerateToken(username)
+	issuer := newTestIssuer(t, Config{})
+	tokenString, err := issuer.GenerateToken(1, "testuser", "employee")
 	assert.NoError(t, err)
 	assert.NotEmpty(t, tokenString)
 }
 
 func TestParseToken(t *testing.T) {
-	username := "testuser"
-	tokenString, err := GenerateToken(username)
+	issuer := newTestIssuer(t, Config{})
+	tokenString, err := issuer.GenerateToken(1, "testuser", "hr")
 	assert.NoError(t, err)
-	username, err = ParseToken(tokenString)
+	claims, err := issuer.ParseToken(tokenString)
 	assert.NoError(t, err)
-	assert.Equal(t, username, "testuser")
+	assert.Equal(t, int64(1), claims.EmployeeID)
+	assert.Equal(t, claims.Username, "testuser")
+	assert.Equal(t, claims.Role, "hr")
+	assert.NotEmpty(t, claims.ID)
+	assert.WithinDuration(t, time.Now().Add(defaultAccessTokenTTL), claims.ExpiresAt, time.Minute)
 }
 
 func TestParseTokenInvalid(t *testing.T) {
+	issuer := newTestIssuer(t, Config{})
 	tokenString := "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9.eyJ1c2VybmFtZSI6InRlc3R1c2VyIiwiaWF0IjoxNjU1OTY1NDI1LCJleHAiOjE2NTU5NzI2MjV9.sdfsdfsdf"
-	username, err := ParseToken(tokenString)
+	claims, err := issuer.ParseToken(tokenString)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	assert.Nil(t, claims)
+}
+
+func TestErrorUsernameEmptyString(t *testing.T) {
+	issuer := newTestIssuer(t, Config{})
+	_, err := issuer.GenerateToken(1, "", "employee")
 	assert.Error(t, err)
-	assert.Empty(t, username)
 }
 
+func TestErrorEmployeeIDMissing(t *testing.T) {
+	issuer := newTestIssuer(t, Config{})
+	_, err := issuer.GenerateToken(0, "testuser", "employee")
+	assert.Error(t, err)
+}
 
-func TestErrorUsernameEmptyString(t *testing.T) {
-	_, err := GenerateToken("")
+func TestGenerateTokenLifetime(t *testing.T) {
+	issuer := newTestIssuer(t, Config{AccessTTL: time.Hour})
+	tokenString, err := issuer.GenerateToken(1, "testuser", "employee")
+	assert.NoError(t, err)
+	claims, err := issuer.ParseToken(tokenString)
+	assert.NoError(t, err)
+	assert.Equal(t, "testuser", claims.Username)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), claims.ExpiresAt, time.Minute)
+	assert.Empty(t, claims.SessionID)
+}
+
+func TestGenerateSessionToken(t *testing.T) {
+	issuer := newTestIssuer(t, Config{})
+	tokenString, err := issuer.GenerateSessionToken(1, "testuser", "employee", "session-1")
+	require.NoError(t, err)
+	claims, err := issuer.ParseToken(tokenString)
+	require.NoError(t, err)
+	assert.Equal(t, "session-1", claims.SessionID)
+}
+
+func TestMFAToken(t *testing.T) {
+	issuer := newTestIssuer(t, Config{})
+	mfaToken, err := issuer.GenerateMFAToken(1, "testuser")
+	require.NoError(t, err)
+
+	// it does not grant access on its own
+	_, err = issuer.ParseToken(mfaToken)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	claims, err := issuer.ParseMFAToken(mfaToken)
+	require.NoError(t, err)
+	assert.Equal(t, "testuser", claims.Username)
+	assert.True(t, claims.MFAPending)
+	assert.Equal(t, mfaTokenTTL, claims.ExpiresAt.Sub(claims.IssuedAt.Truncate(time.Second)))
+
+	// and access tokens cannot stand in for it
+	accessToken, err := issuer.GenerateToken(1, "testuser", "employee")
+	require.NoError(t, err)
+	_, err = issuer.ParseMFAToken(accessToken)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestNewIssuerInvalidConfig(t *testing.T) {
+	for name, cfg := range map[string]Config{
+		"unknown algorithm": {Algorithm: "none"},
+		"missing secret":    {Algorithm: HS256},
+		"missing key":       {Algorithm: RS256},
+		"unreadable key":    {Algorithm: ES256, PrivateKeyPath: "/does/not/exist.pem"},
+		"negative ttl":      {Algorithm: HS256, Secret: []byte("s"), AccessTTL: -time.Second},
+		"secret key dir":    {Algorithm: HS256, Secret: []byte("s"), KeyDir: "/tmp"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewIssuer(cfg)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestAsymmetricAlgorithms(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	ecDER, err := x509.MarshalECPrivateKey(ecKey)
+	require.NoError(t, err)
+
+	for _, cfg := range []Config{
+		{Algorithm: RS256, PrivateKeyPath: writeKey(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey))},
+		{Algorithm: ES256, PrivateKeyPath: writeKey(t, "EC PRIVATE KEY", ecDER)},
+	} {
+		t.Run(cfg.Algorithm, func(t *testing.T) {
+			issuer := newTestIssuer(t, cfg)
+			tokenString, err := issuer.GenerateToken(1, "testuser", "admin")
+			require.NoError(t, err)
+			claims, err := issuer.ParseToken(tokenString)
+			require.NoError(t, err)
+			assert.Equal(t, "admin", claims.Role)
+
+			// an HS256 token signed with the public key must not be accepted
+			hsIssuer := newTestIssuer(t, Config{Algorithm: HS256, Secret: []byte("test-secret")})
+			hsToken, err := hsIssuer.GenerateToken(1, "testuser", "admin")
+			require.NoError(t, err)
+			_, err = issuer.ParseToken(hsToken)
+			assert.ErrorIs(t, err, ErrInvalidToken)
+		})
+	}
+}
+
+func TestParseTokenRejectsOtherAlgorithms(t *testing.T) {
+	issuer := newTestIssuer(t, Config{})
+	claims := tokenClaims{StandardClaims: jwt.StandardClaims{Subject: "testuser", ExpiresAt: time.Now().Add(time.Hour).Unix()}}
+
+	unsigned, err := jwt.NewWithClaims(jwt.SigningMethodNone, claims).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	require.NoError(t, err)
+	_, err = issuer.ParseToken(unsigned)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	hs512, err := jwt.NewWithClaims(jwt.SigningMethodHS512, claims).SignedString([]byte("test-secret"))
+	require.NoError(t, err)
+	_, err = issuer.ParseToken(hs512)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestParseTokenClaims(t *testing.T) {
+	now := time.Now()
+	issuer := newTestIssuer(t, Config{Issuer: "ems", Audience: "ems-api", AccessTTL: time.Minute, ClockSkew: 30 * time.Second})
+	tokenString, err := issuer.GenerateToken(1, "testuser", "employee")
+	require.NoError(t, err)
+
+	// within the clock skew the token is still accepted
+	issuer.now = func() time.Time { return now.Add(time.Minute + 20*time.Second) }
+	_, err = issuer.ParseToken(tokenString)
+	assert.NoError(t, err)
+
+	issuer.now = func() time.Time { return now.Add(time.Minute + time.Minute) }
+	_, err = issuer.ParseToken(tokenString)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	// issued in the future beyond the skew
+	issuer.now = func() time.Time { return now.Add(-time.Minute) }
+	_, err = issuer.ParseToken(tokenString)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	// tokens for another issuer or audience are rejected
+	for _, cfg := range []Config{
+		{Issuer: "someone-else", Audience: "ems-api"},
+		{Issuer: "ems", Audience: "another-api"},
+	} {
+		other := newTestIssuer(t, cfg)
+		_, err = other.ParseToken(tokenString)
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	}
+}
+
+func TestIssuedAtPrecision(t *testing.T) {
+	issuer := newTestIssuer(t, Config{})
+	now := time.Date(2024, 5, 1, 12, 0, 0, 123456789, time.UTC)
+	issuer.now = func() time.Time { return now }
+	tokenString, err := issuer.GenerateToken(1, "testuser", "employee")
+	require.NoError(t, err)
+	claims, err := issuer.ParseToken(tokenString)
+	require.NoError(t, err)
+	assert.True(t, now.Truncate(time.Microsecond).Equal(claims.IssuedAt), "issued at %v", claims.IssuedAt)
+
+	// iat_us must agree with the iat other libraries read
+	forged := tokenClaims{Username: "testuser", IssuedAtMicro: now.Add(time.Hour).UnixMicro(), StandardClaims: jwt.StandardClaims{Subject: "1", IssuedAt: now.Unix(), ExpiresAt: now.Add(time.Hour).Unix()}}
+	forgedString, err := jwt.NewWithClaims(jwt.SigningMethodHS256, forged).SignedString([]byte("test-secret"))
+	require.NoError(t, err)
+	_, err = issuer.ParseToken(forgedString)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestRotate(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	ecDER, err := x509.MarshalECPrivateKey(ecKey)
+	require.NoError(t, err)
+	issuer := newTestIssuer(t, Config{Algorithm: ES256, PrivateKeyPath: writeKey(t, "EC PRIVATE KEY", ecDER), KeyDir: t.TempDir(), AccessTTL: time.Minute})
+
+	oldToken, err := issuer.GenerateToken(1, "testuser", "employee")
+	require.NoError(t, err)
+	oldKid := issuer.JWKS().Keys[0].KeyID
+
+	newKid, err := issuer.Rotate()
+	require.NoError(t, err)
+	require.NotEqual(t, oldKid, newKid)
+
+	// both keys are published and tokens of either are accepted
+	jwks := issuer.JWKS()
+	require.Len(t, jwks.Keys, 2)
+	assert.Equal(t, newKid, jwks.Keys[0].KeyID)
+	assert.Equal(t, oldKid, jwks.Keys[1].KeyID)
+	assert.Equal(t, "EC", jwks.Keys[0].KeyType)
+
+	newToken, err := issuer.GenerateToken(1, "testuser", "employee")
+	require.NoError(t, err)
+	parsed, _, err := new(jwt.Parser).ParseUnverified(newToken, &tokenClaims{})
+	require.NoError(t, err)
+	assert.Equal(t, newKid, parsed.Header["kid"])
+
+	_, err = issuer.ParseToken(oldToken)
+	assert.NoError(t, err)
+	_, err = issuer.ParseToken(newToken)
+	assert.NoError(t, err)
+
+	// once every token of the old key has expired the key is retired
+	now := time.Now()
+	issuer.now = func() time.Time { return now.Add(2 * time.Hour) }
+	require.Len(t, issuer.JWKS().Keys, 1)
+	_, err = issuer.verificationKey(oldKid)
+	assert.Error(t, err)
+}
+
+// TestRotateSharedKeyDir has two issuers share a key directory, as replicas or
+// a process and its restart do
+func TestRotateSharedKeyDir(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	cfg := Config{
+		Algorithm:      RS256,
+		PrivateKeyPath: writeKey(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey)),
+		KeyDir:         t.TempDir(),
+		AccessTTL:      time.Minute,
+	}
+	now := time.Now()
+	clock := func() time.Time { return now }
+	a := newTestIssuer(t, cfg)
+	b := newTestIssuer(t, cfg)
+	a.now, b.now = clock, clock
+
+	_, err = newTestIssuer(t, Config{Algorithm: RS256, PrivateKeyPath: cfg.PrivateKeyPath}).Rotate()
+	assert.ErrorIs(t, err, ErrNoKeyDir)
+
+	oldToken, err := a.GenerateToken(1, "testuser", "employee")
+	require.NoError(t, err)
+	kid, err := a.Rotate()
+	require.NoError(t, err)
+	newToken, err := a.GenerateToken(1, "testuser", "employee")
+	require.NoError(t, err)
+	require.False(t, b.rotationDue(time.Hour), "b sees the key a just rotated")
+
+	// b picks the new key up as soon as a token signed with it arrives
+	now = now.Add(2 * time.Second)
+	_, err = b.ParseToken(newToken)
+	assert.NoError(t, err)
+	_, err = b.ParseToken(oldToken)
+	assert.NoError(t, err)
+	bToken, err := b.GenerateToken(1, "testuser", "employee")
+	require.NoError(t, err)
+	parsed, _, err := new(jwt.Parser).ParseUnverified(bToken, &tokenClaims{})
+	require.NoError(t, err)
+	assert.Equal(t, kid, parsed.Header["kid"])
+
+	// a restart keeps both keys
+	restarted := newTestIssuer(t, cfg)
+	restarted.now = clock
+	_, err = restarted.ParseToken(oldToken)
+	assert.NoError(t, err)
+	_, err = restarted.ParseToken(newToken)
+	assert.NoError(t, err)
+	require.Len(t, restarted.JWKS().Keys, 2)
+
+	// rotating again retires the first generated key once its tokens expired
+	now = now.Add(time.Hour)
+	_, err = b.Rotate()
+	require.NoError(t, err)
+	now = now.Add(time.Hour)
+	_, err = a.Rotate()
+	require.NoError(t, err)
+	files, err := os.ReadDir(cfg.KeyDir)
+	require.NoError(t, err)
+	require.Len(t, files, 2, "the retired key file is deleted")
+	_, err = a.verificationKey(kid)
 	assert.Error(t, err)
-}
\ No newline at end of file
+}
+
+func TestHMACKeys(t *testing.T) {
+	issuer := newTestIssuer(t, Config{})
+	_, err := issuer.Rotate()
+	assert.ErrorIs(t, err, ErrRotationUnsupported)
+	// secrets are never published
+	assert.Empty(t, issuer.JWKS().Keys)
+
+	tokenString, err := issuer.GenerateToken(1, "testuser", "employee")
+	require.NoError(t, err)
+	token, _, err := new(jwt.Parser).ParseUnverified(tokenString, &tokenClaims{})
+	require.NoError(t, err)
+	assert.NotEmpty(t, token.Header["kid"])
+
+	// a token for an unknown key is rejected
+	claims := tokenClaims{StandardClaims: jwt.StandardClaims{Subject: "testuser", ExpiresAt: time.Now().Add(time.Hour).Unix()}}
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	forged.Header["kid"] = "unknown"
+	forgedString, err := forged.SignedString([]byte("test-secret"))
+	require.NoError(t, err)
+	_, err = issuer.ParseToken(forgedString)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestRSAThumbprint(t *testing.T) {
+	// example key from RFC 7638 section 3.1
+	key := &JSONWebKey{
+		KeyType: "RSA",
+		N:       "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+		E:       "AQAB",
+	}
+	assert.Equal(t, "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs", thumbprint(key))
+}
@@ -0,0 +1,175 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// keyDirReloadInterval is how often the key directory is read again to pick
+	// up keys rotated by other replicas. It must stay well below the access token
+	// lifetime, which is how long a replaced key keeps verifying.
+	keyDirReloadInterval = time.Minute
+	// unknownKidReloadInterval limits how often a token signed with an unknown
+	// key makes the key directory be read again
+	unknownKidReloadInterval = time.Second
+)
+
+// generatedKey is a key Rotate stored in the key directory
+type generatedKey struct {
+	file      string
+	createdAt time.Time
+	key       *signingKey
+}
+
+// writeKeyFile stores key in dir as <created unix time>-<kid>.pem. It is written
+// under a temporary name and renamed so that no replica reads it half written.
+func writeKeyFile(dir string, key *signingKey, createdAt time.Time) error {
+	var block *pem.Block
+	switch k := key.sign.(type) {
+	case *rsa.PrivateKey:
+		block = &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(k)}
+	case *ecdsa.PrivateKey:
+		der, err := x509.MarshalECPrivateKey(k)
+		if err != nil {
+			return fmt.Errorf("jwt: encoding EC private key: %w", err)
+		}
+		block = &pem.Block{Type: "EC PRIVATE KEY", Bytes: der}
+	default:
+		return errors.New("jwt: only RSA and EC keys are stored")
+	}
+
+	tmp, err := os.CreateTemp(dir, ".key-*")
+	if err != nil {
+		return fmt.Errorf("jwt: writing key: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if err := pem.Encode(tmp, block); err != nil {
+		tmp.Close()
+		return fmt.Errorf("jwt: writing key: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("jwt: writing key: %w", err)
+	}
+	name := fmt.Sprintf("%d-%s.pem", createdAt.Unix(), key.id)
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, name)); err != nil {
+		return fmt.Errorf("jwt: writing key: %w", err)
+	}
+	return nil
+}
+
+// readKeyDir loads the keys generated into dir, newest first. Keys found in
+// cache, by file name, are not parsed again.
+func readKeyDir(dir, algorithm string, cache map[string]*signingKey) ([]generatedKey, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("jwt: reading key directory: %w", err)
+	}
+	var keys []generatedKey
+	for _, entry := range entries {
+		name := entry.Name()
+		created, _, ok := strings.Cut(name, "-")
+		if entry.IsDir() || !ok || !strings.HasSuffix(name, ".pem") {
+			continue
+		}
+		unix, err := strconv.ParseInt(created, 10, 64)
+		if err != nil {
+			continue
+		}
+		key := cache[name]
+		if key == nil {
+			if key, err = loadKey(algorithm, filepath.Join(dir, name)); err != nil {
+				// pruned by another replica since the directory was listed
+				if errors.Is(err, os.ErrNotExist) {
+					continue
+				}
+				return nil, err
+			}
+		}
+		keys = append(keys, generatedKey{file: name, createdAt: time.Unix(unix, 0), key: key})
+	}
+	// names break ties so that every replica picks the same signing key
+	sort.Slice(keys, func(a, b int) bool {
+		if !keys[a].createdAt.Equal(keys[b].createdAt) {
+			return keys[a].createdAt.After(keys[b].createdAt)
+		}
+		return keys[a].file > keys[b].file
+	})
+	return keys, nil
+}
+
+// loadKeyDir rebuilds the key set from the configured keys and those in the key
+// directory, deleting the generated keys that have retired. The caller must
+// hold i.mu.
+func (i *Issuer) loadKeyDir() error {
+	now := i.now()
+	i.loadedAt = now
+	generated, err := readKeyDir(i.cfg.KeyDir, i.cfg.Algorithm, i.generated)
+	if err != nil {
+		return err
+	}
+
+	// a key retires once the tokens it signed before the next key took over have expired
+	keys := make([]*signingKey, 0, len(generated)+len(i.configured))
+	cache := make(map[string]*signingKey, len(generated))
+	for n, g := range generated {
+		key := *g.key
+		if n > 0 {
+			key.retireAt = generated[n-1].createdAt.Add(i.MaxTokenAge())
+		}
+		if !key.retireAt.IsZero() && now.After(key.retireAt) {
+			if err := os.Remove(filepath.Join(i.cfg.KeyDir, g.file)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				log.Printf("jwt: removing retired key %s: %v", g.file, err)
+			}
+			continue
+		}
+		cache[g.file] = g.key
+		keys = append(keys, &key)
+	}
+	primary := *i.configured[0]
+	if len(generated) > 0 {
+		primary.retireAt = generated[len(generated)-1].createdAt.Add(i.MaxTokenAge())
+		i.activeSince = generated[0].createdAt
+	}
+	keys = append(keys, &primary)
+	keys = append(keys, i.configured[1:]...)
+
+	i.keys = keys
+	i.generated = cache
+	return nil
+}
+
+// reloadKeyDir reads the key directory again when it was last read longer than
+// maxAge ago
+func (i *Issuer) reloadKeyDir(maxAge time.Duration) {
+	if i.cfg.KeyDir == "" {
+		return
+	}
+	i.mu.RLock()
+	fresh := i.now().Sub(i.loadedAt) < maxAge
+	i.mu.RUnlock()
+	if fresh {
+		return
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.now().Sub(i.loadedAt) < maxAge {
+		return
+	}
+	// the keys read before keep working until the directory can be read again
+	if err := i.loadKeyDir(); err != nil {
+		log.Printf("jwt: %v", err)
+	}
+}
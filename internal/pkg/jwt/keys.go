@@ -0,0 +1,175 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const rsaKeyBits = 2048
+
+// signingKey is one key of the issuer's key set
+type signingKey struct {
+	id     string
+	method jwt.SigningMethod
+	sign   interface{}
+	verify interface{}
+	// retireAt is when the key stops being accepted, zero while it is still in use
+	retireAt time.Time
+}
+
+// JSONWebKey is the public half of a signing key as published in a JWKS document
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+}
+
+// JSONWebKeySet is served from /.well-known/jwks.json
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// loadKey reads the PEM encoded private key at path for algorithm
+func loadKey(algorithm, path string) (*signingKey, error) {
+	if path == "" {
+		return nil, errors.New("jwt: a private key path is required")
+	}
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("jwt: reading private key: %w", err)
+	}
+
+	switch algorithm {
+	case RS256:
+		key, err := jwt.ParseRSAPrivateKeyFromPEM(pem)
+		if err != nil {
+			return nil, fmt.Errorf("jwt: parsing RSA private key: %w", err)
+		}
+		return newRSAKey(key), nil
+	case ES256:
+		key, err := jwt.ParseECPrivateKeyFromPEM(pem)
+		if err != nil {
+			return nil, fmt.Errorf("jwt: parsing EC private key: %w", err)
+		}
+		if key.Curve != elliptic.P256() {
+			return nil, errors.New("jwt: ES256 requires a P-256 key")
+		}
+		return newECKey(key), nil
+	default:
+		return nil, fmt.Errorf("jwt: %s does not use key files", algorithm)
+	}
+}
+
+// generateKey creates a fresh private key for algorithm
+func generateKey(algorithm string) (*signingKey, error) {
+	switch algorithm {
+	case RS256:
+		key, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+		if err != nil {
+			return nil, err
+		}
+		return newRSAKey(key), nil
+	case ES256:
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		return newECKey(key), nil
+	default:
+		return nil, fmt.Errorf("jwt: keys cannot be generated for %s", algorithm)
+	}
+}
+
+func newHMACKey(secret []byte) *signingKey {
+	sum := sha256.Sum256(secret)
+	return &signingKey{
+		// derived from the secret so every replica agrees on it without revealing it
+		id:     "hs-" + hex.EncodeToString(sum[:8]),
+		method: jwt.SigningMethodHS256,
+		sign:   secret,
+		verify: secret,
+	}
+}
+
+func newRSAKey(key *rsa.PrivateKey) *signingKey {
+	k := &signingKey{method: jwt.SigningMethodRS256, sign: key, verify: &key.PublicKey}
+	k.id = thumbprint(k.jwk())
+	return k
+}
+
+func newECKey(key *ecdsa.PrivateKey) *signingKey {
+	k := &signingKey{method: jwt.SigningMethodES256, sign: key, verify: &key.PublicKey}
+	k.id = thumbprint(k.jwk())
+	return k
+}
+
+// jwk returns the public JSON Web Key, nil for symmetric keys which must never be published
+func (k *signingKey) jwk() *JSONWebKey {
+	switch pub := k.verify.(type) {
+	case *rsa.PublicKey:
+		return &JSONWebKey{
+			KeyType:   "RSA",
+			Use:       "sig",
+			Algorithm: k.method.Alg(),
+			KeyID:     k.id,
+			N:         base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		return &JSONWebKey{
+			KeyType:   "EC",
+			Use:       "sig",
+			Algorithm: k.method.Alg(),
+			KeyID:     k.id,
+			Curve:     pub.Curve.Params().Name,
+			X:         base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, size))),
+			Y:         base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, size))),
+		}
+	default:
+		return nil
+	}
+}
+
+// thumbprint computes the RFC 7638 thumbprint of key, used as its kid
+func thumbprint(key *JSONWebKey) string {
+	var members interface{}
+	// the required members in lexicographic order
+	switch key.KeyType {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{key.E, key.KeyType, key.N}
+	case "EC":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{key.Curve, key.KeyType, key.X, key.Y}
+	}
+	raw, _ := json.Marshal(members)
+	sum := sha256.Sum256(raw)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,35 @@
+// Package secret generates random identifiers and bearer tokens and derives the
+// hashes they are stored under.
+package secret
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// ID returns a random 32 character hex identifier
+func ID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Token returns a random URL safe token holding 256 bits
+func Token() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Hash is how tokens are looked up without storing them. Tokens are random so
+// a fast unsalted hash is enough.
+func Hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
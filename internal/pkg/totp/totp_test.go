@@ -0,0 +1,66 @@
+package totp
+
+import (
+	"encoding/base32"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// the SHA1 test vectors of RFC 6238 appendix B, truncated to 6 digits
+func TestCode(t *testing.T) {
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+	for unix, want := range map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1111111111: "050471",
+		1234567890: "005924",
+		2000000000: "279037",
+	} {
+		got, err := Code(secret, time.Unix(unix, 0))
+		require.NoError(t, err)
+		assert.Equal(t, want, got, "at %d", unix)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	require.NoError(t, err)
+	now := time.Now()
+	code, err := Code(secret, now)
+	require.NoError(t, err)
+
+	step, ok := Validate(secret, code, now, 0)
+	require.True(t, ok)
+	assert.Equal(t, Step(now), step)
+
+	// a period of clock drift either way is tolerated
+	_, ok = Validate(secret, code, now.Add(Period), 0)
+	assert.True(t, ok)
+	_, ok = Validate(secret, code, now.Add(-Period), 0)
+	assert.True(t, ok)
+	_, ok = Validate(secret, code, now.Add(3*Period), 0)
+	assert.False(t, ok)
+
+	// a code cannot be used twice
+	_, ok = Validate(secret, code, now, step)
+	assert.False(t, ok)
+
+	_, ok = Validate(secret, "12345", now, 0)
+	assert.False(t, ok)
+	_, ok = Validate("not base32!", code, now, 0)
+	assert.False(t, ok)
+}
+
+func TestURI(t *testing.T) {
+	uri, err := url.Parse(URI("EMS", "pascal@example.com", "JBSWY3DPEHPK3PXP"))
+	require.NoError(t, err)
+	assert.Equal(t, "otpauth", uri.Scheme)
+	assert.Equal(t, "totp", uri.Host)
+	assert.Equal(t, "/EMS:pascal@example.com", uri.Path)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", uri.Query().Get("secret"))
+	assert.Equal(t, "EMS", uri.Query().Get("issuer"))
+}
@@ -0,0 +1,177 @@
+package revocation
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/pascaloseko/ems/internal/pkg/jwt"
+)
+
+// Denylist answers whether an access token has been revoked before it expired.
+// Revocations are written through to the Store and kept in memory, Sync picks up
+// the ones made by other replicas.
+type Denylist struct {
+	store Store
+	// maxTokenAge is how long a token issued now can stay valid, revoking every
+	// token of an employee only has to be remembered that long
+	maxTokenAge time.Duration
+	now         func() time.Time
+
+	mu        sync.RWMutex
+	tokens    map[string]time.Time
+	employees map[int64]EmployeeRevocation
+}
+
+func NewDenylist(store Store, maxTokenAge time.Duration) *Denylist {
+	return &Denylist{
+		store:       store,
+		maxTokenAge: maxTokenAge,
+		now:         time.Now,
+		tokens:      map[string]time.Time{},
+		employees:   map[int64]EmployeeRevocation{},
+	}
+}
+
+// IsRevoked reports whether the token described by claims, issued to employeeID,
+// has been revoked
+func (d *Denylist) IsRevoked(claims *jwt.Claims, employeeID int64) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if _, ok := d.tokens[claims.ID]; ok && claims.ID != "" {
+		return true
+	}
+	if _, ok := d.tokens[claims.SessionID]; ok && claims.SessionID != "" {
+		return true
+	}
+	if revocation, ok := d.employees[employeeID]; ok {
+		return !claims.IssuedAt.After(revocation.RevokedBefore)
+	}
+	return false
+}
+
+// RevokeToken revokes the token described by claims
+func (d *Denylist) RevokeToken(ctx context.Context, claims *jwt.Claims) error {
+	token := RevokedToken{ID: claims.ID, ExpiresAt: claims.ExpiresAt}
+	if err := d.store.RevokeToken(ctx, token); err != nil {
+		return err
+	}
+	d.mu.Lock()
+	d.tokens[token.ID] = token.ExpiresAt
+	d.mu.Unlock()
+	return nil
+}
+
+// RevokeSession revokes every token issued to the session sessionID up to now
+func (d *Denylist) RevokeSession(ctx context.Context, sessionID string) error {
+	token := RevokedToken{ID: sessionID, ExpiresAt: d.now().Add(d.maxTokenAge)}
+	if err := d.store.RevokeToken(ctx, token); err != nil {
+		return err
+	}
+	d.mu.Lock()
+	d.tokens[token.ID] = token.ExpiresAt
+	d.mu.Unlock()
+	return nil
+}
+
+// RevokeEmployee revokes every token issued to employeeID up to now
+func (d *Denylist) RevokeEmployee(ctx context.Context, employeeID int64) error {
+	// tokens carry their issue time in microseconds, rounding up keeps every
+	// token issued so far revoked without catching the ones issued after
+	now := d.now()
+	revokedBefore := now.Truncate(time.Microsecond)
+	if revokedBefore.Before(now) {
+		revokedBefore = revokedBefore.Add(time.Microsecond)
+	}
+	revocation := EmployeeRevocation{
+		EmployeeID:    employeeID,
+		RevokedBefore: revokedBefore,
+		// tokens issued before iat_us was added round their issue time down to the second
+		ExpiresAt: revokedBefore.Add(d.maxTokenAge + time.Second),
+	}
+	if err := d.store.RevokeEmployeeTokens(ctx, revocation); err != nil {
+		return err
+	}
+	d.mu.Lock()
+	d.employees[employeeID] = revocation
+	d.mu.Unlock()
+	return nil
+}
+
+// Sync replaces the in-memory entries with the ones in the Store
+func (d *Denylist) Sync(ctx context.Context) error {
+	now := d.now()
+	revokedTokens, err := d.store.GetRevokedTokens(ctx, now)
+	if err != nil {
+		return err
+	}
+	employeeRevocations, err := d.store.GetEmployeeRevocations(ctx, now)
+	if err != nil {
+		return err
+	}
+
+	tokens := make(map[string]time.Time, len(revokedTokens))
+	for _, token := range revokedTokens {
+		tokens[token.ID] = token.ExpiresAt
+	}
+	employees := make(map[int64]EmployeeRevocation, len(employeeRevocations))
+	for _, revocation := range employeeRevocations {
+		employees[revocation.EmployeeID] = revocation
+	}
+
+	d.mu.Lock()
+	d.tokens = tokens
+	d.employees = employees
+	d.mu.Unlock()
+	return nil
+}
+
+// Sweep deletes the entries whose tokens would have expired anyway
+func (d *Denylist) Sweep(ctx context.Context) (int64, error) {
+	now := d.now()
+	deleted, err := d.store.DeleteExpiredRevocations(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for id, expiresAt := range d.tokens {
+		if !now.Before(expiresAt) {
+			delete(d.tokens, id)
+		}
+	}
+	for id, revocation := range d.employees {
+		if !now.Before(revocation.ExpiresAt) {
+			delete(d.employees, id)
+		}
+	}
+	return deleted, nil
+}
+
+// StartSweeper sweeps and syncs the denylist every interval until ctx is done
+func (d *Denylist) StartSweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				deleted, err := d.Sweep(ctx)
+				if err != nil {
+					log.Printf("revocation: sweeping denylist: %v", err)
+					continue
+				}
+				if deleted > 0 {
+					log.Printf("revocation: pruned %d expired denylist entries", deleted)
+				}
+				if err := d.Sync(ctx); err != nil {
+					log.Printf("revocation: syncing denylist: %v", err)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,106 @@
+package revocation_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pascaloseko/ems/internal/mockdb"
+	"github.com/pascaloseko/ems/internal/pkg/jwt"
+	"github.com/pascaloseko/ems/internal/revocation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRevokeEmployee(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockRevocationStore(ctrl)
+	store.EXPECT().RevokeEmployeeTokens(gomock.Any(), gomock.Any()).Times(1).DoAndReturn(
+		func(_ context.Context, r revocation.EmployeeRevocation) error {
+			// kept until every token revoked by it has expired
+			assert.True(t, r.ExpiresAt.After(r.RevokedBefore.Add(15*time.Minute)))
+			return nil
+		})
+
+	denylist := revocation.NewDenylist(store, 15*time.Minute)
+	before := &jwt.Claims{ID: "a", IssuedAt: time.Now().Add(-time.Minute)}
+	require.False(t, denylist.IsRevoked(before, 1))
+
+	require.NoError(t, denylist.RevokeEmployee(context.Background(), 1))
+	assert.True(t, denylist.IsRevoked(before, 1))
+	assert.False(t, denylist.IsRevoked(before, 2))
+	after := &jwt.Claims{ID: "b", IssuedAt: time.Now().Add(time.Second)}
+	assert.False(t, denylist.IsRevoked(after, 1))
+}
+
+// TestRevokeEmployeeThenLogin issues tokens right around a revocation, within
+// the same second
+func TestRevokeEmployeeThenLogin(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockRevocationStore(ctrl)
+	store.EXPECT().RevokeEmployeeTokens(gomock.Any(), gomock.Any()).Times(1).Return(nil)
+	issuer, err := jwt.NewIssuer(jwt.Config{Algorithm: jwt.HS256, Secret: []byte("test-secret")})
+	require.NoError(t, err)
+	denylist := revocation.NewDenylist(store, issuer.MaxTokenAge())
+
+	issue := func() *jwt.Claims {
+		token, err := issuer.GenerateToken(1, "pascal", "employee")
+		require.NoError(t, err)
+		claims, err := issuer.ParseToken(token)
+		require.NoError(t, err)
+		return claims
+	}
+	before := issue()
+	require.NoError(t, denylist.RevokeEmployee(context.Background(), 1))
+	time.Sleep(2 * time.Microsecond)
+	after := issue()
+
+	assert.True(t, denylist.IsRevoked(before, 1))
+	assert.False(t, denylist.IsRevoked(after, 1))
+}
+
+func TestSyncAndSweep(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockRevocationStore(ctrl)
+
+	now := time.Now()
+	store.EXPECT().GetRevokedTokens(gomock.Any(), gomock.Any()).Times(1).Return([]revocation.RevokedToken{
+		{ID: "live", ExpiresAt: now.Add(time.Hour)},
+		{ID: "expired", ExpiresAt: now.Add(-time.Second)},
+	}, nil)
+	store.EXPECT().GetEmployeeRevocations(gomock.Any(), gomock.Any()).Times(1).Return([]revocation.EmployeeRevocation{
+		{EmployeeID: 1, RevokedBefore: now.Add(-time.Hour), ExpiresAt: now.Add(-time.Second)},
+	}, nil)
+	store.EXPECT().DeleteExpiredRevocations(gomock.Any(), gomock.Any()).Times(1).Return(int64(2), nil)
+
+	denylist := revocation.NewDenylist(store, 15*time.Minute)
+	require.NoError(t, denylist.Sync(context.Background()))
+	// revocations made by another replica are picked up
+	assert.True(t, denylist.IsRevoked(&jwt.Claims{ID: "live"}, 5))
+	assert.True(t, denylist.IsRevoked(&jwt.Claims{ID: "expired"}, 5))
+	assert.True(t, denylist.IsRevoked(&jwt.Claims{ID: "x", IssuedAt: now.Add(-2 * time.Hour)}, 1))
+
+	deleted, err := denylist.Sweep(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), deleted)
+	assert.True(t, denylist.IsRevoked(&jwt.Claims{ID: "live"}, 5))
+	assert.False(t, denylist.IsRevoked(&jwt.Claims{ID: "expired"}, 5))
+	assert.False(t, denylist.IsRevoked(&jwt.Claims{ID: "x", IssuedAt: now.Add(-2 * time.Hour)}, 1))
+}
+
+func TestRevokeSession(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockRevocationStore(ctrl)
+	store.EXPECT().RevokeToken(gomock.Any(), gomock.Any()).Times(1).Return(nil)
+
+	denylist := revocation.NewDenylist(store, 15*time.Minute)
+	require.NoError(t, denylist.RevokeSession(context.Background(), "session-1"))
+	assert.True(t, denylist.IsRevoked(&jwt.Claims{ID: "a", SessionID: "session-1"}, 1))
+	assert.False(t, denylist.IsRevoked(&jwt.Claims{ID: "b", SessionID: "session-2"}, 1))
+	assert.False(t, denylist.IsRevoked(&jwt.Claims{ID: "c"}, 1))
+}
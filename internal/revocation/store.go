@@ -0,0 +1,137 @@
+package revocation
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// RevokedToken is an access token that must no longer be accepted. ID is the jti
+// of a single token, or the sid of a session to revoke all of its tokens.
+type RevokedToken struct {
+	ID        string
+	ExpiresAt time.Time
+}
+
+// EmployeeRevocation invalidates every access token issued to an employee
+// at or before RevokedBefore
+type EmployeeRevocation struct {
+	EmployeeID    int64
+	RevokedBefore time.Time
+	ExpiresAt     time.Time
+}
+
+type Store interface {
+	RevokeToken(ctx context.Context, token RevokedToken) error
+	RevokeEmployeeTokens(ctx context.Context, revocation EmployeeRevocation) error
+	GetRevokedTokens(ctx context.Context, now time.Time) ([]RevokedToken, error)
+	GetEmployeeRevocations(ctx context.Context, now time.Time) ([]EmployeeRevocation, error)
+	DeleteExpiredRevocations(ctx context.Context, now time.Time) (int64, error)
+}
+
+type RevocationStore struct {
+	store *sql.DB
+}
+
+func NewRevocationStore(db *sql.DB) Store {
+	return &RevocationStore{
+		store: db,
+	}
+}
+
+// RevokeToken implements Store. Revoking the same token twice is not an error.
+func (s *RevocationStore) RevokeToken(ctx context.Context, token RevokedToken) error {
+	tsql := `
+	INSERT INTO Revoked_Tokens (Jti, Expires_At)
+	SELECT @Jti, @Expires_At
+	WHERE NOT EXISTS (SELECT 1 FROM Revoked_Tokens WHERE Jti = @Jti)
+	`
+	_, err := s.store.ExecContext(ctx, tsql, sql.Named("Jti", token.ID), sql.Named("Expires_At", token.ExpiresAt))
+	return err
+}
+
+// RevokeEmployeeTokens implements Store.
+func (s *RevocationStore) RevokeEmployeeTokens(ctx context.Context, revocation EmployeeRevocation) error {
+	result, err := s.store.ExecContext(
+		ctx,
+		"UPDATE Employee_Token_Revocations SET Revoked_Before = @Revoked_Before, Expires_At = @Expires_At WHERE Employee_Id = @Employee_Id",
+		sql.Named("Revoked_Before", revocation.RevokedBefore),
+		sql.Named("Expires_At", revocation.ExpiresAt),
+		sql.Named("Employee_Id", revocation.EmployeeID))
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected > 0 {
+		return nil
+	}
+
+	_, err = s.store.ExecContext(
+		ctx,
+		"INSERT INTO Employee_Token_Revocations (Employee_Id, Revoked_Before, Expires_At) VALUES (@Employee_Id, @Revoked_Before, @Expires_At)",
+		sql.Named("Employee_Id", revocation.EmployeeID),
+		sql.Named("Revoked_Before", revocation.RevokedBefore),
+		sql.Named("Expires_At", revocation.ExpiresAt))
+	return err
+}
+
+// GetRevokedTokens implements Store.
+func (s *RevocationStore) GetRevokedTokens(ctx context.Context, now time.Time) ([]RevokedToken, error) {
+	rows, err := s.store.QueryContext(ctx, "SELECT Jti, Expires_At FROM Revoked_Tokens WHERE Expires_At > @Now", sql.Named("Now", now))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []RevokedToken
+	for rows.Next() {
+		var token RevokedToken
+		if err := rows.Scan(&token.ID, &token.ExpiresAt); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+// GetEmployeeRevocations implements Store.
+func (s *RevocationStore) GetEmployeeRevocations(ctx context.Context, now time.Time) ([]EmployeeRevocation, error) {
+	rows, err := s.store.QueryContext(ctx, "SELECT Employee_Id, Revoked_Before, Expires_At FROM Employee_Token_Revocations WHERE Expires_At > @Now", sql.Named("Now", now))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revocations []EmployeeRevocation
+	for rows.Next() {
+		var revocation EmployeeRevocation
+		if err := rows.Scan(&revocation.EmployeeID, &revocation.RevokedBefore, &revocation.ExpiresAt); err != nil {
+			return nil, err
+		}
+		revocations = append(revocations, revocation)
+	}
+	return revocations, rows.Err()
+}
+
+// DeleteExpiredRevocations implements Store.
+func (s *RevocationStore) DeleteExpiredRevocations(ctx context.Context, now time.Time) (int64, error) {
+	var deleted int64
+	for _, tsql := range []string{
+		"DELETE FROM Revoked_Tokens WHERE Expires_At <= @Now",
+		"DELETE FROM Employee_Token_Revocations WHERE Expires_At <= @Now",
+	} {
+		result, err := s.store.ExecContext(ctx, tsql, sql.Named("Now", now))
+		if err != nil {
+			return deleted, err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return deleted, err
+		}
+		deleted += affected
+	}
+	return deleted, nil
+}
@@ -0,0 +1,50 @@
+package sessions
+
+import (
+	"context"
+	"net"
+	"net/http"
+)
+
+const maxUserAgentLength = 512
+
+var clientCtxKey = &contextKey{"client"}
+
+type contextKey struct {
+	name string
+}
+
+// Client describes where a request came from
+type Client struct {
+	IPAddress string
+	UserAgent string
+}
+
+// Middleware records the Client of every request so that sessions started or
+// used by it can show where they are
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), clientCtxKey, ClientFromRequest(r))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// ClientFromRequest reads the address and user agent of r. Put chi's RealIP
+// middleware in front when running behind a proxy.
+func ClientFromRequest(r *http.Request) Client {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	userAgent := r.UserAgent()
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+	return Client{IPAddress: ip, UserAgent: userAgent}
+}
+
+// ClientForContext returns the Client stored by Middleware, empty when it did not run
+func ClientForContext(ctx context.Context) Client {
+	client, _ := ctx.Value(clientCtxKey).(Client)
+	return client
+}
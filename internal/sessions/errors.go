@@ -0,0 +1,23 @@
+package sessions
+
+type InvalidRefreshTokenError struct{}
+
+func (m *InvalidRefreshTokenError) Error() string {
+	return "invalid refresh token"
+}
+
+// RefreshTokenReusedError is returned when a refresh token that was already rotated
+// is presented again, which means it has leaked. The session it belonged to has been ended.
+type RefreshTokenReusedError struct {
+	SessionID string
+}
+
+func (m *RefreshTokenReusedError) Error() string {
+	return "refresh token reuse detected, the session has been revoked"
+}
+
+type SessionNotFoundError struct{}
+
+func (m *SessionNotFoundError) Error() string {
+	return "session not found"
+}
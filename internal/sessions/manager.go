@@ -0,0 +1,204 @@
+package sessions
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/pascaloseko/ems/internal/pkg/secret"
+)
+
+const (
+	defaultRefreshTokenTTL = time.Hour * 24 * 7
+	// touchInterval limits how often the last seen time of a session is written
+	touchInterval = time.Minute
+	// maxTouched is how many sessions are remembered before stale ones are forgotten
+	maxTouched = 1024
+)
+
+// Manager tracks the sessions of employees and issues and rotates their opaque
+// refresh tokens. Only the SHA-256 of a token is stored.
+type Manager struct {
+	store Store
+	ttl   time.Duration
+	now   func() time.Time
+
+	mu      sync.Mutex
+	touched map[string]time.Time
+}
+
+func NewManager(store Store, ttl time.Duration) *Manager {
+	if ttl <= 0 {
+		ttl = defaultRefreshTokenTTL
+	}
+	return &Manager{
+		store:   store,
+		ttl:     ttl,
+		now:     time.Now,
+		touched: map[string]time.Time{},
+	}
+}
+
+// Issue starts a new session for employeeID, from the Client of ctx, and
+// returns its first refresh token
+func (m *Manager) Issue(ctx context.Context, employeeID int64) (Grant, error) {
+	sessionID, err := secret.ID()
+	if err != nil {
+		return Grant{}, err
+	}
+	client := ClientForContext(ctx)
+	now := m.now()
+	err = m.store.SaveSession(ctx, Session{
+		ID:         sessionID,
+		EmployeeID: employeeID,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(m.ttl),
+		UserAgent:  client.UserAgent,
+		IPAddress:  client.IPAddress,
+	})
+	if err != nil {
+		return Grant{}, err
+	}
+
+	token, err := m.issue(ctx, sessionID, employeeID)
+	if err != nil {
+		return Grant{}, err
+	}
+	return Grant{RefreshToken: token, SessionID: sessionID, EmployeeID: employeeID}, nil
+}
+
+// Rotate exchanges token for a new refresh token of the same session. Presenting
+// a token that was already rotated ends the session.
+func (m *Manager) Rotate(ctx context.Context, token string) (Grant, error) {
+	current, err := m.store.GetRefreshTokenByHash(ctx, secret.Hash(token))
+	if err != nil {
+		return Grant{}, err
+	}
+	now := m.now()
+	if current.RevokedAt != nil || !now.Before(current.ExpiresAt) {
+		return Grant{}, &InvalidRefreshTokenError{}
+	}
+	if current.RotatedAt != nil {
+		return Grant{}, m.reused(ctx, current)
+	}
+
+	rotated, err := m.store.MarkRefreshTokenRotated(ctx, current.ID, now)
+	if err != nil {
+		return Grant{}, err
+	}
+	// somebody else rotated it between our read and write
+	if !rotated {
+		return Grant{}, m.reused(ctx, current)
+	}
+
+	next, err := m.issue(ctx, current.FamilyID, current.EmployeeID)
+	if err != nil {
+		return Grant{}, err
+	}
+	if err := m.store.ExtendSession(ctx, current.FamilyID, now.Add(m.ttl)); err != nil {
+		return Grant{}, err
+	}
+	m.Touch(ctx, current.FamilyID)
+	return Grant{RefreshToken: next, SessionID: current.FamilyID, EmployeeID: current.EmployeeID}, nil
+}
+
+// Touch records that the session was used just now by the Client of ctx.
+// Writes are throttled to one per session every touchInterval and failures are
+// only logged, so it is safe to call on every request.
+func (m *Manager) Touch(ctx context.Context, sessionID string) {
+	now := m.now()
+	m.mu.Lock()
+	if last, ok := m.touched[sessionID]; ok && now.Sub(last) < touchInterval {
+		m.mu.Unlock()
+		return
+	}
+	if len(m.touched) >= maxTouched {
+		for id, last := range m.touched {
+			if now.Sub(last) >= touchInterval {
+				delete(m.touched, id)
+			}
+		}
+	}
+	m.touched[sessionID] = now
+	m.mu.Unlock()
+
+	if err := m.store.TouchSession(ctx, sessionID, now, ClientForContext(ctx)); err != nil {
+		log.Printf("sessions: touching session %s: %v", sessionID, err)
+	}
+}
+
+// Get returns the session with id
+func (m *Manager) Get(ctx context.Context, id string) (Session, error) {
+	return m.store.GetSession(ctx, id)
+}
+
+// Sessions returns the sessions of employeeID that have not ended, most recently used first
+func (m *Manager) Sessions(ctx context.Context, employeeID int64) ([]Session, error) {
+	return m.store.GetEmployeeSessions(ctx, employeeID, m.now())
+}
+
+// Terminate ends the session with id and revokes its refresh tokens. Access tokens
+// already issued to it have to be revoked separately.
+func (m *Manager) Terminate(ctx context.Context, id string) error {
+	now := m.now()
+	if err := m.store.RevokeRefreshTokenFamily(ctx, id, now); err != nil {
+		return err
+	}
+	return m.store.RevokeSession(ctx, id, now)
+}
+
+// Revoke ends the session of token, which must belong to employeeID, and returns its id
+func (m *Manager) Revoke(ctx context.Context, token string, employeeID int64) (string, error) {
+	current, err := m.store.GetRefreshTokenByHash(ctx, secret.Hash(token))
+	if err != nil {
+		return "", err
+	}
+	if current.EmployeeID != employeeID {
+		return "", &InvalidRefreshTokenError{}
+	}
+	return current.FamilyID, m.Terminate(ctx, current.FamilyID)
+}
+
+// RevokeAll ends every session of employeeID
+func (m *Manager) RevokeAll(ctx context.Context, employeeID int64) error {
+	now := m.now()
+	if err := m.store.RevokeEmployeeRefreshTokens(ctx, employeeID, now); err != nil {
+		return err
+	}
+	return m.store.RevokeEmployeeSessions(ctx, employeeID, now)
+}
+
+func (m *Manager) reused(ctx context.Context, token RefreshToken) error {
+	log.Printf("refresh token reuse detected for employee %d, ending session %s", token.EmployeeID, token.FamilyID)
+	if err := m.Terminate(ctx, token.FamilyID); err != nil {
+		return err
+	}
+	return &RefreshTokenReusedError{SessionID: token.FamilyID}
+}
+
+func (m *Manager) issue(ctx context.Context, familyID string, employeeID int64) (string, error) {
+	id, err := secret.ID()
+	if err != nil {
+		return "", err
+	}
+	token, err := secret.Token()
+	if err != nil {
+		return "", err
+	}
+
+	now := m.now()
+	err = m.store.SaveRefreshToken(ctx, RefreshToken{
+		ID:         id,
+		FamilyID:   familyID,
+		EmployeeID: employeeID,
+		TokenHash:  secret.Hash(token),
+		CreatedAt:  now,
+		ExpiresAt:  now.Add(m.ttl),
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
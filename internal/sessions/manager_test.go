@@ -0,0 +1,209 @@
+package sessions_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pascaloseko/ems/internal/mockdb"
+	"github.com/pascaloseko/ems/internal/sessions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestIssue(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockSessionStore(ctrl)
+
+	var saved sessions.RefreshToken
+	store.EXPECT().SaveRefreshToken(gomock.Any(), gomock.Any()).Times(2).DoAndReturn(
+		func(_ context.Context, token sessions.RefreshToken) error {
+			saved = token
+			return nil
+		})
+	var session sessions.Session
+	store.EXPECT().SaveSession(gomock.Any(), gomock.Any()).Times(2).DoAndReturn(
+		func(_ context.Context, s sessions.Session) error {
+			session = s
+			return nil
+		})
+
+	manager := sessions.NewManager(store, time.Hour)
+	req := httptest.NewRequest(http.MethodPost, "/query", nil)
+	req.Header.Set("User-Agent", "test-agent")
+	var ctx context.Context
+	sessions.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx = r.Context()
+	})).ServeHTTP(httptest.NewRecorder(), req)
+
+	grant, err := manager.Issue(ctx, 7)
+	require.NoError(t, err)
+	first := saved
+
+	// the token itself is never stored
+	assert.Equal(t, hash(grant.RefreshToken), first.TokenHash)
+	assert.NotContains(t, first.TokenHash, grant.RefreshToken)
+	assert.Equal(t, int64(7), first.EmployeeID)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), first.ExpiresAt, time.Minute)
+
+	// the refresh token family is the session
+	assert.Equal(t, grant.SessionID, first.FamilyID)
+	assert.Equal(t, grant.SessionID, session.ID)
+	assert.Equal(t, int64(7), session.EmployeeID)
+	assert.Equal(t, "test-agent", session.UserAgent)
+	assert.Equal(t, "192.0.2.1", session.IPAddress)
+
+	// every login starts a new session
+	other, err := manager.Issue(context.Background(), 7)
+	require.NoError(t, err)
+	assert.NotEqual(t, grant.RefreshToken, other.RefreshToken)
+	assert.NotEqual(t, first.FamilyID, saved.FamilyID)
+}
+
+func TestTouchIsThrottled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockSessionStore(ctrl)
+	store.EXPECT().TouchSession(gomock.Any(), "session-1", gomock.Any(), gomock.Any()).Times(1).Return(nil)
+	store.EXPECT().TouchSession(gomock.Any(), "session-2", gomock.Any(), gomock.Any()).Times(1).Return(nil)
+
+	manager := sessions.NewManager(store, time.Hour)
+	for i := 0; i < 10; i++ {
+		manager.Touch(context.Background(), "session-1")
+	}
+	manager.Touch(context.Background(), "session-2")
+}
+
+func TestRotate(t *testing.T) {
+	current := sessions.RefreshToken{
+		ID:         "token-1",
+		FamilyID:   "family-1",
+		EmployeeID: 7,
+		TokenHash:  hash("current"),
+		CreatedAt:  time.Now().Add(-time.Minute),
+		ExpiresAt:  time.Now().Add(time.Hour),
+	}
+	past := time.Now().Add(-time.Second)
+
+	testCases := []struct {
+		name       string
+		token      func() sessions.RefreshToken
+		buildStubs func(store *mockdb.MockSessionStore)
+		check      func(t *testing.T, grant sessions.Grant, err error)
+	}{
+		{
+			name:  "OK",
+			token: func() sessions.RefreshToken { return current },
+			buildStubs: func(store *mockdb.MockSessionStore) {
+				store.EXPECT().MarkRefreshTokenRotated(gomock.Any(), "token-1", gomock.Any()).Times(1).Return(true, nil)
+				store.EXPECT().SaveRefreshToken(gomock.Any(), gomock.Any()).Times(1).DoAndReturn(
+					func(_ context.Context, token sessions.RefreshToken) error {
+						assert.Equal(t, "family-1", token.FamilyID)
+						assert.Equal(t, int64(7), token.EmployeeID)
+						return nil
+					})
+				store.EXPECT().ExtendSession(gomock.Any(), "family-1", gomock.Any()).Times(1).Return(nil)
+				store.EXPECT().TouchSession(gomock.Any(), "family-1", gomock.Any(), gomock.Any()).Times(1).Return(nil)
+			},
+			check: func(t *testing.T, grant sessions.Grant, err error) {
+				require.NoError(t, err)
+				assert.NotEmpty(t, grant.RefreshToken)
+				assert.NotEqual(t, "current", grant.RefreshToken)
+				assert.Equal(t, int64(7), grant.EmployeeID)
+				assert.Equal(t, "family-1", grant.SessionID)
+			},
+		},
+		{
+			name: "Reused",
+			token: func() sessions.RefreshToken {
+				token := current
+				token.RotatedAt = &past
+				return token
+			},
+			buildStubs: func(store *mockdb.MockSessionStore) {
+				store.EXPECT().RevokeRefreshTokenFamily(gomock.Any(), "family-1", gomock.Any()).Times(1).Return(nil)
+				store.EXPECT().RevokeSession(gomock.Any(), "family-1", gomock.Any()).Times(1).Return(nil)
+				store.EXPECT().SaveRefreshToken(gomock.Any(), gomock.Any()).Times(0)
+			},
+			check: func(t *testing.T, grant sessions.Grant, err error) {
+				assert.Equal(t, &sessions.RefreshTokenReusedError{SessionID: "family-1"}, err)
+				assert.Empty(t, grant.RefreshToken)
+			},
+		},
+		{
+			name:  "ConcurrentRotation",
+			token: func() sessions.RefreshToken { return current },
+			buildStubs: func(store *mockdb.MockSessionStore) {
+				store.EXPECT().MarkRefreshTokenRotated(gomock.Any(), "token-1", gomock.Any()).Times(1).Return(false, nil)
+				store.EXPECT().RevokeRefreshTokenFamily(gomock.Any(), "family-1", gomock.Any()).Times(1).Return(nil)
+				store.EXPECT().RevokeSession(gomock.Any(), "family-1", gomock.Any()).Times(1).Return(nil)
+				store.EXPECT().SaveRefreshToken(gomock.Any(), gomock.Any()).Times(0)
+			},
+			check: func(t *testing.T, grant sessions.Grant, err error) {
+				assert.IsType(t, &sessions.RefreshTokenReusedError{}, err)
+			},
+		},
+		{
+			name: "Revoked",
+			token: func() sessions.RefreshToken {
+				token := current
+				token.RevokedAt = &past
+				return token
+			},
+			buildStubs: func(store *mockdb.MockSessionStore) {
+				store.EXPECT().MarkRefreshTokenRotated(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			check: func(t *testing.T, grant sessions.Grant, err error) {
+				assert.IsType(t, &sessions.InvalidRefreshTokenError{}, err)
+			},
+		},
+		{
+			name: "Expired",
+			token: func() sessions.RefreshToken {
+				token := current
+				token.ExpiresAt = past
+				return token
+			},
+			buildStubs: func(store *mockdb.MockSessionStore) {
+				store.EXPECT().MarkRefreshTokenRotated(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			check: func(t *testing.T, grant sessions.Grant, err error) {
+				assert.IsType(t, &sessions.InvalidRefreshTokenError{}, err)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockSessionStore(ctrl)
+			store.EXPECT().GetRefreshTokenByHash(gomock.Any(), hash("current")).Times(1).Return(tc.token(), nil)
+			tc.buildStubs(store)
+
+			grant, err := sessions.NewManager(store, time.Hour).Rotate(context.Background(), "current")
+			tc.check(t, grant, err)
+		})
+	}
+}
+
+func TestRotateUnknownToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockSessionStore(ctrl)
+	store.EXPECT().GetRefreshTokenByHash(gomock.Any(), hash("unknown")).Times(1).Return(sessions.RefreshToken{}, &sessions.InvalidRefreshTokenError{})
+
+	_, err := sessions.NewManager(store, time.Hour).Rotate(context.Background(), "unknown")
+	assert.IsType(t, &sessions.InvalidRefreshTokenError{}, err)
+}
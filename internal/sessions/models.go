@@ -0,0 +1,35 @@
+package sessions
+
+import "time"
+
+// RefreshToken is a single use token, every rotation issues a new token in the same family
+type RefreshToken struct {
+	ID         string
+	FamilyID   string
+	EmployeeID int64
+	TokenHash  string
+	CreatedAt  time.Time
+	ExpiresAt  time.Time
+	RotatedAt  *time.Time
+	RevokedAt  *time.Time
+}
+
+// Session is one login of an employee. Its ID is the family of the refresh
+// tokens issued by that login and the sid claim of its access tokens.
+type Session struct {
+	ID         string
+	EmployeeID int64
+	CreatedAt  time.Time
+	LastSeenAt time.Time
+	ExpiresAt  time.Time
+	UserAgent  string
+	IPAddress  string
+	RevokedAt  *time.Time
+}
+
+// Grant is the outcome of issuing or rotating a refresh token
+type Grant struct {
+	RefreshToken string
+	SessionID    string
+	EmployeeID   int64
+}
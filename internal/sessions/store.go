@@ -0,0 +1,206 @@
+package sessions
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type Store interface {
+	SaveRefreshToken(ctx context.Context, token RefreshToken) error
+	GetRefreshTokenByHash(ctx context.Context, hash string) (RefreshToken, error)
+	MarkRefreshTokenRotated(ctx context.Context, id string, at time.Time) (bool, error)
+	RevokeRefreshTokenFamily(ctx context.Context, familyID string, at time.Time) error
+	RevokeEmployeeRefreshTokens(ctx context.Context, employeeID int64, at time.Time) error
+
+	SaveSession(ctx context.Context, session Session) error
+	GetSession(ctx context.Context, id string) (Session, error)
+	GetEmployeeSessions(ctx context.Context, employeeID int64, now time.Time) ([]Session, error)
+	TouchSession(ctx context.Context, id string, at time.Time, client Client) error
+	ExtendSession(ctx context.Context, id string, expiresAt time.Time) error
+	RevokeSession(ctx context.Context, id string, at time.Time) error
+	RevokeEmployeeSessions(ctx context.Context, employeeID int64, at time.Time) error
+}
+
+type SessionStore struct {
+	store *sql.DB
+}
+
+func NewSessionStore(db *sql.DB) Store {
+	return &SessionStore{
+		store: db,
+	}
+}
+
+// SaveRefreshToken implements Store.
+func (s *SessionStore) SaveRefreshToken(ctx context.Context, t RefreshToken) error {
+	tsql := `
+	INSERT INTO Refresh_Tokens (ID, Family_Id, Employee_Id, Token_Hash, Created_At, Expires_At)
+	VALUES (@ID, @Family_Id, @Employee_Id, @Token_Hash, @Created_At, @Expires_At)
+	`
+	_, err := s.store.ExecContext(
+		ctx,
+		tsql,
+		sql.Named("ID", t.ID),
+		sql.Named("Family_Id", t.FamilyID),
+		sql.Named("Employee_Id", t.EmployeeID),
+		sql.Named("Token_Hash", t.TokenHash),
+		sql.Named("Created_At", t.CreatedAt),
+		sql.Named("Expires_At", t.ExpiresAt))
+	return err
+}
+
+// GetRefreshTokenByHash implements Store.
+func (s *SessionStore) GetRefreshTokenByHash(ctx context.Context, hash string) (RefreshToken, error) {
+	tsql := `
+	SELECT ID, Family_Id, Employee_Id, Token_Hash, Created_At, Expires_At, Rotated_At, Revoked_At
+	FROM Refresh_Tokens WHERE Token_Hash = @Token_Hash
+	`
+	row := s.store.QueryRowContext(ctx, tsql, sql.Named("Token_Hash", hash))
+	var t RefreshToken
+	var rotatedAt, revokedAt sql.NullTime
+	err := row.Scan(&t.ID, &t.FamilyID, &t.EmployeeID, &t.TokenHash, &t.CreatedAt, &t.ExpiresAt, &rotatedAt, &revokedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return RefreshToken{}, &InvalidRefreshTokenError{}
+		}
+		return RefreshToken{}, err
+	}
+	t.RotatedAt = nullTime(rotatedAt)
+	t.RevokedAt = nullTime(revokedAt)
+	return t, nil
+}
+
+// MarkRefreshTokenRotated implements Store. It reports false when the token had
+// already been rotated, so that only one of two concurrent refreshes wins.
+func (s *SessionStore) MarkRefreshTokenRotated(ctx context.Context, id string, at time.Time) (bool, error) {
+	result, err := s.store.ExecContext(ctx, "UPDATE Refresh_Tokens SET Rotated_At = @At WHERE ID = @ID AND Rotated_At IS NULL", sql.Named("At", at), sql.Named("ID", id))
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+// RevokeRefreshTokenFamily implements Store.
+func (s *SessionStore) RevokeRefreshTokenFamily(ctx context.Context, familyID string, at time.Time) error {
+	_, err := s.store.ExecContext(ctx, "UPDATE Refresh_Tokens SET Revoked_At = @At WHERE Family_Id = @Family_Id AND Revoked_At IS NULL", sql.Named("At", at), sql.Named("Family_Id", familyID))
+	return err
+}
+
+// RevokeEmployeeRefreshTokens implements Store.
+func (s *SessionStore) RevokeEmployeeRefreshTokens(ctx context.Context, employeeID int64, at time.Time) error {
+	_, err := s.store.ExecContext(ctx, "UPDATE Refresh_Tokens SET Revoked_At = @At WHERE Employee_Id = @Employee_Id AND Revoked_At IS NULL", sql.Named("At", at), sql.Named("Employee_Id", employeeID))
+	return err
+}
+
+// SaveSession implements Store.
+func (s *SessionStore) SaveSession(ctx context.Context, session Session) error {
+	tsql := `
+	INSERT INTO Sessions (ID, Employee_Id, Created_At, Last_Seen_At, Expires_At, User_Agent, Ip_Address)
+	VALUES (@ID, @Employee_Id, @Created_At, @Last_Seen_At, @Expires_At, @User_Agent, @Ip_Address)
+	`
+	_, err := s.store.ExecContext(
+		ctx,
+		tsql,
+		sql.Named("ID", session.ID),
+		sql.Named("Employee_Id", session.EmployeeID),
+		sql.Named("Created_At", session.CreatedAt),
+		sql.Named("Last_Seen_At", session.LastSeenAt),
+		sql.Named("Expires_At", session.ExpiresAt),
+		sql.Named("User_Agent", session.UserAgent),
+		sql.Named("Ip_Address", session.IPAddress))
+	return err
+}
+
+const sessionColumns = "ID, Employee_Id, Created_At, Last_Seen_At, Expires_At, User_Agent, Ip_Address, Revoked_At"
+
+// GetSession implements Store.
+func (s *SessionStore) GetSession(ctx context.Context, id string) (Session, error) {
+	row := s.store.QueryRowContext(ctx, "SELECT "+sessionColumns+" FROM Sessions WHERE ID = @ID", sql.Named("ID", id))
+	session, err := scanSession(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Session{}, &SessionNotFoundError{}
+	}
+	return session, err
+}
+
+// GetEmployeeSessions implements Store. Only sessions that can still be used are returned.
+func (s *SessionStore) GetEmployeeSessions(ctx context.Context, employeeID int64, now time.Time) ([]Session, error) {
+	tsql := "SELECT " + sessionColumns + `
+	FROM Sessions
+	WHERE Employee_Id = @Employee_Id AND Revoked_At IS NULL AND Expires_At > @Now
+	ORDER BY Last_Seen_At DESC
+	`
+	rows, err := s.store.QueryContext(ctx, tsql, sql.Named("Employee_Id", employeeID), sql.Named("Now", now))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []Session
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// TouchSession implements Store.
+func (s *SessionStore) TouchSession(ctx context.Context, id string, at time.Time, client Client) error {
+	_, err := s.store.ExecContext(
+		ctx,
+		"UPDATE Sessions SET Last_Seen_At = @At, User_Agent = @User_Agent, Ip_Address = @Ip_Address WHERE ID = @ID",
+		sql.Named("At", at),
+		sql.Named("User_Agent", client.UserAgent),
+		sql.Named("Ip_Address", client.IPAddress),
+		sql.Named("ID", id))
+	return err
+}
+
+// ExtendSession implements Store.
+func (s *SessionStore) ExtendSession(ctx context.Context, id string, expiresAt time.Time) error {
+	_, err := s.store.ExecContext(ctx, "UPDATE Sessions SET Expires_At = @Expires_At WHERE ID = @ID", sql.Named("Expires_At", expiresAt), sql.Named("ID", id))
+	return err
+}
+
+// RevokeSession implements Store.
+func (s *SessionStore) RevokeSession(ctx context.Context, id string, at time.Time) error {
+	_, err := s.store.ExecContext(ctx, "UPDATE Sessions SET Revoked_At = @At WHERE ID = @ID AND Revoked_At IS NULL", sql.Named("At", at), sql.Named("ID", id))
+	return err
+}
+
+// RevokeEmployeeSessions implements Store.
+func (s *SessionStore) RevokeEmployeeSessions(ctx context.Context, employeeID int64, at time.Time) error {
+	_, err := s.store.ExecContext(ctx, "UPDATE Sessions SET Revoked_At = @At WHERE Employee_Id = @Employee_Id AND Revoked_At IS NULL", sql.Named("At", at), sql.Named("Employee_Id", employeeID))
+	return err
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSession(row scanner) (Session, error) {
+	var session Session
+	var revokedAt sql.NullTime
+	err := row.Scan(&session.ID, &session.EmployeeID, &session.CreatedAt, &session.LastSeenAt, &session.ExpiresAt, &session.UserAgent, &session.IPAddress, &revokedAt)
+	if err != nil {
+		return Session{}, err
+	}
+	session.RevokedAt = nullTime(revokedAt)
+	return session, nil
+}
+
+func nullTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
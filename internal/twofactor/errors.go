@@ -0,0 +1,21 @@
+package twofactor
+
+type NotEnrolledError struct{}
+
+func (m *NotEnrolledError) Error() string {
+	return "two-factor authentication is not enabled"
+}
+
+// AlreadyEnrolledError is returned when enrolling while two-factor
+// authentication is enabled, it has to be disabled first
+type AlreadyEnrolledError struct{}
+
+func (m *AlreadyEnrolledError) Error() string {
+	return "two-factor authentication is already enabled"
+}
+
+type InvalidCodeError struct{}
+
+func (m *InvalidCodeError) Error() string {
+	return "invalid two-factor authentication code"
+}
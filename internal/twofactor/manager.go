@@ -0,0 +1,182 @@
+// Package twofactor adds authenticator app codes as a second factor to logins.
+package twofactor
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/pascaloseko/ems/internal/employees"
+	"github.com/pascaloseko/ems/internal/pkg/secret"
+	"github.com/pascaloseko/ems/internal/pkg/totp"
+)
+
+const (
+	defaultIssuer = "EMS"
+	// recoveryCodes is how many recovery codes an enrollment comes with
+	recoveryCodes = 10
+)
+
+var recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Setup is what an employee needs to add EMS to their authenticator app. The
+// recovery codes are not stored and cannot be shown again.
+type Setup struct {
+	URI           string
+	Secret        string
+	RecoveryCodes []string
+}
+
+// Manager enrolls employees in TOTP and verifies their codes
+type Manager struct {
+	store  Store
+	issuer string
+	now    func() time.Time
+}
+
+// NewManager returns a Manager that names itself issuer in authenticator apps
+func NewManager(store Store, issuer string) *Manager {
+	if issuer == "" {
+		issuer = defaultIssuer
+	}
+	return &Manager{
+		store:  store,
+		issuer: issuer,
+		now:    time.Now,
+	}
+}
+
+// Enroll generates a new secret and recovery codes for employee. They are only
+// used once the enrollment is confirmed with a code, enrolling again before
+// that replaces them.
+func (m *Manager) Enroll(ctx context.Context, employee employees.Employee) (Setup, error) {
+	enabled, err := m.Enabled(ctx, employee.ID)
+	if err != nil {
+		return Setup{}, err
+	}
+	if enabled {
+		return Setup{}, &AlreadyEnrolledError{}
+	}
+
+	key, err := totp.GenerateSecret()
+	if err != nil {
+		return Setup{}, err
+	}
+	setup := Setup{
+		URI:    totp.URI(m.issuer, employee.Username, key),
+		Secret: key,
+	}
+	codes := make([]RecoveryCode, 0, recoveryCodes)
+	for i := 0; i < recoveryCodes; i++ {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return Setup{}, err
+		}
+		id, err := secret.ID()
+		if err != nil {
+			return Setup{}, err
+		}
+		setup.RecoveryCodes = append(setup.RecoveryCodes, code)
+		codes = append(codes, RecoveryCode{ID: id, EmployeeID: employee.ID, CodeHash: hashRecoveryCode(code)})
+	}
+
+	enrollment := Enrollment{EmployeeID: employee.ID, Secret: key, CreatedAt: m.now()}
+	if err := m.store.SaveEnrollment(ctx, enrollment, codes); err != nil {
+		return Setup{}, err
+	}
+	return setup, nil
+}
+
+// Confirm enables two-factor authentication for employeeID once code shows
+// that their authenticator app was set up
+func (m *Manager) Confirm(ctx context.Context, employeeID int64, code string) error {
+	enrollment, err := m.store.GetEnrollment(ctx, employeeID)
+	if err != nil {
+		return err
+	}
+	if enrollment.ConfirmedAt != nil {
+		return &AlreadyEnrolledError{}
+	}
+	if err := m.useCode(ctx, enrollment, code); err != nil {
+		return err
+	}
+	return m.store.ConfirmEnrollment(ctx, employeeID, m.now())
+}
+
+// Enabled reports whether logins of employeeID need a second factor
+func (m *Manager) Enabled(ctx context.Context, employeeID int64) (bool, error) {
+	enrollment, err := m.store.GetEnrollment(ctx, employeeID)
+	if err != nil {
+		var notEnrolled *NotEnrolledError
+		if errors.As(err, &notEnrolled) {
+			return false, nil
+		}
+		return false, err
+	}
+	return enrollment.ConfirmedAt != nil, nil
+}
+
+// Verify accepts a code from the authenticator app of employeeID or one of
+// their recovery codes. Either can only be used once.
+func (m *Manager) Verify(ctx context.Context, employeeID int64, code string) error {
+	enrollment, err := m.store.GetEnrollment(ctx, employeeID)
+	if err != nil {
+		return err
+	}
+	if enrollment.ConfirmedAt == nil {
+		return &NotEnrolledError{}
+	}
+	if len(code) == totp.Digits {
+		return m.useCode(ctx, enrollment, code)
+	}
+	used, err := m.store.UseRecoveryCode(ctx, employeeID, hashRecoveryCode(code), m.now())
+	if err != nil {
+		return err
+	}
+	if !used {
+		return &InvalidCodeError{}
+	}
+	return nil
+}
+
+// Disable turns two-factor authentication off for employeeID and forgets their
+// secret and recovery codes
+func (m *Manager) Disable(ctx context.Context, employeeID int64) error {
+	return m.store.DeleteEnrollment(ctx, employeeID)
+}
+
+func (m *Manager) useCode(ctx context.Context, enrollment Enrollment, code string) error {
+	step, ok := totp.Validate(enrollment.Secret, code, m.now(), enrollment.LastUsedStep)
+	if !ok {
+		return &InvalidCodeError{}
+	}
+	// the step is claimed atomically so that concurrent logins cannot share a code
+	used, err := m.store.UseStep(ctx, enrollment.EmployeeID, step)
+	if err != nil {
+		return err
+	}
+	if !used {
+		return &InvalidCodeError{}
+	}
+	return nil
+}
+
+// newRecoveryCode returns a random code formatted as xxxxx-xxxxx
+func newRecoveryCode() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := strings.ToLower(recoveryEncoding.EncodeToString(b))[:10]
+	return code[:5] + "-" + code[5:], nil
+}
+
+// hashRecoveryCode hashes code ignoring case, spaces and dashes so that it can
+// be typed the way it was written down
+func hashRecoveryCode(code string) string {
+	normalized := strings.NewReplacer("-", "", " ", "").Replace(strings.ToLower(code))
+	return secret.Hash(normalized)
+}
@@ -0,0 +1,154 @@
+package twofactor_test
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pascaloseko/ems/internal/employees"
+	"github.com/pascaloseko/ems/internal/mockdb"
+	"github.com/pascaloseko/ems/internal/pkg/secret"
+	"github.com/pascaloseko/ems/internal/pkg/totp"
+	"github.com/pascaloseko/ems/internal/twofactor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnrollAndConfirm(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockTwoFactorStore(ctrl)
+	manager := twofactor.NewManager(store, "")
+	ctx := context.Background()
+
+	var saved twofactor.Enrollment
+	var savedCodes []twofactor.RecoveryCode
+	store.EXPECT().GetEnrollment(gomock.Any(), int64(7)).Times(1).Return(twofactor.Enrollment{}, &twofactor.NotEnrolledError{})
+	store.EXPECT().SaveEnrollment(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).DoAndReturn(
+		func(_ context.Context, enrollment twofactor.Enrollment, codes []twofactor.RecoveryCode) error {
+			saved = enrollment
+			savedCodes = codes
+			return nil
+		})
+	setup, err := manager.Enroll(ctx, employees.Employee{ID: 7, Username: "pascal"})
+	require.NoError(t, err)
+
+	uri, err := url.Parse(setup.URI)
+	require.NoError(t, err)
+	assert.Equal(t, "/EMS:pascal", uri.Path)
+	assert.Equal(t, saved.Secret, uri.Query().Get("secret"))
+	assert.Nil(t, saved.ConfirmedAt)
+	require.Len(t, setup.RecoveryCodes, 10)
+	require.Len(t, savedCodes, 10)
+	for i, code := range setup.RecoveryCodes {
+		assert.Regexp(t, `^[a-z2-7]{5}-[a-z2-7]{5}$`, code)
+		assert.NotEqual(t, code, savedCodes[i].CodeHash)
+	}
+
+	// a wrong code does not confirm
+	store.EXPECT().GetEnrollment(gomock.Any(), int64(7)).AnyTimes().Return(saved, nil)
+	store.EXPECT().ConfirmEnrollment(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+	code, err := totp.Code(saved.Secret, time.Now())
+	require.NoError(t, err)
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+	assert.IsType(t, &twofactor.InvalidCodeError{}, manager.Confirm(ctx, 7, wrong))
+
+	store.EXPECT().UseStep(gomock.Any(), int64(7), gomock.Any()).Times(1).Return(true, nil)
+	store.EXPECT().ConfirmEnrollment(gomock.Any(), int64(7), gomock.Any()).Times(1).Return(nil)
+	require.NoError(t, manager.Confirm(ctx, 7, code))
+}
+
+func TestEnrollWhenEnabled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockTwoFactorStore(ctrl)
+
+	confirmed := time.Now()
+	store.EXPECT().GetEnrollment(gomock.Any(), int64(7)).Times(1).Return(twofactor.Enrollment{EmployeeID: 7, ConfirmedAt: &confirmed}, nil)
+	store.EXPECT().SaveEnrollment(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+	_, err := twofactor.NewManager(store, "EMS").Enroll(context.Background(), employees.Employee{ID: 7, Username: "pascal"})
+	assert.IsType(t, &twofactor.AlreadyEnrolledError{}, err)
+}
+
+func TestVerify(t *testing.T) {
+	key, err := totp.GenerateSecret()
+	require.NoError(t, err)
+	code, err := totp.Code(key, time.Now())
+	require.NoError(t, err)
+	confirmed := time.Now()
+	enrollment := twofactor.Enrollment{EmployeeID: 7, Secret: key, ConfirmedAt: &confirmed}
+
+	testCases := []struct {
+		name       string
+		enrollment twofactor.Enrollment
+		code       string
+		buildStubs func(store *mockdb.MockTwoFactorStore)
+		wantErr    error
+	}{
+		{
+			name:       "Code",
+			enrollment: enrollment,
+			code:       code,
+			buildStubs: func(store *mockdb.MockTwoFactorStore) {
+				store.EXPECT().UseStep(gomock.Any(), int64(7), gomock.Any()).Times(1).Return(true, nil)
+			},
+		},
+		{
+			name:       "CodeReplayed",
+			enrollment: enrollment,
+			code:       code,
+			buildStubs: func(store *mockdb.MockTwoFactorStore) {
+				store.EXPECT().UseStep(gomock.Any(), int64(7), gomock.Any()).Times(1).Return(false, nil)
+			},
+			wantErr: &twofactor.InvalidCodeError{},
+		},
+		{
+			name:       "RecoveryCode",
+			enrollment: enrollment,
+			code:       "ABCDE-fghij",
+			buildStubs: func(store *mockdb.MockTwoFactorStore) {
+				store.EXPECT().UseRecoveryCode(gomock.Any(), int64(7), secret.Hash("abcdefghij"), gomock.Any()).Times(1).Return(true, nil)
+			},
+		},
+		{
+			name:       "RecoveryCodeUsed",
+			enrollment: enrollment,
+			code:       "abcde-fghij",
+			buildStubs: func(store *mockdb.MockTwoFactorStore) {
+				store.EXPECT().UseRecoveryCode(gomock.Any(), int64(7), gomock.Any(), gomock.Any()).Times(1).Return(false, nil)
+			},
+			wantErr: &twofactor.InvalidCodeError{},
+		},
+		{
+			name:       "NotConfirmed",
+			enrollment: twofactor.Enrollment{EmployeeID: 7, Secret: key},
+			code:       code,
+			buildStubs: func(store *mockdb.MockTwoFactorStore) {
+				store.EXPECT().UseStep(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			wantErr: &twofactor.NotEnrolledError{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockTwoFactorStore(ctrl)
+			store.EXPECT().GetEnrollment(gomock.Any(), int64(7)).Times(1).Return(tc.enrollment, nil)
+			tc.buildStubs(store)
+
+			err := twofactor.NewManager(store, "EMS").Verify(context.Background(), 7, tc.code)
+			if tc.wantErr != nil {
+				assert.IsType(t, tc.wantErr, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package twofactor
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Enrollment is the authenticator app secret of an employee. It is only asked
+// for at login once ConfirmedAt is set.
+type Enrollment struct {
+	EmployeeID  int64
+	Secret      string
+	CreatedAt   time.Time
+	ConfirmedAt *time.Time
+	// LastUsedStep is the TOTP period of the last accepted code, codes up to it
+	// are rejected so that they cannot be replayed
+	LastUsedStep int64
+}
+
+// RecoveryCode is a single use code that replaces the authenticator app, only
+// its hash is stored
+type RecoveryCode struct {
+	ID         string
+	EmployeeID int64
+	CodeHash   string
+	UsedAt     *time.Time
+}
+
+type Store interface {
+	// SaveEnrollment replaces the enrollment and recovery codes of the employee
+	SaveEnrollment(ctx context.Context, enrollment Enrollment, codes []RecoveryCode) error
+	GetEnrollment(ctx context.Context, employeeID int64) (Enrollment, error)
+	ConfirmEnrollment(ctx context.Context, employeeID int64, at time.Time) error
+	// UseStep records step as the last used TOTP period, it reports false when
+	// a code of the same or a later period was already used
+	UseStep(ctx context.Context, employeeID int64, step int64) (bool, error)
+	// UseRecoveryCode marks the unused code with hash as used, it reports false
+	// when there is none
+	UseRecoveryCode(ctx context.Context, employeeID int64, hash string, at time.Time) (bool, error)
+	DeleteEnrollment(ctx context.Context, employeeID int64) error
+}
+
+type TwoFactorStore struct {
+	store *sql.DB
+}
+
+func NewTwoFactorStore(db *sql.DB) Store {
+	return &TwoFactorStore{
+		store: db,
+	}
+}
+
+// SaveEnrollment implements Store.
+func (s *TwoFactorStore) SaveEnrollment(ctx context.Context, enrollment Enrollment, codes []RecoveryCode) error {
+	tx, err := s.store.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, tsql := range []string{
+		"DELETE FROM Recovery_Codes WHERE Employee_Id = @Employee_Id",
+		"DELETE FROM Totp_Enrollments WHERE Employee_Id = @Employee_Id",
+	} {
+		if _, err := tx.ExecContext(ctx, tsql, sql.Named("Employee_Id", enrollment.EmployeeID)); err != nil {
+			return err
+		}
+	}
+	tsql := `
+	INSERT INTO Totp_Enrollments (Employee_Id, Secret, Created_At, Last_Used_Step)
+	VALUES (@Employee_Id, @Secret, @Created_At, 0)
+	`
+	_, err = tx.ExecContext(
+		ctx,
+		tsql,
+		sql.Named("Employee_Id", enrollment.EmployeeID),
+		sql.Named("Secret", enrollment.Secret),
+		sql.Named("Created_At", enrollment.CreatedAt))
+	if err != nil {
+		return err
+	}
+	for _, code := range codes {
+		_, err := tx.ExecContext(
+			ctx,
+			"INSERT INTO Recovery_Codes (ID, Employee_Id, Code_Hash) VALUES (@ID, @Employee_Id, @Code_Hash)",
+			sql.Named("ID", code.ID),
+			sql.Named("Employee_Id", code.EmployeeID),
+			sql.Named("Code_Hash", code.CodeHash))
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetEnrollment implements Store.
+func (s *TwoFactorStore) GetEnrollment(ctx context.Context, employeeID int64) (Enrollment, error) {
+	tsql := `
+	SELECT Employee_Id, Secret, Created_At, Confirmed_At, Last_Used_Step
+	FROM Totp_Enrollments WHERE Employee_Id = @Employee_Id
+	`
+	row := s.store.QueryRowContext(ctx, tsql, sql.Named("Employee_Id", employeeID))
+	var e Enrollment
+	var confirmedAt sql.NullTime
+	err := row.Scan(&e.EmployeeID, &e.Secret, &e.CreatedAt, &confirmedAt, &e.LastUsedStep)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Enrollment{}, &NotEnrolledError{}
+		}
+		return Enrollment{}, err
+	}
+	if confirmedAt.Valid {
+		e.ConfirmedAt = &confirmedAt.Time
+	}
+	return e, nil
+}
+
+// ConfirmEnrollment implements Store.
+func (s *TwoFactorStore) ConfirmEnrollment(ctx context.Context, employeeID int64, at time.Time) error {
+	_, err := s.store.ExecContext(ctx, "UPDATE Totp_Enrollments SET Confirmed_At = @At WHERE Employee_Id = @Employee_Id", sql.Named("At", at), sql.Named("Employee_Id", employeeID))
+	return err
+}
+
+// UseStep implements Store.
+func (s *TwoFactorStore) UseStep(ctx context.Context, employeeID int64, step int64) (bool, error) {
+	result, err := s.store.ExecContext(
+		ctx,
+		"UPDATE Totp_Enrollments SET Last_Used_Step = @Step WHERE Employee_Id = @Employee_Id AND Last_Used_Step < @Step",
+		sql.Named("Step", step),
+		sql.Named("Employee_Id", employeeID))
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// UseRecoveryCode implements Store.
+func (s *TwoFactorStore) UseRecoveryCode(ctx context.Context, employeeID int64, hash string, at time.Time) (bool, error) {
+	result, err := s.store.ExecContext(
+		ctx,
+		"UPDATE Recovery_Codes SET Used_At = @At WHERE Employee_Id = @Employee_Id AND Code_Hash = @Code_Hash AND Used_At IS NULL",
+		sql.Named("At", at),
+		sql.Named("Employee_Id", employeeID),
+		sql.Named("Code_Hash", hash))
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// DeleteEnrollment implements Store.
+func (s *TwoFactorStore) DeleteEnrollment(ctx context.Context, employeeID int64) error {
+	for _, tsql := range []string{
+		"DELETE FROM Recovery_Codes WHERE Employee_Id = @Employee_Id",
+		"DELETE FROM Totp_Enrollments WHERE Employee_Id = @Employee_Id",
+	} {
+		if _, err := s.store.ExecContext(ctx, tsql, sql.Named("Employee_Id", employeeID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,50 +1,275 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/99designs/gqlgen/graphql/handler"
 	"github.com/99designs/gqlgen/graphql/playground"
 	"github.com/go-chi/chi"
 	"github.com/pascaloseko/ems/graph"
+	"github.com/pascaloseko/ems/internal/apikeys"
 	"github.com/pascaloseko/ems/internal/auth"
+	"github.com/pascaloseko/ems/internal/config"
 	"github.com/pascaloseko/ems/internal/employees"
 	"github.com/pascaloseko/ems/internal/handlers"
+	"github.com/pascaloseko/ems/internal/loaders"
+	"github.com/pascaloseko/ems/internal/lockout"
+	"github.com/pascaloseko/ems/internal/mail"
+	"github.com/pascaloseko/ems/internal/oidc"
+	"github.com/pascaloseko/ems/internal/passwordreset"
+	"github.com/pascaloseko/ems/internal/passwords"
 	"github.com/pascaloseko/ems/internal/pkg/db/database"
+	"github.com/pascaloseko/ems/internal/pkg/jwt"
+	"github.com/pascaloseko/ems/internal/revocation"
+	"github.com/pascaloseko/ems/internal/sessions"
+	"github.com/pascaloseko/ems/internal/twofactor"
 )
 
-const defaultPort = "8080"
-
 func main() {
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = defaultPort
+	storeKind := flag.String("store", "sql", "where employees are kept: sql, the database of EMS_DATABASE_URL, or memory, lost when the server stops")
+	flag.Parse()
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if flag.NArg() > 0 {
+		if flag.Arg(0) != "migrate" {
+			log.Fatalf("unknown command %q, expected migrate", flag.Arg(0))
+		}
+		if err := migrate(cfg.DatabaseURL, flag.Args()[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	tokens, err := jwt.NewIssuer(cfg.JWT)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if cfg.KeyRotationInterval > 0 {
+		tokens.StartRotation(context.Background(), cfg.KeyRotationInterval)
 	}
 
 	router := chi.NewRouter()
-	db, err := database.InitDB()
+	databaseURL := cfg.DatabaseURL
+	if *storeKind == "memory" {
+		// sessions, API keys and the like have no store of their own in memory
+		databaseURL = "sqlite::memory:"
+	}
+	db, dialect, err := database.InitDB(databaseURL)
+	if err != nil && *storeKind == "memory" {
+		log.Fatalf("--store=memory keeps sessions in SQLite: %v", err)
+	}
 	if err != nil {
 		panic(err)
 	}
 	defer db.Close()
-	store := employees.NewEmployeeStore(db)
-	resolver := graph.NewResolver(store)
-	handlers := handlers.NewHandlers(resolver)
+	hasher, err := employees.NewPasswordHasher(cfg.PasswordHashing)
+	if err != nil {
+		log.Fatal(err)
+	}
+	store, err := newEmployeeStore(*storeKind, dialect, db, hasher)
+	if err != nil {
+		log.Fatal(err)
+	}
+	sessionManager := sessions.NewManager(sessions.NewSessionStore(db), cfg.RefreshTokenTTL)
+	denylist := revocation.NewDenylist(revocation.NewRevocationStore(db), tokens.MaxTokenAge())
+	if err := denylist.Sync(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+	denylist.StartSweeper(context.Background(), cfg.RevocationSweepInterval)
+	guard := lockout.NewGuard(lockout.NewLoginFailureStore(db), cfg.Lockout)
+	guard.StartSweeper(context.Background(), time.Hour)
+	mailer, err := newMailer(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	policy, err := newPasswordPolicy(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	keys := apikeys.NewManager(apikeys.NewAPIKeyStore(db))
+	resolver := graph.NewResolver(graph.Services{
+		Employees:      store,
+		Tokens:         tokens,
+		Sessions:       sessionManager,
+		Denylist:       denylist,
+		PasswordResets: passwordreset.NewManager(passwordreset.NewResetStore(db), mailer, cfg.PasswordResetTTL, cfg.PasswordResetURL),
+		PasswordPolicy: policy,
+		Lockout:        guard,
+		TwoFactor:      twofactor.NewManager(twofactor.NewTwoFactorStore(db), cfg.TOTPIssuer),
+		APIKeys:        keys,
+
+		OpenRegistration: cfg.OpenRegistration,
+	})
+	provider, err := newOIDCProvider(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	handlers := handlers.NewHandlers(resolver, tokens, provider)
+
+	srv := handler.NewDefaultServer(graph.NewExecutableSchema(graph.NewConfig(resolver)))
+	srv.SetErrorPresenter(graph.ErrorPresenter)
 
-	srv := handler.NewDefaultServer(graph.NewExecutableSchema(graph.Config{Resolvers: &graph.Resolver{}}))
+	router.Use(sessions.Middleware)
 
 	router.HandleFunc("/login", handlers.LoginHandler)
+	router.HandleFunc("/login/mfa", handlers.MFAHandler)
+	router.HandleFunc("/.well-known/jwks.json", handlers.JWKSHandler)
+	if provider != nil {
+		router.HandleFunc("/auth/oidc/login", handlers.OIDCLoginHandler)
+		router.HandleFunc("/auth/oidc/callback", handlers.OIDCCallbackHandler)
+	}
 
-	// Protected Route: /employees
+	// GraphQL and /register: anonymous requests may only reach public operations
+	// such as login
 	router.Group(func(r chi.Router) {
-		r.Use(auth.Middleware(store))
+		r.Use(auth.OptionalMiddleware(store, tokens, denylist, sessionManager, keys))
+		r.Use(loaders.Middleware(store))
 		r.Handle("/", playground.Handler("GraphQL playground", "/query"))
 		r.Handle("/query", srv)
+		r.HandleFunc("/register", handlers.RegisterHandler)
+	})
+
+	// Protected Route: /employees
+	router.Group(func(r chi.Router) {
+		r.Use(auth.Middleware(store, tokens, denylist, sessionManager, keys))
+		r.Use(loaders.Middleware(store))
 		r.HandleFunc("/employees", handlers.GetAllEmployeesHandler)
 	})
 
-	log.Printf("connect to http://localhost:%s/ for GraphQL playground", port)
-	log.Fatal(http.ListenAndServe(":"+port, router))
+	log.Printf("connect to http://localhost:%s/ for GraphQL playground", cfg.Port)
+	log.Fatal(http.ListenAndServe(":"+cfg.Port, router))
+}
+
+// newEmployeeStore returns the in-memory Store for kind memory, and otherwise
+// the Store written for the dialect of db
+func newEmployeeStore(kind string, dialect database.Dialect, db *sql.DB, hasher employees.PasswordHasher) (employees.Store, error) {
+	switch kind {
+	case "sql":
+	case "memory":
+		log.Println("employees are kept in memory and lost when the server stops")
+		return employees.NewMemoryStore(hasher), nil
+	default:
+		return nil, fmt.Errorf("unknown store %q, expected sql or memory", kind)
+	}
+	switch dialect {
+	case database.Postgres:
+		return employees.NewPostgresStore(db, hasher), nil
+	case database.SQLite:
+		return employees.NewSQLiteStore(db, hasher), nil
+	default:
+		return employees.NewEmployeeStore(db, hasher), nil
+	}
+}
+
+// migrate runs server migrate up, down [N] or status against the database at
+// url. down rolls back the last migration unless told how many.
+func migrate(url string, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: server migrate up|down [N]|status")
+	}
+	db, dialect, err := database.Open(url)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	migrator, err := database.NewMigrator(db, dialect)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("applied %d migrations\n", len(applied))
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of migrations %q", args[1])
+			}
+		}
+		rolledBack, err := migrator.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("rolled back %d migrations\n", len(rolledBack))
+	case "status":
+		states, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, state := range states {
+			status := "pending"
+			switch {
+			case state.Unknown:
+				status = "applied by a newer version, " + state.AppliedAt.Format(time.RFC3339)
+			case !state.AppliedAt.IsZero():
+				status = "applied " + state.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d %-30s %s\n", state.Version, state.Name, status)
+		}
+	default:
+		return fmt.Errorf("unknown migrate command %q, expected up, down or status", args[0])
+	}
+	return nil
+}
+
+// newOIDCProvider returns nil when single sign-on is not configured
+func newOIDCProvider(cfg *config.Config) (*oidc.Provider, error) {
+	provider, err := oidc.NewProvider(cfg.OIDC, nil)
+	if errors.Is(err, oidc.ErrNotConfigured) {
+		return nil, nil
+	}
+	return provider, err
+}
+
+// newMailer sends email through SMTP when a host is configured and writes it to
+// a file or stdout otherwise
+func newMailer(cfg *config.Config) (mail.Mailer, error) {
+	if cfg.SMTP.Host != "" {
+		return mail.NewSMTPMailer(cfg.SMTP), nil
+	}
+	if cfg.MailFile == "" {
+		log.Println("no SMTP host configured, emails are written to stdout")
+		return mail.NewLogMailer(os.Stdout, cfg.SMTP.From), nil
+	}
+	f, err := os.OpenFile(cfg.MailFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("no SMTP host configured, emails are written to %s", cfg.MailFile)
+	return mail.NewLogMailer(f, cfg.SMTP.From), nil
+}
+
+// newPasswordPolicy builds the password policy, adding the passwords listed in
+// the configured denylist file to the built in common ones
+func newPasswordPolicy(cfg *config.Config) (*passwords.Policy, error) {
+	policy := passwords.NewPolicy(cfg.PasswordMinLength, cfg.PasswordMinClasses)
+	if cfg.PasswordDenylistFile == "" {
+		return policy, nil
+	}
+	f, err := os.Open(cfg.PasswordDenylistFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if err := policy.AddDenylist(f); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", cfg.PasswordDenylistFile, err)
+	}
+	return policy, nil
 }